@@ -0,0 +1,343 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package aiff reads and writes AIFF and AIFF-C audio files, mirroring the
+// wave package.
+package aiff
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	scaling8bit  = 1 << 7  // 2 ** (8 - 1)
+	scaling16bit = 1 << 15 // 2 ** (16 - 1)
+	scaling24bit = 1 << 23 // 2 ** (24 - 1)
+	scaling32bit = 1 << 31 // 2 ** (32 - 1)
+)
+
+type Aiff struct {
+	Format Format
+	Data   []byte
+}
+
+type Format struct {
+	Channels      uint16
+	SampleRate    uint32
+	BitsPerSample uint16
+	// CompressionType is the AIFF-C compression type. The zero value, or
+	// NoneCompression, stands for big-endian integer PCM, which is written
+	// as plain AIFF.
+	CompressionType [4]byte
+}
+
+// Supported AIFF-C compression types.
+var (
+	NoneCompression    = [4]byte{'N', 'O', 'N', 'E'}
+	SowtCompression    = [4]byte{'s', 'o', 'w', 't'} // little-endian integer PCM
+	Float32Compression = [4]byte{'f', 'l', '3', '2'}
+	Float64Compression = [4]byte{'f', 'l', '6', '4'}
+)
+
+var (
+	formChunkID = [4]byte{'F', 'O', 'R', 'M'}
+	aiffType    = [4]byte{'A', 'I', 'F', 'F'}
+	aifcType    = [4]byte{'A', 'I', 'F', 'C'}
+	commChunkID = [4]byte{'C', 'O', 'M', 'M'}
+	ssndChunkID = [4]byte{'S', 'S', 'N', 'D'}
+	fverChunkID = [4]byte{'F', 'V', 'E', 'R'}
+)
+
+// aifcVersion1 is the timestamp of the AIFF-C version 1 specification,
+// stored in the mandatory FVER chunk.
+const aifcVersion1 = 0xA2805140
+
+// NewPCMFormat returns the Format for big-endian integer PCM data with the
+// given channels, sample rate and bits per sample (8, 16, 24 or 32).
+func NewPCMFormat(channels, sampleRate, bitsPerSample int) Format {
+	return Format{
+		Channels:        uint16(channels),
+		SampleRate:      uint32(sampleRate),
+		BitsPerSample:   uint16(bitsPerSample),
+		CompressionType: NoneCompression,
+	}
+}
+
+// NewFloatFormat returns the AIFF-C Format for IEEE floating-point data with
+// the given channels, sample rate and bits per sample (32 or 64).
+func NewFloatFormat(channels, sampleRate, bitsPerSample int) Format {
+	compression := Float32Compression
+	if bitsPerSample == 64 {
+		compression = Float64Compression
+	}
+	return Format{
+		Channels:        uint16(channels),
+		SampleRate:      uint32(sampleRate),
+		BitsPerSample:   uint16(bitsPerSample),
+		CompressionType: compression,
+	}
+}
+
+// IsFloat reports whether the samples are IEEE floating-point values.
+func (f Format) IsFloat() bool {
+	return f.compressionType() == Float32Compression || f.compressionType() == Float64Compression
+}
+
+// IsAIFC reports whether the format can only be written as AIFF-C.
+func (f Format) IsAIFC() bool {
+	return f.compressionType() != NoneCompression
+}
+
+// compressionType returns the normalized compression type: the zero value
+// is mapped to NoneCompression, and uppercase float types to lowercase.
+func (f Format) compressionType() [4]byte {
+	switch string(f.CompressionType[:]) {
+	case "\x00\x00\x00\x00":
+		return NoneCompression
+	case "FL32":
+		return Float32Compression
+	case "FL64":
+		return Float64Compression
+	default:
+		return f.CompressionType
+	}
+}
+
+// sampleSize returns the bytes occupied by each sample: samples with a
+// resolution not multiple of 8 are left-justified in whole bytes.
+func (f Format) sampleSize() int {
+	return (int(f.BitsPerSample) + 7) / 8
+}
+
+// AiffToFloatsWithFormat reads an AIFF or AIFF-C file of any supported
+// format, returning its normalized floating-point samples, interleaved, and
+// the format.
+//
+// Like Read, if the sound data is truncated, it returns the available
+// samples along with a *TruncatedDataError.
+func AiffToFloatsWithFormat(filename string) ([]float32, Format, error) {
+	a, readErr := ReadFile(filename)
+	var truncated *TruncatedDataError
+	if readErr != nil && !errors.As(readErr, &truncated) {
+		return nil, Format{}, fmt.Errorf("failed to read AIFF file: %w", readErr)
+	}
+	data, err := DecodeFloats(a)
+	if err != nil {
+		return nil, Format{}, err
+	}
+	return data, a.Format, readErr
+}
+
+// FloatsToAiffWithFormat writes normalized floating-point samples to an
+// AIFF or AIFF-C file, encoding them according to the given format.
+func FloatsToAiffWithFormat(data []float32, format Format, filename string) error {
+	b, err := EncodeFloats(data, format)
+	if err != nil {
+		return err
+	}
+	a := Aiff{Format: format, Data: b}
+	if err := WriteFile(&a, filename); err != nil {
+		return fmt.Errorf("failed to write AIFF file: %w", err)
+	}
+	return nil
+}
+
+// DecodeFloats converts the sound data to normalized floating-point samples.
+// Integer PCM values are scaled to the range [-1, 1); IEEE float values are
+// returned unchanged.
+func DecodeFloats(a *Aiff) ([]float32, error) {
+	decode, err := sampleDecoder(a.Format)
+	if err != nil {
+		return nil, err
+	}
+	size := a.Format.sampleSize()
+	if len(a.Data)%size != 0 {
+		return nil, fmt.Errorf("cannot convert sound data to %d-bit values: bad data length %d", a.Format.BitsPerSample, len(a.Data))
+	}
+	data := make([]float32, len(a.Data)/size)
+	for i := range data {
+		j := i * size
+		data[i] = decode(a.Data[j : j+size])
+	}
+	return data, nil
+}
+
+// EncodeFloats converts normalized floating-point samples to sound data,
+// according to the given format. Integer PCM values are clipped to the
+// range [-1, 1] before scaling. The input data is left untouched.
+func EncodeFloats(data []float32, format Format) ([]byte, error) {
+	encode, err := sampleEncoder(format)
+	if err != nil {
+		return nil, err
+	}
+	size := format.sampleSize()
+	b := make([]byte, len(data)*size)
+	for i, v := range data {
+		j := i * size
+		encode(b[j:j+size], v)
+	}
+	return b, nil
+}
+
+// sampleDecoder returns a function which decodes a single sample, according
+// to the given format, as a normalized floating-point value.
+func sampleDecoder(format Format) (func([]byte) float32, error) {
+	compression := format.compressionType()
+	switch compression {
+	case NoneCompression:
+		switch format.sampleSize() {
+		case 1:
+			return func(b []byte) float32 {
+				return float32(int8(b[0])) / scaling8bit
+			}, nil
+		case 2:
+			return func(b []byte) float32 {
+				return float32(int16(binary.BigEndian.Uint16(b))) / scaling16bit
+			}, nil
+		case 3:
+			return func(b []byte) float32 {
+				return float32(bigEndianInt24(b)) / scaling24bit
+			}, nil
+		case 4:
+			return func(b []byte) float32 {
+				return float32(float64(int32(binary.BigEndian.Uint32(b))) / scaling32bit)
+			}, nil
+		}
+	case SowtCompression:
+		if format.BitsPerSample == 16 {
+			return func(b []byte) float32 {
+				return float32(int16(binary.LittleEndian.Uint16(b))) / scaling16bit
+			}, nil
+		}
+	case Float32Compression:
+		if format.BitsPerSample == 32 {
+			return func(b []byte) float32 {
+				return math.Float32frombits(binary.BigEndian.Uint32(b))
+			}, nil
+		}
+	case Float64Compression:
+		if format.BitsPerSample == 64 {
+			return func(b []byte) float32 {
+				return float32(math.Float64frombits(binary.BigEndian.Uint64(b)))
+			}, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported compression type %q", string(compression[:]))
+	}
+	return nil, fmt.Errorf("unsupported %d-bit samples with compression type %q", format.BitsPerSample, string(compression[:]))
+}
+
+// sampleEncoder returns a function which encodes a single normalized
+// floating-point sample, according to the given format.
+func sampleEncoder(format Format) (func([]byte, float32), error) {
+	compression := format.compressionType()
+	switch compression {
+	case NoneCompression:
+		switch format.BitsPerSample {
+		case 8:
+			return func(b []byte, v float32) {
+				b[0] = byte(quantize(v, scaling8bit))
+			}, nil
+		case 16:
+			return func(b []byte, v float32) {
+				binary.BigEndian.PutUint16(b, uint16(quantize(v, scaling16bit)))
+			}, nil
+		case 24:
+			return func(b []byte, v float32) {
+				putBigEndianInt24(b, int32(quantize(v, scaling24bit)))
+			}, nil
+		case 32:
+			return func(b []byte, v float32) {
+				binary.BigEndian.PutUint32(b, uint32(quantize(v, scaling32bit)))
+			}, nil
+		}
+	case SowtCompression:
+		if format.BitsPerSample == 16 {
+			return func(b []byte, v float32) {
+				binary.LittleEndian.PutUint16(b, uint16(quantize(v, scaling16bit)))
+			}, nil
+		}
+	case Float32Compression:
+		if format.BitsPerSample == 32 {
+			return func(b []byte, v float32) {
+				binary.BigEndian.PutUint32(b, math.Float32bits(v))
+			}, nil
+		}
+	case Float64Compression:
+		if format.BitsPerSample == 64 {
+			return func(b []byte, v float32) {
+				binary.BigEndian.PutUint64(b, math.Float64bits(float64(v)))
+			}, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported compression type %q", string(compression[:]))
+	}
+	return nil, fmt.Errorf("unsupported %d-bit samples with compression type %q", format.BitsPerSample, string(compression[:]))
+}
+
+// quantize scales a normalized sample to an integer PCM value, clipping to
+// the representable range.
+func quantize(v float32, scaling float64) int64 {
+	return int64(math.Round(max(-scaling, min(float64(v)*scaling, scaling-1))))
+}
+
+func bigEndianInt24(b []byte) int32 {
+	_ = b[2] // bounds check hint to compiler; see golang.org/issue/14808
+	// the arithmetic shifts "extend" the sign to 32-bit
+	return int32(uint32(b[2])|uint32(b[1])<<8|uint32(b[0])<<16) << 8 >> 8
+}
+
+func putBigEndianInt24(b []byte, v int32) {
+	u := uint32(v)
+	_ = b[2] // early bounds check to guarantee safety of writes below
+	b[0] = byte(u >> 16)
+	b[1] = byte(u >> 8)
+	b[2] = byte(u)
+}
+
+// decodeExtended converts an 80-bit IEEE 754 extended precision number, as
+// used for the sample rate, to float64.
+func decodeExtended(b [10]byte) float64 {
+	exponent := int(binary.BigEndian.Uint16(b[:2]))
+	mantissa := binary.BigEndian.Uint64(b[2:])
+	if exponent&0x7FFF == 0 && mantissa == 0 {
+		return 0
+	}
+	v := math.Ldexp(float64(mantissa), exponent&0x7FFF-16383-63)
+	if exponent&0x8000 != 0 {
+		v = -v
+	}
+	return v
+}
+
+// encodeExtended converts a float64 to an 80-bit IEEE 754 extended precision
+// number.
+func encodeExtended(v float64) [10]byte {
+	var b [10]byte
+	if v == 0 {
+		return b
+	}
+	var sign uint16
+	if v < 0 {
+		sign = 0x8000
+		v = -v
+	}
+	frac, exp := math.Frexp(v) // v = frac × 2^exp, with frac in [0.5, 1)
+	binary.BigEndian.PutUint16(b[:2], sign|uint16(exp-1+16383))
+	binary.BigEndian.PutUint64(b[2:], uint64(math.Ldexp(frac, 64)))
+	return b
+}
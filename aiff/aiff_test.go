@@ -0,0 +1,226 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aiff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncodeDecodeFloats(t *testing.T) {
+	input := []float32{-1, -0.5, -0.25, 0, 0.25, 0.5, 0.999}
+	testCases := []struct {
+		format    Format
+		tolerance float64
+	}{
+		{NewPCMFormat(1, 48_000, 8), 1.0 / scaling8bit},
+		{NewPCMFormat(1, 48_000, 16), 1.0 / scaling16bit},
+		{NewPCMFormat(1, 44_100, 24), 1.0 / scaling24bit},
+		{NewPCMFormat(1, 96_000, 32), 1.0 / scaling32bit},
+		{Format{Channels: 1, SampleRate: 48_000, BitsPerSample: 16, CompressionType: SowtCompression}, 1.0 / scaling16bit},
+		{NewFloatFormat(1, 48_000, 32), 0},
+		{NewFloatFormat(1, 48_000, 64), 0},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d-bit %s", tc.format.BitsPerSample, tc.format.CompressionType[:]), func(t *testing.T) {
+			data, err := EncodeFloats(input, tc.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err = Write(&Aiff{Format: tc.format, Data: data}, &buf); err != nil {
+				t.Fatal(err)
+			}
+			a, err := Read(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.Format != tc.format {
+				t.Errorf("expected format %+v, actual %+v", tc.format, a.Format)
+			}
+			actual, err := DecodeFloats(a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(actual) != len(input) {
+				t.Fatalf("expected %d samples, actual %d", len(input), len(actual))
+			}
+			for i, v := range input {
+				if math.Abs(float64(actual[i]-v)) > tc.tolerance {
+					t.Errorf("sample %d: expected %g, actual %g", i, v, actual[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExtended(t *testing.T) {
+	for _, v := range []float64{0, 1, 8000, 44_100, 48_000, 96_000, 192_000, 22_050.5, -3} {
+		if actual := decodeExtended(encodeExtended(v)); actual != v {
+			t.Errorf("expected %g, actual %g", v, actual)
+		}
+	}
+	// 44100 Hz, as stored by common encoders.
+	b := [10]byte{0x40, 0x0E, 0xAC, 0x44, 0, 0, 0, 0, 0, 0}
+	if actual := decodeExtended(b); actual != 44_100 {
+		t.Errorf("expected 44100, actual %g", actual)
+	}
+	if actual := encodeExtended(44_100); actual != b {
+		t.Errorf("expected %x, actual %x", b, actual)
+	}
+}
+
+func TestEncoderDecoder(t *testing.T) {
+	format := NewPCMFormat(2, 44_100, 24)
+	data := make([]float32, 2*1001)
+	for i := range data {
+		data[i] = float32(math.Sin(float64(i)/10)) / 2
+	}
+
+	name := filepath.Join(t.TempDir(), "test.aiff")
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := NewEncoder(file, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for from := 0; from < len(data); from += 2 * 300 {
+		if err = enc.WriteFrames(data[from:min(from+2*300, len(data))]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Format != format {
+		t.Errorf("expected format %+v, actual %+v", format, a.Format)
+	}
+
+	file, err = os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+	dec, err := NewDecoder(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := dec.NumFrames(); n != 1001 {
+		t.Errorf("expected 1001 frames, actual %d", n)
+	}
+	var actual []float32
+	buf := make([]float32, 2*128)
+	for {
+		n, err := dec.ReadFrames(buf)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual = append(actual, buf[:n*2]...)
+	}
+	if len(actual) != len(data) {
+		t.Fatalf("expected %d samples, actual %d", len(data), len(actual))
+	}
+	for i, v := range data {
+		if math.Abs(float64(actual[i]-v)) > 1e-6 {
+			t.Errorf("sample %d: expected %g, actual %g", i, v, actual[i])
+		}
+	}
+}
+
+func TestEncoderZeroChannels(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "test.aiff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+	if _, err = NewEncoder(file, NewPCMFormat(0, 44_100, 24)); err == nil {
+		t.Fatal("expected error for zero channels")
+	}
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("expected nothing written, actual %d bytes", info.Size())
+	}
+}
+
+func TestTruncatedData(t *testing.T) {
+	format := NewPCMFormat(2, 44_100, 16)
+	data := []float32{0.125, -0.25, 0.375, -0.5, 0.625, -0.75, 0.875, -1}
+	b, err := EncodeFloats(data, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = Write(&Aiff{Format: format, Data: b}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	// Cut the last frame in half.
+	truncatedFile := buf.Bytes()[:buf.Len()-2]
+
+	a, err := Read(bytes.NewReader(truncatedFile))
+	var truncated *TruncatedDataError
+	if !errors.As(err, &truncated) {
+		t.Fatalf("expected TruncatedDataError, actual %v", err)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected error to match io.ErrUnexpectedEOF")
+	}
+	if truncated.DeclaredSize != 16 || truncated.ActualSize != 14 {
+		t.Errorf("expected sizes 16 and 14, actual %d and %d", truncated.DeclaredSize, truncated.ActualSize)
+	}
+	if !bytes.Equal(a.Data, b[:12]) {
+		t.Errorf("expected data %v, actual %v", b[:12], a.Data)
+	}
+
+	dec, err := NewDecoder(bytes.NewReader(truncatedFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := make([]float32, 2*10)
+	n, err := dec.ReadFrames(frames)
+	if err != nil || n != 3 {
+		t.Fatalf("expected 3 frames and no error, actual %d and %v", n, err)
+	}
+	for i, v := range data[:6] {
+		if math.Abs(float64(frames[i]-v)) > 1e-4 {
+			t.Errorf("sample %d: expected %g, actual %g", i, v, frames[i])
+		}
+	}
+	n, err = dec.ReadFrames(frames)
+	if !errors.As(err, &truncated) || n != 0 {
+		t.Fatalf("expected 0 frames and TruncatedDataError, actual %d and %v", n, err)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aiff
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// An Encoder writes normalized floating-point samples to an AIFF or AIFF-C
+// stream, block by block, without holding the whole sound data in memory.
+//
+// The header is written upfront with placeholder sizes, and rewritten when
+// the Encoder is closed.
+type Encoder struct {
+	w          io.WriteSeeker
+	bw         *bufio.Writer
+	format     Format
+	encode     func([]byte, float32)
+	sampleSize int
+	start      int64
+	dataSize   int64
+	buf        []byte
+}
+
+// NewEncoder writes the AIFF header to w, at its current position, and
+// returns a new Encoder ready to write samples with the given format.
+func NewEncoder(w io.WriteSeeker, format Format) (*Encoder, error) {
+	if format.Channels == 0 {
+		return nil, errors.New("invalid format: zero channels")
+	}
+	encode, err := sampleEncoder(format)
+	if err != nil {
+		return nil, err
+	}
+	start, err := w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current position: %w", err)
+	}
+	bw := bufio.NewWriter(w)
+	if err = writeHeader(bw, format, 0); err != nil {
+		return nil, err
+	}
+	return &Encoder{
+		w:          w,
+		bw:         bw,
+		format:     format,
+		encode:     encode,
+		sampleSize: format.sampleSize(),
+		start:      start,
+	}, nil
+}
+
+// WriteFrames encodes and writes the interleaved samples of one or more
+// frames. The input data is left untouched.
+func (e *Encoder) WriteFrames(src []float32) error {
+	if len(src)%int(e.format.Channels) != 0 {
+		return fmt.Errorf("samples count %d is not a multiple of channels %d", len(src), e.format.Channels)
+	}
+	size := len(src) * e.sampleSize
+	if cap(e.buf) < size {
+		e.buf = make([]byte, size)
+	}
+	buf := e.buf[:size]
+	sampleSize := e.sampleSize
+	for i, v := range src {
+		j := i * sampleSize
+		e.encode(buf[j:j+sampleSize], v)
+	}
+	if _, err := e.bw.Write(buf); err != nil {
+		return fmt.Errorf("failed to write sound data: %w", err)
+	}
+	e.dataSize += int64(size)
+	return nil
+}
+
+// Close flushes any buffered data and rewrites the header with the final
+// sizes. It does not close the underlying writer.
+func (e *Encoder) Close() error {
+	if err := writePadByte(e.bw, e.dataSize); err != nil {
+		return err
+	}
+	if err := e.bw.Flush(); err != nil {
+		return fmt.Errorf("failed to flush sound data: %w", err)
+	}
+	if _, err := e.w.Seek(e.start, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek AIFF header: %w", err)
+	}
+	bw := bufio.NewWriter(e.w)
+	if err := writeHeader(bw, e.format, e.dataSize); err != nil {
+		return fmt.Errorf("failed to rewrite AIFF header: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to rewrite AIFF header: %w", err)
+	}
+	if _, err := e.w.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("failed to seek end of AIFF stream: %w", err)
+	}
+	return nil
+}
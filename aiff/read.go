@@ -0,0 +1,300 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aiff
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+func ReadFile(name string) (_ *Aiff, err error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open AIFF file %q: %w", name, err)
+	}
+	defer func() {
+		if e := file.Close(); e != nil && err == nil {
+			err = fmt.Errorf("failed to close AIFF file %q: %w", name, e)
+		}
+	}()
+	a, err := Read(bufio.NewReader(file))
+	if err != nil {
+		return a, fmt.Errorf("failed to read AIFF file %q: %w", name, err)
+	}
+	return a, nil
+}
+
+// Read reads a whole AIFF or AIFF-C stream.
+//
+// If the sound data is shorter than declared, Read returns the available
+// whole frames along with a *TruncatedDataError, so that lenient callers
+// can recover them.
+func Read(r io.Reader) (*Aiff, error) {
+	r, format, dataSize, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	b, err := readSoundData(r, dataSize, format)
+	var truncated *TruncatedDataError
+	if err != nil && !errors.As(err, &truncated) {
+		return nil, err
+	}
+	return &Aiff{Format: format, Data: b}, err
+}
+
+// TruncatedDataError reports that the sound data is shorter than declared
+// by the common chunk, as it happens with files left by crashed recorders.
+type TruncatedDataError struct {
+	// DeclaredSize is the data size declared by the common chunk.
+	DeclaredSize uint64
+	// ActualSize is the size of the data actually available.
+	ActualSize uint64
+}
+
+func (e *TruncatedDataError) Error() string {
+	return fmt.Sprintf("truncated sound data: declared %d bytes, actual %d", e.DeclaredSize, e.ActualSize)
+}
+
+func (e *TruncatedDataError) Unwrap() error {
+	return io.ErrUnexpectedEOF
+}
+
+// maxDataPrealloc limits the memory allocated upfront for reading the sound
+// data, which might be much shorter than declared.
+const maxDataPrealloc = 64 << 20
+
+// readSoundData reads the sound data of the given declared size. If less
+// data is available, it returns the whole frames read along with a
+// *TruncatedDataError.
+func readSoundData(r io.Reader, size int64, format Format) ([]byte, error) {
+	if size > math.MaxInt {
+		return nil, fmt.Errorf("sound data size %d exceeds the addressable memory", size)
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, min(size, maxDataPrealloc)))
+	n, err := buf.ReadFrom(io.LimitReader(r, size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sound data: %w", err)
+	}
+	b := buf.Bytes()
+	if n < size {
+		frameSize := max(format.sampleSize()*int(format.Channels), 1)
+		return b[:len(b)/frameSize*frameSize], &TruncatedDataError{DeclaredSize: uint64(size), ActualSize: uint64(n)}
+	}
+	return b, nil
+}
+
+// readHeader reads the FORM header, the common chunk and any other chunk
+// preceding the sound data, stopping right at the beginning of the data.
+// It returns a reader limited to the sound data of the SSND chunk, the
+// format and the size of the sound data declared by the common chunk.
+func readHeader(r io.Reader) (io.Reader, Format, int64, error) {
+	var err error
+	if err = expectFourCharCode(r, formChunkID); err != nil {
+		return nil, Format{}, 0, err
+	}
+	formChunkSize, err := readUint32(r)
+	if err != nil {
+		return nil, Format{}, 0, fmt.Errorf("failed to read FORM chunk size: %w", err)
+	}
+	r = io.LimitReader(r, int64(formChunkSize))
+
+	formType, err := readFourCharCode(r)
+	if err != nil {
+		return nil, Format{}, 0, err
+	}
+	if formType != aiffType && formType != aifcType {
+		return nil, Format{}, 0, fmt.Errorf("expected form type %q or %q, actual %q", string(aiffType[:]), string(aifcType[:]), string(formType[:]))
+	}
+	aifc := formType == aifcType
+
+	var format Format
+	var numFrames uint32
+	commFound := false
+	for {
+		chunkID, err := readFourCharCode(r)
+		if err != nil {
+			return nil, Format{}, 0, err
+		}
+		switch chunkID {
+		case commChunkID:
+			if commFound {
+				return nil, Format{}, 0, errors.New("duplicate common chunk encountered")
+			}
+			commFound = true
+			if format, numFrames, err = readCommonChunk(r, aifc); err != nil {
+				return nil, Format{}, 0, err
+			}
+		case ssndChunkID:
+			if !commFound {
+				return nil, Format{}, 0, errors.New("sound data is not preceded by common chunk")
+			}
+			dataSize := int64(numFrames) * int64(format.Channels) * int64(format.sampleSize())
+			if r, err = readSoundDataChunkHeader(r); err != nil {
+				return nil, Format{}, 0, err
+			}
+			return r, format, dataSize, nil
+		default:
+			if err = skipChunk(r, chunkID); err != nil {
+				return nil, Format{}, 0, err
+			}
+		}
+	}
+}
+
+// readCommonChunk reads the common chunk, returning the format and the
+// number of sample frames.
+func readCommonChunk(r io.Reader, aifc bool) (Format, uint32, error) {
+	chunkSize, err := readUint32(r)
+	if err != nil {
+		return Format{}, 0, fmt.Errorf("failed to read common chunk size: %w", err)
+	}
+	cr := io.LimitReader(r, int64(chunkSize))
+
+	var format Format
+	if format.Channels, err = readUint16(cr); err != nil {
+		return Format{}, 0, fmt.Errorf("failed to read format's channels: %w", err)
+	}
+	numFrames, err := readUint32(cr)
+	if err != nil {
+		return Format{}, 0, fmt.Errorf("failed to read number of sample frames: %w", err)
+	}
+	if format.BitsPerSample, err = readUint16(cr); err != nil {
+		return Format{}, 0, fmt.Errorf("failed to read format's sample size: %w", err)
+	}
+	var sampleRate [10]byte
+	if _, err = io.ReadFull(cr, sampleRate[:]); err != nil {
+		return Format{}, 0, fmt.Errorf("failed to read format's sample rate: %w", err)
+	}
+	rate := math.Round(decodeExtended(sampleRate))
+	if rate < 0 || rate > math.MaxUint32 {
+		return Format{}, 0, fmt.Errorf("invalid sample rate %g", rate)
+	}
+	format.SampleRate = uint32(rate)
+
+	format.CompressionType = NoneCompression
+	if aifc {
+		// The compression name, following the type, is not needed.
+		if format.CompressionType, err = readFourCharCode(cr); err != nil {
+			return Format{}, 0, fmt.Errorf("failed to read format's compression type: %w", err)
+		}
+	}
+	if _, err = sampleDecoder(format); err != nil {
+		return Format{}, 0, err
+	}
+	if err = skipUntilEOF(cr); err != nil {
+		return Format{}, 0, fmt.Errorf("failed to skip remaining common chunk data: %w", err)
+	}
+	if err = skipPadByte(r, chunkSize); err != nil {
+		return Format{}, 0, err
+	}
+	return format, numFrames, nil
+}
+
+// readSoundDataChunkHeader reads the SSND chunk fields preceding the sound
+// data, skipping the alignment offset, and returns a reader limited to the
+// sound data of the chunk, which is shorter than declared by the common
+// chunk, if truncated.
+func readSoundDataChunkHeader(r io.Reader) (io.Reader, error) {
+	chunkSize, err := readUint32(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sound data chunk size: %w", err)
+	}
+	offset, err := readUint32(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sound data offset: %w", err)
+	}
+	if _, err = readUint32(r); err != nil {
+		return nil, fmt.Errorf("failed to read sound data block size: %w", err)
+	}
+	if 8+int64(offset) > int64(chunkSize) {
+		return nil, fmt.Errorf("sound data chunk is too short: size %d, offset %d", chunkSize, offset)
+	}
+	if err = skipUntilEOF(io.LimitReader(r, int64(offset))); err != nil {
+		return nil, fmt.Errorf("failed to skip sound data offset: %w", err)
+	}
+	return io.LimitReader(r, int64(chunkSize)-8-int64(offset)), nil
+}
+
+func skipChunk(r io.Reader, chunkID [4]byte) error {
+	chunkSize, err := readUint32(r)
+	if err != nil {
+		return fmt.Errorf("failed to read %q chunk size: %w", string(chunkID[:]), err)
+	}
+	if err = skipUntilEOF(io.LimitReader(r, int64(chunkSize))); err != nil {
+		return fmt.Errorf("failed to skip %q chunk data: %w", string(chunkID[:]), err)
+	}
+	return skipPadByte(r, chunkSize)
+}
+
+// skipPadByte skips the pad byte following a chunk of odd size, since
+// chunks are word-aligned.
+func skipPadByte(r io.Reader, chunkSize uint32) error {
+	if chunkSize%2 == 0 {
+		return nil
+	}
+	var b [1]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to skip pad byte: %w", err)
+	}
+	return nil
+}
+
+func expectFourCharCode(r io.Reader, expected [4]byte) error {
+	code, err := readFourCharCode(r)
+	if err != nil {
+		return err
+	}
+	if code != expected {
+		return fmt.Errorf("expected four-character code %q, actual %q", string(expected[:]), string(code[:]))
+	}
+	return nil
+}
+
+func readFourCharCode(r io.Reader) ([4]byte, error) {
+	var code [4]byte
+	if _, err := io.ReadFull(r, code[:]); err != nil {
+		return code, fmt.Errorf("failed to read four-character code: %w", err)
+	}
+	return code, nil
+}
+
+func readUint16(r io.Reader) (uint16, error) {
+	var arr [2]byte
+	buf := arr[:]
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, fmt.Errorf("failed to read uint16 value: %w", err)
+	}
+	return binary.BigEndian.Uint16(buf), nil
+}
+
+func readUint32(r io.Reader) (uint32, error) {
+	var arr [4]byte
+	buf := arr[:]
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, fmt.Errorf("failed to read uint32 value: %w", err)
+	}
+	return binary.BigEndian.Uint32(buf), nil
+}
+
+func skipUntilEOF(r io.Reader) error {
+	_, err := io.Copy(io.Discard, r)
+	return err
+}
@@ -0,0 +1,237 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aiff
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+func WriteFile(a *Aiff, name string) (err error) {
+	file, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create AIFF file %q: %w", name, err)
+	}
+	defer func() {
+		if e := file.Close(); e != nil && err == nil {
+			err = fmt.Errorf("failed to close AIFF file %q: %w", name, e)
+		}
+	}()
+	bw := bufio.NewWriter(file)
+	err = Write(a, bw)
+	if err != nil {
+		return fmt.Errorf("failed to write AIFF file %q: %w", name, err)
+	}
+	if err = bw.Flush(); err != nil {
+		return fmt.Errorf("failed to flush buffer for AIFF file %q: %w", name, err)
+	}
+	return nil
+}
+
+// Write writes the sound to w, as plain AIFF for big-endian integer PCM
+// data, or as AIFF-C otherwise.
+func Write(a *Aiff, w io.Writer) error {
+	if err := writeHeader(w, a.Format, int64(len(a.Data))); err != nil {
+		return err
+	}
+	if _, err := w.Write(a.Data); err != nil {
+		return fmt.Errorf("failed to write sound data: %w", err)
+	}
+	return writePadByte(w, int64(len(a.Data)))
+}
+
+// writeHeader writes the FORM header, the common chunk and the header of the
+// sound data chunk, so that the sound data itself can immediately follow.
+func writeHeader(w io.Writer, format Format, dataSize int64) error {
+	frameSize := int64(format.Channels) * int64(format.sampleSize())
+	if frameSize == 0 {
+		return fmt.Errorf("invalid format: zero frame size")
+	}
+	formSize := int64(computeHeaderSize(format)) - 8 + dataSize + dataSize%2
+	if formSize > math.MaxUint32 {
+		return fmt.Errorf("sound data size %d exceeds the AIFF 4 GiB limit", dataSize)
+	}
+
+	aifc := format.IsAIFC()
+	formType := aiffType
+	if aifc {
+		formType = aifcType
+	}
+	if err := writeFourCharCode(w, formChunkID); err != nil {
+		return err
+	}
+	if err := writeUint32(w, uint32(formSize)); err != nil {
+		return err
+	}
+	if err := writeFourCharCode(w, formType); err != nil {
+		return err
+	}
+	if aifc {
+		if err := writeFourCharCode(w, fverChunkID); err != nil {
+			return err
+		}
+		if err := writeUint32(w, 4); err != nil {
+			return err
+		}
+		if err := writeUint32(w, aifcVersion1); err != nil {
+			return err
+		}
+	}
+	if err := writeCommonChunk(w, format, uint32(dataSize/frameSize)); err != nil {
+		return err
+	}
+	if err := writeFourCharCode(w, ssndChunkID); err != nil {
+		return err
+	}
+	if err := writeUint32(w, uint32(8+dataSize)); err != nil {
+		return err
+	}
+	if err := writeUint32(w, 0); err != nil { // offset
+		return err
+	}
+	return writeUint32(w, 0) // block size
+}
+
+func writeCommonChunk(w io.Writer, format Format, numFrames uint32) error {
+	if err := writeFourCharCode(w, commChunkID); err != nil {
+		return err
+	}
+	if err := writeUint32(w, computeCommonChunkSize(format)); err != nil {
+		return err
+	}
+	if err := writeUint16(w, format.Channels); err != nil {
+		return err
+	}
+	if err := writeUint32(w, numFrames); err != nil {
+		return err
+	}
+	if err := writeUint16(w, format.BitsPerSample); err != nil {
+		return err
+	}
+	sampleRate := encodeExtended(float64(format.SampleRate))
+	if _, err := w.Write(sampleRate[:]); err != nil {
+		return fmt.Errorf("failed to write format's sample rate: %w", err)
+	}
+	if !format.IsAIFC() {
+		return nil
+	}
+	compression := format.compressionType()
+	if err := writeFourCharCode(w, compression); err != nil {
+		return err
+	}
+	if _, err := w.Write(pascalString(compressionName(compression))); err != nil {
+		return fmt.Errorf("failed to write format's compression name: %w", err)
+	}
+	return nil
+}
+
+func compressionName(compression [4]byte) string {
+	switch compression {
+	case Float32Compression:
+		return "32-bit floating point"
+	case Float64Compression:
+		return "64-bit floating point"
+	default:
+		return ""
+	}
+}
+
+// pascalString returns the string prefixed by its length, padded to an even
+// total length.
+func pascalString(s string) []byte {
+	b := append([]byte{byte(len(s))}, s...)
+	if len(b)%2 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+const commonChunkSize = 2 + // channels
+	4 + // number of sample frames
+	2 + // sample size
+	10 // sample rate
+
+func computeCommonChunkSize(format Format) uint32 {
+	if format.IsAIFC() {
+		return commonChunkSize +
+			4 + // compression type
+			uint32(len(pascalString(compressionName(format.compressionType()))))
+	}
+	return commonChunkSize
+}
+
+func computeHeaderSize(format Format) uint32 {
+	size := uint32(4 + // "FORM"
+		4 + // FORM size
+		4 + // "AIFF" or "AIFC"
+		4 + // "COMM"
+		4 + // COMM size
+		computeCommonChunkSize(format) +
+		4 + // "SSND"
+		4 + // SSND size
+		4 + // offset
+		4) // block size
+	if format.IsAIFC() {
+		size += 4 + // "FVER"
+			4 + // FVER size
+			4 // timestamp
+	}
+	return size
+}
+
+// writePadByte writes the pad byte following a chunk of odd size.
+func writePadByte(w io.Writer, chunkSize int64) error {
+	if chunkSize%2 == 0 {
+		return nil
+	}
+	if _, err := w.Write([]byte{0}); err != nil {
+		return fmt.Errorf("failed to write pad byte: %w", err)
+	}
+	return nil
+}
+
+func writeFourCharCode(w io.Writer, value [4]byte) error {
+	_, err := w.Write(value[:])
+	if err != nil {
+		return fmt.Errorf("failed to write four-character code %q: %w", string(value[:]), err)
+	}
+	return nil
+}
+
+func writeUint16(w io.Writer, v uint16) error {
+	var arr [2]byte
+	buf := arr[:]
+	binary.BigEndian.PutUint16(buf, v)
+	_, err := w.Write(buf)
+	if err != nil {
+		return fmt.Errorf("failed to write uint16 value: %w", err)
+	}
+	return nil
+}
+
+func writeUint32(w io.Writer, v uint32) error {
+	var arr [4]byte
+	buf := arr[:]
+	binary.BigEndian.PutUint32(buf, v)
+	_, err := w.Write(buf)
+	if err != nil {
+		return fmt.Errorf("failed to write uint32 value: %w", err)
+	}
+	return nil
+}
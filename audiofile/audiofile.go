@@ -0,0 +1,337 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audiofile reads and writes audio files of any supported type.
+// The type of input files is detected from their content, while the type
+// of output files is chosen by file name extension. FLAC files can only be
+// read.
+//
+// Formats are described by wave.Format, regardless of the file type.
+package audiofile
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/nlpodyssey/waveny/aiff"
+	"github.com/nlpodyssey/waveny/flac"
+	"github.com/nlpodyssey/waveny/wave"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Type is an audio file type.
+type Type int
+
+const (
+	WAVE Type = iota + 1
+	AIFF
+	FLAC
+)
+
+func (t Type) String() string {
+	switch t {
+	case WAVE:
+		return "WAVE"
+	case AIFF:
+		return "AIFF"
+	case FLAC:
+		return "FLAC"
+	default:
+		return fmt.Sprintf("Type(%d)", int(t))
+	}
+}
+
+// TypeFromExtension returns the file type matching the extension of the
+// given file name: AIFF for ".aif", ".aiff" and ".aifc", FLAC for ".flac",
+// WAVE otherwise.
+func TypeFromExtension(filename string) Type {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".aif", ".aiff", ".aifc":
+		return AIFF
+	case ".flac":
+		return FLAC
+	default:
+		return WAVE
+	}
+}
+
+// sniffSize is the amount of bytes needed to detect the file type.
+const sniffSize = 12
+
+// sniff detects the file type from the first sniffSize bytes.
+func sniff(header []byte) (Type, error) {
+	if len(header) >= 4 && string(header[:4]) == "fLaC" {
+		return FLAC, nil
+	}
+	if len(header) >= sniffSize {
+		switch string(header[:4]) {
+		case "RIFF", "RF64", "BW64":
+			if string(header[8:12]) == "WAVE" {
+				return WAVE, nil
+			}
+		case "FORM":
+			if form := string(header[8:12]); form == "AIFF" || form == "AIFC" {
+				return AIFF, nil
+			}
+		}
+	}
+	return 0, errors.New("unsupported audio file type: only WAVE, AIFF and FLAC are supported")
+}
+
+// sniffFile detects the type of the given file from its content.
+func sniffFile(filename string) (_ Type, err error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open audio file %q: %w", filename, err)
+	}
+	defer func() {
+		if e := file.Close(); e != nil && err == nil {
+			err = fmt.Errorf("failed to close audio file %q: %w", filename, e)
+		}
+	}()
+	header := make([]byte, sniffSize)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return 0, fmt.Errorf("failed to read audio file %q: %w", filename, err)
+	}
+	t, err := sniff(header[:n])
+	if err != nil {
+		return 0, fmt.Errorf("failed to read audio file %q: %w", filename, err)
+	}
+	return t, nil
+}
+
+// FileToFloats reads an audio file of any supported type, returning its
+// normalized floating-point samples, interleaved, the format, and the
+// metadata chunks (only for WAVE files). FLAC samples are described by an
+// integer PCM format.
+//
+// Like wave.Read, if WAVE or AIFF data is truncated, it returns the
+// available samples along with a *wave.TruncatedDataError.
+func FileToFloats(filename string) ([]float32, wave.Format, wave.Chunks, error) {
+	t, err := sniffFile(filename)
+	if err != nil {
+		return nil, wave.Format{}, nil, err
+	}
+	switch t {
+	case AIFF:
+		data, format, err := aiff.AiffToFloatsWithFormat(filename)
+		var truncated *aiff.TruncatedDataError
+		if err != nil && !errors.As(err, &truncated) {
+			return nil, wave.Format{}, nil, err
+		}
+		return data, fromAiffFormat(format), nil, fromAiffError(err)
+	case FLAC:
+		data, info, err := flac.FlacToFloatsWithInfo(filename)
+		if err != nil {
+			return nil, wave.Format{}, nil, err
+		}
+		return data, fromFlacStreamInfo(info), nil, nil
+	default:
+		return wave.WavToFloatsWithMetadata(filename)
+	}
+}
+
+// FloatsToFile writes normalized floating-point samples to an audio file,
+// whose type is chosen by TypeFromExtension. Metadata chunks are only
+// written to WAVE files.
+func FloatsToFile(data []float32, format wave.Format, chunks wave.Chunks, filename string) error {
+	switch TypeFromExtension(filename) {
+	case FLAC:
+		return errUnsupportedFLACWriting
+	case AIFF:
+		aiffFormat, err := toAiffFormat(format)
+		if err != nil {
+			return err
+		}
+		return aiff.FloatsToAiffWithFormat(data, aiffFormat, filename)
+	default:
+		return wave.FloatsToWavWithMetadata(data, format, chunks, filename)
+	}
+}
+
+// A Decoder reads normalized floating-point samples from an audio stream,
+// block by block. See wave.Decoder.
+type Decoder interface {
+	Format() wave.Format
+	ReadFrames(dst []float32) (int, error)
+}
+
+// NewDecoder detects the type of the audio stream from its content, and
+// returns a new Decoder ready to read the samples.
+func NewDecoder(r io.Reader) (Decoder, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	header, err := br.Peek(sniffSize)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read audio stream: %w", err)
+	}
+	t, err := sniff(header)
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case AIFF:
+		dec, err := aiff.NewDecoder(br)
+		if err != nil {
+			return nil, err
+		}
+		return aiffDecoder{dec}, nil
+	case FLAC:
+		dec, err := flac.NewDecoder(br)
+		if err != nil {
+			return nil, err
+		}
+		return flacDecoder{dec}, nil
+	default:
+		dec, err := wave.NewDecoder(br)
+		if err != nil {
+			return nil, err
+		}
+		return dec, nil
+	}
+}
+
+// ReadChunks reads the metadata chunks of an audio stream. Only WAVE files
+// have metadata chunks: for any other type, it returns nil.
+func ReadChunks(r io.ReadSeeker) (wave.Chunks, error) {
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current position: %w", err)
+	}
+	header := make([]byte, sniffSize)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("failed to read audio stream: %w", err)
+	}
+	t, err := sniff(header[:n])
+	if err != nil {
+		return nil, err
+	}
+	if _, err = r.Seek(start, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek audio stream: %w", err)
+	}
+	if t != WAVE {
+		return nil, nil
+	}
+	return wave.ReadChunks(r)
+}
+
+// An Encoder writes normalized floating-point samples to an audio stream,
+// block by block. See wave.Encoder.
+type Encoder interface {
+	WriteFrames(src []float32) error
+	Close() error
+}
+
+// NewEncoder writes the header of an audio stream of the given type to w,
+// and returns a new Encoder ready to write samples with the given format.
+// Metadata chunks are only written to WAVE streams.
+func NewEncoder(w io.WriteSeeker, t Type, format wave.Format, chunks wave.Chunks) (Encoder, error) {
+	switch t {
+	case FLAC:
+		return nil, errUnsupportedFLACWriting
+	case AIFF:
+		aiffFormat, err := toAiffFormat(format)
+		if err != nil {
+			return nil, err
+		}
+		enc, err := aiff.NewEncoder(w, aiffFormat)
+		if err != nil {
+			return nil, err
+		}
+		return enc, nil
+	default:
+		enc, err := wave.NewEncoderWithChunks(w, format, chunks)
+		if err != nil {
+			return nil, err
+		}
+		return enc, nil
+	}
+}
+
+var errUnsupportedFLACWriting = errors.New("writing FLAC files is not supported")
+
+// aiffDecoder adapts aiff.Decoder to the Decoder interface.
+type aiffDecoder struct {
+	*aiff.Decoder
+}
+
+func (d aiffDecoder) Format() wave.Format {
+	return fromAiffFormat(d.Decoder.Format())
+}
+
+func (d aiffDecoder) ReadFrames(dst []float32) (int, error) {
+	n, err := d.Decoder.ReadFrames(dst)
+	return n, fromAiffError(err)
+}
+
+// fromAiffError converts an *aiff.TruncatedDataError, possibly wrapped, to
+// a *wave.TruncatedDataError, so that callers can handle truncated data the
+// same way for any file type.
+func fromAiffError(err error) error {
+	var truncated *aiff.TruncatedDataError
+	if !errors.As(err, &truncated) {
+		return err
+	}
+	return &wave.TruncatedDataError{DeclaredSize: truncated.DeclaredSize, ActualSize: truncated.ActualSize}
+}
+
+func fromAiffFormat(f aiff.Format) wave.Format {
+	channels, rate := int(f.Channels), int(f.SampleRate)
+	if f.IsFloat() {
+		return wave.NewIEEEFloatFormat(channels, rate, int(f.BitsPerSample))
+	}
+	return newPCMFormat(channels, rate, f.BitsPerSample)
+}
+
+// flacDecoder adapts flac.Decoder to the Decoder interface.
+type flacDecoder struct {
+	*flac.Decoder
+}
+
+func (d flacDecoder) Format() wave.Format {
+	return fromFlacStreamInfo(d.StreamInfo())
+}
+
+func fromFlacStreamInfo(info flac.StreamInfo) wave.Format {
+	return newPCMFormat(int(info.Channels), int(info.SampleRate), uint16(info.BitsPerSample))
+}
+
+// newPCMFormat returns a PCM format with samples left-justified in whole
+// bytes, keeping track of the valid bits per sample.
+func newPCMFormat(channels, sampleRate int, bitsPerSample uint16) wave.Format {
+	format := wave.NewPCMFormat(channels, sampleRate, (int(bitsPerSample)+7)/8*8)
+	if format.BitsPerSample != bitsPerSample {
+		format.ValidBitsPerSample = bitsPerSample
+	}
+	return format
+}
+
+func toAiffFormat(f wave.Format) (aiff.Format, error) {
+	channels, rate, bits := int(f.Channels), int(f.SampleRate), int(f.BitsPerSample)
+	switch f.SampleFormatTag() {
+	case wave.PCMFormatTag:
+		return aiff.NewPCMFormat(channels, rate, bits), nil
+	case wave.IEEEFloatFormatTag:
+		return aiff.NewFloatFormat(channels, rate, bits), nil
+	default:
+		return aiff.Format{}, fmt.Errorf("unsupported format tag %d for AIFF", f.SampleFormatTag())
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audiofile
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"github.com/nlpodyssey/waveny/wave"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFloatsToFileAndBack(t *testing.T) {
+	data := []float32{-0.5, 0.25, 0, 0.75, -1, 0.5}
+	dir := t.TempDir()
+	testCases := []struct {
+		name   string
+		format wave.Format
+	}{
+		{"test.wav", wave.NewPCMFormat(2, 44_100, 24)},
+		{"test.aif", wave.NewPCMFormat(2, 44_100, 24)},
+		{"test.AIFF", wave.NewPCMFormat(1, 48_000, 16)},
+		{"test.aiff", wave.NewIEEEFloatFormat(2, 96_000, 32)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			name := filepath.Join(dir, tc.name)
+			if err := FloatsToFile(data, tc.format, nil, name); err != nil {
+				t.Fatal(err)
+			}
+			if typ, err := sniffFile(name); err != nil || typ != TypeFromExtension(name) {
+				t.Errorf("expected type %v, actual %v (error %v)", TypeFromExtension(name), typ, err)
+			}
+			actual, format, _, err := FileToFloats(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format.Channels != tc.format.Channels || format.SampleRate != tc.format.SampleRate ||
+				format.BitsPerSample != tc.format.BitsPerSample || format.SampleFormatTag() != tc.format.SampleFormatTag() {
+				t.Errorf("expected format %+v, actual %+v", tc.format, format)
+			}
+			for i, v := range data {
+				if math.Abs(float64(actual[i]-v)) > 1e-4 {
+					t.Errorf("sample %d: expected %g, actual %g", i, v, actual[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTruncatedAIFF(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.aif")
+	data := []float32{-0.5, 0.25, 0, 0.75, -1, 0.5}
+	if err := FloatsToFile(data, wave.NewPCMFormat(2, 44_100, 16), nil, name); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cut the last frame in half.
+	if err = os.Truncate(name, info.Size()-2); err != nil {
+		t.Fatal(err)
+	}
+	actual, _, _, err := FileToFloats(name)
+	var truncated *wave.TruncatedDataError
+	if !errors.As(err, &truncated) {
+		t.Fatalf("expected wave.TruncatedDataError, actual %v", err)
+	}
+	if len(actual) != 4 {
+		t.Errorf("expected 4 samples, actual %d", len(actual))
+	}
+}
+
+func TestUnsupportedType(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.wav")
+	if err := os.WriteFile(name, []byte("not an audio file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := FileToFloats(name); err == nil {
+		t.Error("expected error, actual nil")
+	}
+}
+
+func TestFLAC(t *testing.T) {
+	// RFC 9639, Appendix D.1.
+	b, err := hex.DecodeString("664c614380000022100010000000" + "0f00000f0ac442f0000000013e84" +
+		"b41807dc690307586a3dad1a2e0ffff869180000bf0358fd03128baa9a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := NewDecoder(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, actual := wave.NewPCMFormat(2, 44_100, 16), dec.Format(); actual != expected {
+		t.Errorf("expected format %+v, actual %+v", expected, actual)
+	}
+	buf := make([]float32, 4)
+	n, err := dec.ReadFrames(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []float32{25588. / 32768, 10416. / 32768}; n != 1 || buf[0] != expected[0] || buf[1] != expected[1] {
+		t.Errorf("expected %v, actual %v", expected, buf[:n*2])
+	}
+
+	name := filepath.Join(t.TempDir(), "test.flac")
+	if err := FloatsToFile(buf[:2], wave.NewPCMFormat(2, 44_100, 16), nil, name); err == nil {
+		t.Error("expected error writing FLAC file, actual nil")
+	}
+}
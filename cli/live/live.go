@@ -43,5 +43,6 @@ func newFlags() *flags {
 	}
 	f.StringVar(&f.Config.ModelDataPath, "model", "", "NAM model-data JSON file.")
 	f.IntVar(&f.Config.FramesPerBuffer, "fpb", 256, "Frames per buffer.")
+	f.IntVar(&f.Config.Workers, "workers", 1, "Goroutines processing each layer of WaveNet models, up to the available cores.")
 	return f
 }
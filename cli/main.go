@@ -17,6 +17,7 @@ package cli
 import (
 	"fmt"
 	"github.com/nlpodyssey/waveny/cli/live"
+	"github.com/nlpodyssey/waveny/cli/process_batch"
 	"github.com/nlpodyssey/waveny/cli/process_rt"
 	"github.com/nlpodyssey/waveny/cli/process_spago"
 	"github.com/nlpodyssey/waveny/cli/process_torch"
@@ -48,6 +49,8 @@ func Main(arguments []string) error {
 		return process_spago.Main(arguments)
 	case "process-rt":
 		return process_rt.Main(arguments)
+	case "process-batch":
+		return process_batch.Main(arguments)
 	case "process-torch":
 		return process_torch.Main(arguments)
 	case "live":
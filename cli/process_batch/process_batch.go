@@ -0,0 +1,108 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package process_batch
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/nlpodyssey/waveny/audiofile"
+	"github.com/nlpodyssey/waveny/processing"
+	"path/filepath"
+	"strings"
+)
+
+func Main(arguments []string) error {
+	f := newFlags()
+	err := f.Parse(arguments)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	configs, err := f.configs()
+	if err != nil {
+		return err
+	}
+	return processing.ProcessBatchWithRTModel(configs, f.BatchConfig)
+}
+
+type flags struct {
+	*flag.FlagSet
+	processing.Config
+	processing.BatchConfig
+	OutputDir string
+}
+
+func newFlags() *flags {
+	f := &flags{
+		FlagSet: flag.NewFlagSet("waveny process-batch", flag.ContinueOnError),
+	}
+	f.Usage = func() {
+		_, _ = fmt.Fprintf(f.Output(), "Usage of %s:\n\n  %[1]s [arguments...] INPUT...\n\n", f.Name())
+		f.PrintDefaults()
+	}
+	f.StringVar(&f.OutputDir, "output-dir", "", "Directory of the processed audio files, named as the inputs: AIFF for .aif/.aiff extensions, WAVE otherwise.")
+	f.Var(&f.Config.Channel, "channel", `Input channel to process: zero-based index, "mix" (downmix) or "all" (each channel independently).`)
+	f.BoolVar(&f.Config.Lenient, "lenient", false, "Process the available samples of truncated inputs, with a warning, instead of failing.")
+	f.Var(&f.Config.Quantization.Dither, "dither", `Dither added when reducing output samples to 24-bit: "none" or "tpdf".`)
+	f.Var(&f.Config.Quantization.NoiseShaping, "noise-shaping", `Noise shaping of the output quantization error: "none", "first-order", "lipshitz" or "wannamaker" (the last two for 44.1/48kHz outputs only).`)
+	f.StringVar(&f.BatchConfig.ModelDataPath, "model", "", "NAM model-data JSON file, of a WaveNet model.")
+	f.IntVar(&f.BatchConfig.Precision, "precision", 32, "Floating-point precision of the model computations, in bits: 32 or 64.")
+	f.IntVar(&f.BatchConfig.Workers, "workers", 1, "Goroutines processing each layer of the model, up to the available cores.")
+	return f
+}
+
+// configs returns the configuration of each input file, given as
+// positional argument. FLAC inputs are written as WAVE.
+func (f *flags) configs() ([]processing.Config, error) {
+	if f.NArg() == 0 {
+		return nil, fmt.Errorf("no input files")
+	}
+	if f.OutputDir == "" {
+		return nil, fmt.Errorf("missing output directory")
+	}
+	configs := make([]processing.Config, f.NArg())
+	outputs := make(map[string]string, f.NArg())
+	for i, input := range f.Args() {
+		name := filepath.Base(input)
+		if audiofile.TypeFromExtension(name) == audiofile.FLAC {
+			name = strings.TrimSuffix(name, filepath.Ext(name)) + ".wav"
+		}
+		output := filepath.Join(f.OutputDir, name)
+		if absPath(input) == absPath(output) {
+			return nil, fmt.Errorf("output file %q would overwrite the input", output)
+		}
+		if other, ok := outputs[output]; ok {
+			return nil, fmt.Errorf("inputs %q and %q have the same output file %q", other, input, output)
+		}
+		outputs[output] = input
+
+		configs[i] = f.Config
+		configs[i].InputPath = input
+		configs[i].OutputPath = output
+	}
+	return configs, nil
+}
+
+// absPath returns the absolute path, or the cleaned path if unknown.
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
@@ -42,8 +42,12 @@ func newFlags() *flags {
 	f := &flags{
 		FlagSet: flag.NewFlagSet("waveny process-torch", flag.ContinueOnError),
 	}
-	f.StringVar(&f.Config.InputPath, "input", "", "Input WAVE file to process.")
-	f.StringVar(&f.Config.OutputPath, "output", "", "Output, processed WAVE file.")
+	f.StringVar(&f.Config.InputPath, "input", "", "Input audio file (WAVE, AIFF or FLAC) to process.")
+	f.StringVar(&f.Config.OutputPath, "output", "", "Output, processed audio file: AIFF for .aif/.aiff extensions, WAVE otherwise.")
+	f.Var(&f.Config.Channel, "channel", `Input channel to process: zero-based index, "mix" (downmix) or "all" (each channel independently).`)
+	f.BoolVar(&f.Config.Lenient, "lenient", false, "Process the available samples of a truncated input, with a warning, instead of failing.")
+	f.Var(&f.Config.Quantization.Dither, "dither", `Dither added when reducing output samples to 24-bit: "none" or "tpdf".`)
+	f.Var(&f.Config.Quantization.NoiseShaping, "noise-shaping", `Noise shaping of the output quantization error: "none", "first-order", "lipshitz" or "wannamaker".`)
 	f.StringVar(&f.TorchConfig.ConfigPath, "config", "", "Model configuration JSON file.")
 	f.StringVar(&f.TorchConfig.ModelPath, "model", "", "PyTorch Lightning model checkpoint file.")
 	return f
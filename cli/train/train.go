@@ -48,6 +48,7 @@ func newFlags() *flags {
 	f.StringVar(&f.PathsConfig.InputPath, "input", "", "Clean audio file.")
 	f.StringVar(&f.PathsConfig.TargetPath, "target", "", "Target (reamped) audio file.")
 	f.StringVar(&f.PathsConfig.OutDirPath, "out", "", "Output directory for model checkpoints.")
+	f.Var(&f.Config.Channel, "channel", `Channel of input and target files: zero-based index or "mix" (downmix).`)
 
 	f.IntVar(&f.Config.MaxEpochs, "epochs", 100, "Maximum training epochs.")
 
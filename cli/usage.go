@@ -27,20 +27,30 @@ List of commands:
     Train a new WaveNet model using SpaGO, producing both SpaGO and .nam models.
 
   process-spago
-    Process a WAVE file using a pre-trained WaveNet SpaGO model,
+    Process an audio file using a pre-trained WaveNet SpaGO model,
     loaded from a file in "native" format.
 
   process-rt
-    Process a WAVE file using the custom Waveny real-time-capable
-    WaveNet model, loaded from a .nam model-data file.
+    Process an audio file using the custom Waveny real-time-capable
+    model (WaveNet, LSTM, ConvNet or Linear), loaded from a .nam
+    model-data file.
+
+  process-batch
+    Process several audio files at once, through the same custom Waveny
+    real-time-capable WaveNet model, loaded from a .nam model-data file.
 
   process-torch
-    Process a WAVE file using a WaveNet SpaGO model, loaded and converted
+    Process an audio file using a WaveNet SpaGO model, loaded and converted
     from a pre-trained NAM PyTorch/Lightning checkpoint file.
 
   live
-    Process audio input in real-time using the custom Waveny WaveNet
-    model, loaded from a .nam model-data file. It uses PortAudio for I/O.
+    Process audio input in real-time using the custom Waveny model
+    (WaveNet, LSTM, ConvNet or Linear), loaded from a .nam model-data file.
+    It uses PortAudio for I/O.
+
+The process commands read WAVE (.wav), AIFF (.aif, .aiff) and FLAC (.flac)
+audio files, and write AIFF files for .aif/.aiff output extensions, WAVE
+files otherwise.
 
 For detailed usage and arguments of each command, execute:
 
@@ -0,0 +1,150 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flac
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// bitReader reads big-endian bit fields, keeping track of the CRC-8 and
+// CRC-16 of the bytes read, as needed to verify frames.
+type bitReader struct {
+	r     *bufio.Reader
+	cache uint64 // the lowest n bits are not read yet
+	n     uint
+	crc8  uint8
+	crc16 uint16
+}
+
+func newBitReader(r *bufio.Reader) *bitReader {
+	return &bitReader{r: r}
+}
+
+// resetCRC restarts the CRC computation. It must be called on byte
+// boundaries, with no bits cached.
+func (br *bitReader) resetCRC() {
+	br.crc8 = 0
+	br.crc16 = 0
+}
+
+// atEOF reports whether the end of the stream is reached, on a byte
+// boundary.
+func (br *bitReader) atEOF() bool {
+	if br.n > 0 {
+		return false
+	}
+	_, err := br.r.Peek(1)
+	return errors.Is(err, io.EOF)
+}
+
+func (br *bitReader) fill() error {
+	b, err := br.r.ReadByte()
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	br.crc8 = crc8Table[br.crc8^b]
+	br.crc16 = br.crc16<<8 ^ crc16Table[byte(br.crc16>>8)^b]
+	br.cache = br.cache<<8 | uint64(b)
+	br.n += 8
+	return nil
+}
+
+// readBits reads an unsigned value of n bits, with n up to 56.
+func (br *bitReader) readBits(n uint) (uint64, error) {
+	for br.n < n {
+		if err := br.fill(); err != nil {
+			return 0, err
+		}
+	}
+	br.n -= n
+	return br.cache >> br.n & (1<<n - 1), nil
+}
+
+// readSigned reads a two's complement signed value of n bits.
+func (br *bitReader) readSigned(n uint) (int64, error) {
+	if n == 0 {
+		return 0, nil
+	}
+	v, err := br.readBits(n)
+	if err != nil {
+		return 0, err
+	}
+	return int64(v<<(64-n)) >> (64 - n), nil
+}
+
+// readUnary reads a unary-coded value: the number of 0 bits before a 1.
+func (br *bitReader) readUnary() (uint64, error) {
+	var count uint64
+	for {
+		if br.n == 0 {
+			if err := br.fill(); err != nil {
+				return 0, err
+			}
+		}
+		v := br.cache & (1<<br.n - 1)
+		if v == 0 {
+			count += uint64(br.n)
+			br.n = 0
+			continue
+		}
+		zeros := br.n - uint(bits.Len64(v))
+		br.n -= zeros + 1
+		return count + uint64(zeros), nil
+	}
+}
+
+// align discards the bits up to the next byte boundary.
+func (br *bitReader) align() {
+	br.n -= br.n % 8
+}
+
+var crc8Table = makeCRC8Table(0x07)
+var crc16Table = makeCRC16Table(0x8005)
+
+func makeCRC8Table(poly uint8) (table [256]uint8) {
+	for i := range table {
+		crc := uint8(i)
+		for j := 0; j < 8; j++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ poly
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}
+
+func makeCRC16Table(poly uint16) (table [256]uint16) {
+	for i := range table {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ poly
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}
@@ -0,0 +1,366 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flac
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// A Decoder reads normalized floating-point samples from a FLAC stream,
+// frame by frame, without loading the whole audio data in memory.
+type Decoder struct {
+	br    *bitReader
+	info  StreamInfo
+	scale float32
+
+	// Samples of the last decoded frame, per channel, and the amount of
+	// them already returned.
+	samples  [][]int32
+	consumed int
+
+	numDecoded uint64
+	md5        hash.Hash
+	md5Buf     []byte
+	done       bool
+}
+
+// NewDecoder reads the FLAC metadata from r, up to the beginning of the
+// first frame, and returns a new Decoder ready to read the samples.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	br := newBufferedReader(r)
+	info, err := readMetadata(br)
+	if err != nil {
+		return nil, err
+	}
+	d := &Decoder{
+		br:      newBitReader(br),
+		info:    info,
+		scale:   1 / float32(uint64(1)<<(info.BitsPerSample-1)),
+		samples: make([][]int32, info.Channels),
+	}
+	if info.MD5 != ([16]byte{}) {
+		d.md5 = md5.New()
+	}
+	return d, nil
+}
+
+// StreamInfo returns the stream information.
+func (d *Decoder) StreamInfo() StreamInfo {
+	return d.info
+}
+
+// NumFrames returns the total amount of frames (inter-channel samples)
+// declared by the stream information, or 0 if unknown.
+func (d *Decoder) NumFrames() int64 {
+	return int64(d.info.TotalSamples)
+}
+
+// ReadFrames reads up to len(dst)/channels frames, storing their interleaved
+// samples into dst, and returns the number of frames read.
+// At the end of the audio data, it returns 0 and io.EOF.
+func (d *Decoder) ReadFrames(dst []float32) (int, error) {
+	channels := int(d.info.Channels)
+	if len(dst) < channels {
+		return 0, fmt.Errorf("destination length %d is too short for a frame of %d channels", len(dst), channels)
+	}
+	for d.consumed == len(d.samples[0]) {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.nextFrame(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := min(len(dst)/channels, len(d.samples[0])-d.consumed)
+	for c, samples := range d.samples {
+		for i, v := range samples[d.consumed : d.consumed+n] {
+			dst[i*channels+c] = float32(v) * d.scale
+		}
+	}
+	d.consumed += n
+	return n, nil
+}
+
+// nextFrame decodes the next frame, or verifies the decoded stream at the
+// end of it.
+func (d *Decoder) nextFrame() error {
+	if d.br.atEOF() {
+		d.done = true
+		d.samples[0] = d.samples[0][:0]
+		d.consumed = 0
+		return d.verify()
+	}
+	if err := d.decodeFrame(); err != nil {
+		return fmt.Errorf("failed to decode FLAC frame: %w", err)
+	}
+	d.consumed = 0
+	d.numDecoded += uint64(len(d.samples[0]))
+	if d.md5 != nil {
+		d.updateMD5()
+	}
+	return nil
+}
+
+// verify checks the amount of decoded samples and their MD5 signature
+// against the stream information, when known.
+func (d *Decoder) verify() error {
+	if total := d.info.TotalSamples; total != 0 && total != d.numDecoded {
+		return fmt.Errorf("decoded %d samples, expected %d", d.numDecoded, total)
+	}
+	if d.md5 != nil && [16]byte(d.md5.Sum(nil)) != d.info.MD5 {
+		return errors.New("MD5 signature mismatch")
+	}
+	return nil
+}
+
+// updateMD5 adds the last decoded frame to the MD5 signature, computed over
+// the interleaved samples as little-endian signed integers.
+func (d *Decoder) updateMD5() {
+	sampleSize := (int(d.info.BitsPerSample) + 7) / 8
+	n := len(d.samples[0]) * len(d.samples) * sampleSize
+	if cap(d.md5Buf) < n {
+		d.md5Buf = make([]byte, n)
+	}
+	buf := d.md5Buf[:n]
+	channels := len(d.samples)
+	for c, samples := range d.samples {
+		for i, v := range samples {
+			j := (i*channels + c) * sampleSize
+			for k := 0; k < sampleSize; k++ {
+				buf[j+k] = byte(v >> (8 * k))
+			}
+		}
+	}
+	d.md5.Write(buf)
+}
+
+// Channel assignments for stereo decorrelation.
+const (
+	leftSideChannels  = 8
+	sideRightChannels = 9
+	midSideChannels   = 10
+)
+
+var sampleRates = [...]uint32{0, 88200, 176400, 192000, 8000, 16000, 22050, 24000, 32000, 44100, 48000, 96000}
+
+var sampleSizes = [...]uint8{0, 8, 12, 0, 16, 20, 24, 32}
+
+func (d *Decoder) decodeFrame() error {
+	br := d.br
+	br.resetCRC()
+
+	sync, err := br.readBits(15)
+	if err != nil {
+		return err
+	}
+	if sync != 0x3FFE<<1 {
+		return errors.New("invalid frame sync code")
+	}
+	if _, err = br.readBits(1); err != nil { // blocking strategy
+		return err
+	}
+	header, err := br.readBits(16)
+	if err != nil {
+		return err
+	}
+	blockSizeCode := header >> 12
+	sampleRateCode := header >> 8 & 0x0F
+	channelAssignment := header >> 4 & 0x0F
+	sampleSizeCode := header >> 1 & 0x07
+	if header&1 != 0 {
+		return errors.New("invalid frame header reserved bit")
+	}
+
+	if err = skipCodedNumber(br); err != nil {
+		return err
+	}
+
+	var blockSize int
+	switch {
+	case blockSizeCode == 0:
+		return errors.New("reserved block size")
+	case blockSizeCode == 1:
+		blockSize = 192
+	case blockSizeCode <= 5:
+		blockSize = 576 << (blockSizeCode - 2)
+	case blockSizeCode == 6:
+		v, err := br.readBits(8)
+		if err != nil {
+			return err
+		}
+		blockSize = int(v) + 1
+	case blockSizeCode == 7:
+		v, err := br.readBits(16)
+		if err != nil {
+			return err
+		}
+		blockSize = int(v) + 1
+	default:
+		blockSize = 256 << (blockSizeCode - 8)
+	}
+
+	sampleRate := d.info.SampleRate
+	switch {
+	case sampleRateCode == 0:
+	case int(sampleRateCode) < len(sampleRates):
+		sampleRate = sampleRates[sampleRateCode]
+	case sampleRateCode == 12:
+		v, err := br.readBits(8)
+		if err != nil {
+			return err
+		}
+		sampleRate = uint32(v) * 1000
+	case sampleRateCode == 13 || sampleRateCode == 14:
+		v, err := br.readBits(16)
+		if err != nil {
+			return err
+		}
+		sampleRate = uint32(v)
+		if sampleRateCode == 14 {
+			sampleRate *= 10
+		}
+	default:
+		return errors.New("invalid sample rate code")
+	}
+	if sampleRate != d.info.SampleRate {
+		return fmt.Errorf("frame sample rate %d differs from stream sample rate %d", sampleRate, d.info.SampleRate)
+	}
+
+	bitsPerSample := d.info.BitsPerSample
+	if sampleSizeCode != 0 {
+		bitsPerSample = sampleSizes[sampleSizeCode]
+		if bitsPerSample == 0 {
+			return errors.New("reserved sample size")
+		}
+	}
+	if bitsPerSample != d.info.BitsPerSample {
+		return fmt.Errorf("frame bits per sample %d differ from stream bits per sample %d", bitsPerSample, d.info.BitsPerSample)
+	}
+
+	channels := int(channelAssignment) + 1
+	if channelAssignment >= leftSideChannels {
+		if channelAssignment > midSideChannels {
+			return errors.New("reserved channel assignment")
+		}
+		channels = 2
+	}
+	if channels != int(d.info.Channels) {
+		return fmt.Errorf("frame channels %d differ from stream channels %d", channels, d.info.Channels)
+	}
+
+	crc8 := br.crc8
+	expectedCRC8, err := br.readBits(8)
+	if err != nil {
+		return err
+	}
+	if uint8(expectedCRC8) != crc8 {
+		return errors.New("frame header CRC-8 mismatch")
+	}
+
+	for c := range d.samples {
+		if cap(d.samples[c]) < blockSize {
+			d.samples[c] = make([]int32, blockSize)
+		}
+		d.samples[c] = d.samples[c][:blockSize]
+		// The side channel needs an extra bit.
+		bps := uint(bitsPerSample)
+		if (c == 1 && (channelAssignment == leftSideChannels || channelAssignment == midSideChannels)) ||
+			(c == 0 && channelAssignment == sideRightChannels) {
+			bps++
+		}
+		if err = decodeSubframe(br, d.samples[c], bps); err != nil {
+			return fmt.Errorf("channel %d: %w", c, err)
+		}
+	}
+	decorrelate(d.samples, channelAssignment)
+
+	br.align()
+	crc16 := br.crc16
+	expectedCRC16, err := br.readBits(16)
+	if err != nil {
+		return err
+	}
+	if uint16(expectedCRC16) != crc16 {
+		return errors.New("frame CRC-16 mismatch")
+	}
+	return nil
+}
+
+// skipCodedNumber skips the frame or sample number, coded like UTF-8 with
+// up to 7 bytes.
+func skipCodedNumber(br *bitReader) error {
+	first, err := br.readBits(8)
+	if err != nil {
+		return err
+	}
+	var extra int
+	switch {
+	case first&0x80 == 0:
+		extra = 0
+	case first&0xE0 == 0xC0:
+		extra = 1
+	case first&0xF0 == 0xE0:
+		extra = 2
+	case first&0xF8 == 0xF0:
+		extra = 3
+	case first&0xFC == 0xF8:
+		extra = 4
+	case first&0xFE == 0xFC:
+		extra = 5
+	case first == 0xFE:
+		extra = 6
+	default:
+		return errors.New("invalid coded frame number")
+	}
+	for i := 0; i < extra; i++ {
+		b, err := br.readBits(8)
+		if err != nil {
+			return err
+		}
+		if b&0xC0 != 0x80 {
+			return errors.New("invalid coded frame number")
+		}
+	}
+	return nil
+}
+
+// decorrelate restores left and right channels from stereo decorrelation.
+func decorrelate(samples [][]int32, channelAssignment uint64) {
+	switch channelAssignment {
+	case leftSideChannels:
+		left, side := samples[0], samples[1]
+		for i := range side {
+			side[i] = left[i] - side[i]
+		}
+	case sideRightChannels:
+		side, right := samples[0], samples[1]
+		for i := range side {
+			side[i] += right[i]
+		}
+	case midSideChannels:
+		mid, side := samples[0], samples[1]
+		for i := range mid {
+			m := int64(mid[i])<<1 | int64(side[i])&1
+			s := int64(side[i])
+			mid[i] = int32((m + s) >> 1)
+			side[i] = int32((m - s) >> 1)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package flac decodes FLAC audio files, supporting constant, verbatim,
+// fixed and LPC subframes, with any bit depth from 4 to 32 bits.
+package flac
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// StreamInfo is the content of the STREAMINFO metadata block.
+type StreamInfo struct {
+	MinBlockSize  uint16
+	MaxBlockSize  uint16
+	MinFrameSize  uint32
+	MaxFrameSize  uint32
+	SampleRate    uint32
+	Channels      uint8
+	BitsPerSample uint8
+	// TotalSamples is the number of inter-channel samples (frames), or 0 if
+	// unknown.
+	TotalSamples uint64
+	// MD5 is the signature of the unencoded audio data, or zero if unknown.
+	MD5 [16]byte
+}
+
+var flacMarker = [4]byte{'f', 'L', 'a', 'C'}
+
+// Metadata block types.
+const (
+	streamInfoBlockType = 0
+	invalidBlockType    = 127
+)
+
+const streamInfoSize = 34
+
+// FlacToFloatsWithInfo reads a FLAC file, returning its normalized
+// floating-point samples, interleaved, and the stream information.
+func FlacToFloatsWithInfo(filename string) (_ []float32, _ StreamInfo, err error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, StreamInfo{}, fmt.Errorf("failed to open FLAC file %q: %w", filename, err)
+	}
+	defer func() {
+		if e := file.Close(); e != nil && err == nil {
+			err = fmt.Errorf("failed to close FLAC file %q: %w", filename, e)
+		}
+	}()
+
+	dec, err := NewDecoder(file)
+	if err != nil {
+		return nil, StreamInfo{}, fmt.Errorf("failed to read FLAC file %q: %w", filename, err)
+	}
+	info := dec.StreamInfo()
+	channels := int(info.Channels)
+	// The declared size is only a hint, not to be trusted blindly.
+	data := make([]float32, 0, min(info.TotalSamples, 1<<24)*uint64(channels))
+	buf := make([]float32, 4096*channels)
+	for {
+		n, err := dec.ReadFrames(buf)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, StreamInfo{}, fmt.Errorf("failed to read FLAC file %q: %w", filename, err)
+		}
+		data = append(data, buf[:n*channels]...)
+	}
+	return data, info, nil
+}
+
+// readMetadata reads the "fLaC" marker and all the metadata blocks,
+// returning the stream information.
+func readMetadata(r io.Reader) (StreamInfo, error) {
+	var marker [4]byte
+	if _, err := io.ReadFull(r, marker[:]); err != nil {
+		return StreamInfo{}, fmt.Errorf("failed to read FLAC marker: %w", err)
+	}
+	if marker != flacMarker {
+		return StreamInfo{}, fmt.Errorf("expected FLAC marker %q, actual %q", string(flacMarker[:]), string(marker[:]))
+	}
+
+	var info StreamInfo
+	for first := true; ; first = false {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return StreamInfo{}, fmt.Errorf("failed to read metadata block header: %w", err)
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		size := uint32(header[1])<<16 | uint32(header[2])<<8 | uint32(header[3])
+
+		switch {
+		case first != (blockType == streamInfoBlockType):
+			return StreamInfo{}, errors.New("STREAMINFO must be the first and only metadata block of its type")
+		case blockType == invalidBlockType:
+			return StreamInfo{}, errors.New("invalid metadata block type")
+		case blockType == streamInfoBlockType:
+			if size != streamInfoSize {
+				return StreamInfo{}, fmt.Errorf("invalid STREAMINFO size %d", size)
+			}
+			var err error
+			if info, err = readStreamInfo(r); err != nil {
+				return StreamInfo{}, err
+			}
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size)); err != nil {
+				return StreamInfo{}, fmt.Errorf("failed to skip metadata block of type %d: %w", blockType, err)
+			}
+		}
+		if last {
+			return info, nil
+		}
+	}
+}
+
+func readStreamInfo(r io.Reader) (StreamInfo, error) {
+	var b [streamInfoSize]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return StreamInfo{}, fmt.Errorf("failed to read STREAMINFO: %w", err)
+	}
+	uint24 := func(b []byte) uint32 {
+		return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+	}
+	// sample rate (20 bits), channels - 1 (3), bits per sample - 1 (5),
+	// total samples (36)
+	x := binary.BigEndian.Uint64(b[10:18])
+	info := StreamInfo{
+		MinBlockSize:  binary.BigEndian.Uint16(b[0:2]),
+		MaxBlockSize:  binary.BigEndian.Uint16(b[2:4]),
+		MinFrameSize:  uint24(b[4:7]),
+		MaxFrameSize:  uint24(b[7:10]),
+		SampleRate:    uint32(x >> 44),
+		Channels:      uint8(x>>41&0x07) + 1,
+		BitsPerSample: uint8(x>>36&0x1F) + 1,
+		TotalSamples:  x & (1<<36 - 1),
+		MD5:           [16]byte(b[18:34]),
+	}
+	if info.SampleRate == 0 {
+		return StreamInfo{}, errors.New("invalid STREAMINFO sample rate 0")
+	}
+	if info.BitsPerSample < 4 {
+		return StreamInfo{}, fmt.Errorf("invalid STREAMINFO bits per sample %d", info.BitsPerSample)
+	}
+	return info, nil
+}
+
+// newBufferedReader returns r itself if it is already a *bufio.Reader.
+func newBufferedReader(r io.Reader) *bufio.Reader {
+	if br, ok := r.(*bufio.Reader); ok {
+		return br
+	}
+	return bufio.NewReader(r)
+}
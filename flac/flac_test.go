@@ -0,0 +1,408 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flac
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"math/bits"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// The minimal stream of RFC 9639, Appendix D.1: one stereo 16-bit frame,
+// with verbatim subframes and wasted bits.
+const rfcExample = `
+664c 6143 8000 0022 1000 1000 0000 0f00 000f 0ac4 42f0 0000 0001 3e84
+b418 07dc 6903 0758 6a3d ad1a 2e0f fff8 6918 0000 bf03 58fd 0312 8baa 9a`
+
+func TestDecodeRFCExample(t *testing.T) {
+	b, err := hex.DecodeString(strings.Join(strings.Fields(rfcExample), ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := NewDecoder(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := dec.StreamInfo()
+	if info.SampleRate != 44100 || info.Channels != 2 || info.BitsPerSample != 16 || info.TotalSamples != 1 {
+		t.Errorf("unexpected stream info %+v", info)
+	}
+	expected := [][]int32{{25588}, {10416}}
+	assertDecoded(t, dec, expected, 16)
+}
+
+// testSubframe describes how a test channel is encoded.
+type testSubframe struct {
+	kind           string // "constant", "verbatim", "fixed" or "lpc"
+	order          int
+	coefficients   []int64
+	precision      uint
+	shift          uint
+	wasted         uint
+	partitionOrder uint
+	escape         bool
+}
+
+type testFrame struct {
+	assignment uint64
+	subframes  []testSubframe
+	size       int
+}
+
+func TestDecodeSubframes(t *testing.T) {
+	testCases := []struct {
+		name          string
+		bitsPerSample uint
+		channels      int
+		frames        []testFrame
+	}{
+		{
+			name:          "16-bit stereo",
+			bitsPerSample: 16,
+			channels:      2,
+			frames: []testFrame{
+				{1, []testSubframe{{kind: "fixed", order: 2}, {kind: "fixed", order: 0, partitionOrder: 3}}, 1024},
+				{leftSideChannels, []testSubframe{{kind: "lpc", order: 3, coefficients: []int64{1700, -900, 150}, precision: 12, shift: 10}, {kind: "verbatim"}}, 1000},
+				{midSideChannels, []testSubframe{{kind: "fixed", order: 4, partitionOrder: 2}, {kind: "lpc", order: 2, coefficients: []int64{60, -28}, precision: 7, shift: 5, escape: true}}, 512},
+				{1, []testSubframe{{kind: "constant"}, {kind: "verbatim", wasted: 3}}, 256},
+				{sideRightChannels, []testSubframe{{kind: "fixed", order: 1}, {kind: "fixed", order: 3}}, 77},
+			},
+		},
+		{
+			name:          "24-bit mono",
+			bitsPerSample: 24,
+			channels:      1,
+			frames: []testFrame{
+				{0, []testSubframe{{kind: "lpc", order: 8, coefficients: []int64{3000, -1500, 800, -400, 200, -100, 50, -25}, precision: 15, shift: 11, partitionOrder: 4}}, 4096},
+				{0, []testSubframe{{kind: "fixed", order: 1, wasted: 2}}, 4096},
+				{0, []testSubframe{{kind: "verbatim"}}, 100},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(1))
+			var all [][]int32 = make([][]int32, tc.channels)
+			var frames [][][]int32
+			for _, f := range tc.frames {
+				samples := make([][]int32, tc.channels)
+				for c := range samples {
+					samples[c] = testSignal(rnd, f.size, tc.bitsPerSample, f.subframes[c], c)
+					all[c] = append(all[c], samples[c]...)
+				}
+				frames = append(frames, samples)
+			}
+			stream := encodeTestStream(t, tc.bitsPerSample, tc.frames, frames)
+			dec, err := NewDecoder(bytes.NewReader(stream))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertDecoded(t, dec, all, tc.bitsPerSample)
+
+			// Corrupted streams are detected.
+			stream[len(stream)/2] ^= 0x10
+			dec, err = NewDecoder(bytes.NewReader(stream))
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf := make([]float32, 1024*tc.channels)
+			for err == nil {
+				_, err = dec.ReadFrames(buf)
+			}
+			if errors.Is(err, io.EOF) {
+				t.Error("expected error decoding corrupted stream")
+			}
+		})
+	}
+}
+
+func assertDecoded(t *testing.T, dec *Decoder, expected [][]int32, bitsPerSample uint) {
+	t.Helper()
+	channels := len(expected)
+	scale := 1 / float32(uint64(1)<<(bitsPerSample-1))
+	var actual []float32
+	buf := make([]float32, 300*channels)
+	for {
+		n, err := dec.ReadFrames(buf)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual = append(actual, buf[:n*channels]...)
+	}
+	if len(actual) != len(expected[0])*channels {
+		t.Fatalf("expected %d samples, actual %d", len(expected[0])*channels, len(actual))
+	}
+	for c, samples := range expected {
+		for i, v := range samples {
+			if a := actual[i*channels+c]; a != float32(v)*scale {
+				t.Fatalf("channel %d, sample %d: expected %d, actual %g", c, i, v, a/scale)
+			}
+		}
+	}
+}
+
+// testSignal returns a noisy sine wave, suitable for the given subframe.
+func testSignal(rnd *rand.Rand, n int, bitsPerSample uint, sf testSubframe, channel int) []int32 {
+	amplitude := float64(int64(1)<<(bitsPerSample-1)) * 0.4
+	samples := make([]int32, n)
+	constant := int32(rnd.Intn(1000)) - 500
+	for i := range samples {
+		if sf.kind == "constant" {
+			samples[i] = constant
+			continue
+		}
+		v := amplitude*math.Sin(float64(i)/float64(9+channel*5)) + rnd.NormFloat64()*amplitude/100
+		samples[i] = int32(v) >> sf.wasted << sf.wasted
+	}
+	return samples
+}
+
+// encodeTestStream encodes a FLAC stream, with the given frames.
+func encodeTestStream(t *testing.T, bitsPerSample uint, frameConfigs []testFrame, frames [][][]int32) []byte {
+	t.Helper()
+	channels := len(frames[0])
+	hash := md5.New()
+	var totalSamples uint64
+	for _, samples := range frames {
+		for i := range samples[0] {
+			for c := range samples {
+				var b [4]byte
+				binary.LittleEndian.PutUint32(b[:], uint32(samples[c][i]))
+				hash.Write(b[:(bitsPerSample+7)/8])
+			}
+		}
+		totalSamples += uint64(len(samples[0]))
+	}
+
+	w := &bitWriter{}
+	w.buf = append(w.buf, "fLaC"...)
+	w.writeBits(1, 1) // last metadata block
+	w.writeBits(0, 7) // STREAMINFO
+	w.writeBits(34, 24)
+	w.writeBits(16, 16)
+	w.writeBits(4096, 16)
+	w.writeBits(0, 24)
+	w.writeBits(0, 24)
+	w.writeBits(48000, 20)
+	w.writeBits(uint64(channels-1), 3)
+	w.writeBits(uint64(bitsPerSample-1), 5)
+	w.writeBits(totalSamples, 36)
+	w.buf = hash.Sum(w.buf)
+
+	for i, samples := range frames {
+		cfg := frameConfigs[i]
+		start := len(w.buf)
+		w.writeBits(0x3FFE, 14)
+		w.writeBits(0, 2)
+		w.writeBits(7, 4) // 16-bit block size at end of header
+		w.writeBits(0, 4) // sample rate from STREAMINFO
+		w.writeBits(cfg.assignment, 4)
+		w.writeBits(0, 3) // bits per sample from STREAMINFO
+		w.writeBits(0, 1)
+		w.writeBits(uint64(i), 8)
+		w.writeBits(uint64(len(samples[0])-1), 16)
+		w.writeBits(uint64(crc8(w.buf[start:])), 8)
+
+		coded := correlate(samples, cfg.assignment)
+		for c, s := range coded {
+			bps := bitsPerSample
+			if (c == 1 && (cfg.assignment == leftSideChannels || cfg.assignment == midSideChannels)) ||
+				(c == 0 && cfg.assignment == sideRightChannels) {
+				bps++
+			}
+			encodeTestSubframe(w, s, bps, cfg.subframes[c])
+		}
+		w.align()
+		w.writeBits(uint64(crc16(w.buf[start:])), 16)
+	}
+	return w.buf
+}
+
+func correlate(samples [][]int32, assignment uint64) [][]int32 {
+	if assignment < leftSideChannels {
+		return samples
+	}
+	left, right := samples[0], samples[1]
+	a := make([]int32, len(left))
+	b := make([]int32, len(left))
+	for i := range left {
+		switch assignment {
+		case leftSideChannels:
+			a[i], b[i] = left[i], left[i]-right[i]
+		case sideRightChannels:
+			a[i], b[i] = left[i]-right[i], right[i]
+		case midSideChannels:
+			a[i], b[i] = (left[i]+right[i])>>1, left[i]-right[i]
+		}
+	}
+	return [][]int32{a, b}
+}
+
+func encodeTestSubframe(w *bitWriter, samples []int32, bps uint, sf testSubframe) {
+	var subframeType uint64
+	switch sf.kind {
+	case "constant":
+		subframeType = 0
+	case "verbatim":
+		subframeType = 1
+	case "fixed":
+		subframeType = 8 + uint64(sf.order)
+	case "lpc":
+		subframeType = 31 + uint64(sf.order)
+	}
+	w.writeBits(0, 1)
+	w.writeBits(subframeType, 6)
+	if sf.wasted > 0 {
+		w.writeBits(1, 1)
+		w.writeUnary(uint64(sf.wasted - 1))
+	} else {
+		w.writeBits(0, 1)
+	}
+	bps -= sf.wasted
+	s := make([]int32, len(samples))
+	for i, v := range samples {
+		s[i] = v >> sf.wasted
+	}
+
+	switch sf.kind {
+	case "constant":
+		w.writeSigned(int64(s[0]), bps)
+	case "verbatim":
+		for _, v := range s {
+			w.writeSigned(int64(v), bps)
+		}
+	case "fixed":
+		for _, v := range s[:sf.order] {
+			w.writeSigned(int64(v), bps)
+		}
+		encodeTestResidual(w, residuals(s, fixedCoefficients[sf.order], 0), sf)
+	case "lpc":
+		for _, v := range s[:sf.order] {
+			w.writeSigned(int64(v), bps)
+		}
+		w.writeBits(uint64(sf.precision-1), 4)
+		w.writeSigned(int64(sf.shift), 5)
+		for _, c := range sf.coefficients {
+			w.writeSigned(c, sf.precision)
+		}
+		encodeTestResidual(w, residuals(s, sf.coefficients, sf.shift), sf)
+	}
+}
+
+func residuals(samples []int32, coefficients []int64, shift uint) []int64 {
+	order := len(coefficients)
+	r := make([]int64, len(samples)-order)
+	for i := order; i < len(samples); i++ {
+		var sum int64
+		for j, c := range coefficients {
+			sum += c * int64(samples[i-1-j])
+		}
+		r[i-order] = int64(samples[i]) - sum>>shift
+	}
+	return r
+}
+
+func encodeTestResidual(w *bitWriter, residuals []int64, sf testSubframe) {
+	w.writeBits(1, 2) // 5-bit Rice parameters
+	w.writeBits(uint64(sf.partitionOrder), 4)
+	partitions := 1 << sf.partitionOrder
+	blockSize := len(residuals) + sf.order
+	for p := 0; p < partitions; p++ {
+		from := max(p*blockSize>>sf.partitionOrder-sf.order, 0)
+		to := (p+1)*blockSize>>sf.partitionOrder - sf.order
+		part := residuals[from:to]
+		if sf.escape {
+			width := uint(1)
+			for _, r := range part {
+				width = max(width, uint(bits.Len64(uint64(r^r>>63)))+1)
+			}
+			w.writeBits(31, 5)
+			w.writeBits(uint64(width), 5)
+			for _, r := range part {
+				w.writeSigned(r, width)
+			}
+			continue
+		}
+		var sum uint64
+		for _, r := range part {
+			sum += uint64(r<<1 ^ r>>63)
+		}
+		param := uint(0)
+		if len(part) > 0 {
+			param = uint(bits.Len64(sum / uint64(len(part))))
+		}
+		w.writeBits(uint64(param), 5)
+		for _, r := range part {
+			u := uint64(r<<1 ^ r>>63)
+			w.writeUnary(u >> param)
+			w.writeBits(u&(1<<param-1), param)
+		}
+	}
+}
+
+type bitWriter struct {
+	buf []byte
+	n   uint // bits used in the last byte
+}
+
+func (w *bitWriter) writeBits(v uint64, n uint) {
+	for i := int(n) - 1; i >= 0; i-- {
+		if w.n == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		w.buf[len(w.buf)-1] |= byte(v>>i&1) << (7 - w.n)
+		w.n = (w.n + 1) % 8
+	}
+}
+
+func (w *bitWriter) writeSigned(v int64, n uint) {
+	w.writeBits(uint64(v)&(1<<n-1), n)
+}
+
+func (w *bitWriter) writeUnary(v uint64) {
+	for ; v > 0; v-- {
+		w.writeBits(0, 1)
+	}
+	w.writeBits(1, 1)
+}
+
+func (w *bitWriter) align() {
+	w.n = 0
+}
+
+func crc8(b []byte) (crc uint8) {
+	for _, v := range b {
+		crc = crc8Table[crc^v]
+	}
+	return crc
+}
+
+func crc16(b []byte) (crc uint16) {
+	for _, v := range b {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^v]
+	}
+	return crc
+}
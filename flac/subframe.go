@@ -0,0 +1,228 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flac
+
+import (
+	"errors"
+	"fmt"
+)
+
+// decodeSubframe decodes the samples of a channel, with the given bits per
+// sample, filling the whole samples slice.
+func decodeSubframe(br *bitReader, samples []int32, bps uint) error {
+	if bps > 32 {
+		return fmt.Errorf("unsupported %d-bit subframe", bps)
+	}
+	header, err := br.readBits(8)
+	if err != nil {
+		return err
+	}
+	if header&0x80 != 0 {
+		return errors.New("invalid subframe padding bit")
+	}
+	subframeType := header >> 1 & 0x3F
+
+	var wasted uint
+	if header&1 != 0 {
+		k, err := br.readUnary()
+		if err != nil {
+			return err
+		}
+		wasted = uint(k) + 1
+		if wasted >= bps {
+			return fmt.Errorf("invalid %d wasted bits for %d-bit samples", wasted, bps)
+		}
+		bps -= wasted
+	}
+
+	switch {
+	case subframeType == 0:
+		err = decodeConstant(br, samples, bps)
+	case subframeType == 1:
+		err = decodeVerbatim(br, samples, bps)
+	case subframeType >= 8 && subframeType <= 12:
+		err = decodeFixed(br, samples, bps, int(subframeType-8))
+	case subframeType >= 32:
+		err = decodeLPC(br, samples, bps, int(subframeType-31))
+	default:
+		return fmt.Errorf("reserved subframe type %d", subframeType)
+	}
+	if err != nil {
+		return err
+	}
+
+	if wasted > 0 {
+		for i := range samples {
+			samples[i] <<= wasted
+		}
+	}
+	return nil
+}
+
+func decodeConstant(br *bitReader, samples []int32, bps uint) error {
+	v, err := br.readSigned(bps)
+	if err != nil {
+		return err
+	}
+	for i := range samples {
+		samples[i] = int32(v)
+	}
+	return nil
+}
+
+func decodeVerbatim(br *bitReader, samples []int32, bps uint) error {
+	for i := range samples {
+		v, err := br.readSigned(bps)
+		if err != nil {
+			return err
+		}
+		samples[i] = int32(v)
+	}
+	return nil
+}
+
+// fixedCoefficients are the predictor coefficients of each fixed order.
+var fixedCoefficients = [...][]int64{
+	{},
+	{1},
+	{2, -1},
+	{3, -3, 1},
+	{4, -6, 4, -1},
+}
+
+func decodeFixed(br *bitReader, samples []int32, bps uint, order int) error {
+	if order > len(samples) {
+		return fmt.Errorf("fixed predictor order %d exceeds block size %d", order, len(samples))
+	}
+	if err := decodeVerbatim(br, samples[:order], bps); err != nil {
+		return err
+	}
+	if err := decodeResidual(br, samples, order); err != nil {
+		return err
+	}
+	predict(samples, fixedCoefficients[order], 0)
+	return nil
+}
+
+func decodeLPC(br *bitReader, samples []int32, bps uint, order int) error {
+	if order > len(samples) {
+		return fmt.Errorf("LPC order %d exceeds block size %d", order, len(samples))
+	}
+	if err := decodeVerbatim(br, samples[:order], bps); err != nil {
+		return err
+	}
+	precision, err := br.readBits(4)
+	if err != nil {
+		return err
+	}
+	if precision == 0x0F {
+		return errors.New("invalid LPC coefficients precision")
+	}
+	precision++
+	shift, err := br.readSigned(5)
+	if err != nil {
+		return err
+	}
+	if shift < 0 {
+		return fmt.Errorf("invalid negative LPC shift %d", shift)
+	}
+	coefficients := make([]int64, order)
+	for i := range coefficients {
+		if coefficients[i], err = br.readSigned(uint(precision)); err != nil {
+			return err
+		}
+	}
+	if err = decodeResidual(br, samples, order); err != nil {
+		return err
+	}
+	predict(samples, coefficients, uint(shift))
+	return nil
+}
+
+// predict restores the samples following the warm-up ones, adding the
+// linear prediction to the residuals they hold. The coefficients apply to
+// the previous samples, from the most recent one.
+func predict(samples []int32, coefficients []int64, shift uint) {
+	order := len(coefficients)
+	for i := order; i < len(samples); i++ {
+		var sum int64
+		for j, c := range coefficients {
+			sum += c * int64(samples[i-1-j])
+		}
+		samples[i] += int32(sum >> shift)
+	}
+}
+
+// decodeResidual decodes the Rice-coded residuals of the samples following
+// the warm-up ones.
+func decodeResidual(br *bitReader, samples []int32, order int) error {
+	method, err := br.readBits(2)
+	if err != nil {
+		return err
+	}
+	var paramBits uint
+	switch method {
+	case 0:
+		paramBits = 4
+	case 1:
+		paramBits = 5
+	default:
+		return fmt.Errorf("reserved residual coding method %d", method)
+	}
+	escape := uint64(1)<<paramBits - 1
+
+	partitionOrder, err := br.readBits(4)
+	if err != nil {
+		return err
+	}
+	partitions := 1 << partitionOrder
+	partitionSize := len(samples) >> partitionOrder
+	if partitionSize<<partitionOrder != len(samples) || partitionSize < order {
+		return fmt.Errorf("invalid partition order %d for block size %d and predictor order %d", partitionOrder, len(samples), order)
+	}
+
+	i := order
+	for p := 0; p < partitions; p++ {
+		end := (p + 1) * partitionSize
+		param, err := br.readBits(paramBits)
+		if err != nil {
+			return err
+		}
+		if param == escape {
+			n, err := br.readBits(5)
+			if err != nil {
+				return err
+			}
+			if err = decodeVerbatim(br, samples[i:end], uint(n)); err != nil {
+				return err
+			}
+			i = end
+			continue
+		}
+		for ; i < end; i++ {
+			q, err := br.readUnary()
+			if err != nil {
+				return err
+			}
+			r, err := br.readBits(uint(param))
+			if err != nil {
+				return err
+			}
+			u := q<<param | r
+			samples[i] = int32(u>>1) ^ -int32(u&1) // zigzag decoding
+		}
+	}
+	return nil
+}
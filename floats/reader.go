@@ -15,11 +15,11 @@
 package floats
 
 type Reader struct {
-	slice    []float32
+	slice    []float64
 	position int
 }
 
-func NewReader(slice []float32) *Reader {
+func NewReader(slice []float64) *Reader {
 	return &Reader{
 		slice:    slice,
 		position: 0,
@@ -30,7 +30,12 @@ func (r *Reader) HasNext() bool {
 	return r.position < len(r.slice)
 }
 
-func (r *Reader) Next() float32 {
+// Remaining returns the amount of values not read yet.
+func (r *Reader) Remaining() int {
+	return len(r.slice) - r.position
+}
+
+func (r *Reader) Next() float64 {
 	v := r.slice[r.position]
 	r.position += 1
 	return v
@@ -15,17 +15,17 @@
 package floats
 
 type Writer struct {
-	slice []float32
+	slice []float64
 }
 
 func NewWriter() *Writer {
 	return &Writer{}
 }
 
-func (r *Writer) Write(v float32) {
+func (r *Writer) Write(v float64) {
 	r.slice = append(r.slice, v)
 }
 
-func (r *Writer) Floats() []float32 {
+func (r *Writer) Floats() []float64 {
 	return r.slice
 }
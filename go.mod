@@ -20,6 +20,7 @@ require (
 	github.com/gordonklaus/portaudio v0.0.0-20230709114228-aafa478834f5
 	github.com/nlpodyssey/gopickle v0.2.0
 	github.com/nlpodyssey/spago v1.1.1-0.20231104144211-93ae88e239d8
+	golang.org/x/sys v0.14.0
 )
 
 require github.com/google/flatbuffers v23.5.26+incompatible // indirect
@@ -17,7 +17,11 @@ package liveplay
 import (
 	"fmt"
 	"github.com/gordonklaus/portaudio"
-	"github.com/nlpodyssey/waveny/models/realtime/wavenet"
+	"github.com/nlpodyssey/waveny/models/realtime"
+	_ "github.com/nlpodyssey/waveny/models/realtime/convnet"
+	_ "github.com/nlpodyssey/waveny/models/realtime/linear"
+	_ "github.com/nlpodyssey/waveny/models/realtime/lstm"
+	_ "github.com/nlpodyssey/waveny/models/realtime/wavenet"
 	"os"
 	"os/signal"
 )
@@ -25,20 +29,31 @@ import (
 const (
 	numInputChannels  = 1
 	numOutputChannels = 1
-	sampleRate        = 48000
 	framesPerBuffer   = 256
 )
 
 type Config struct {
 	ModelDataPath   string
 	FramesPerBuffer int
+	// Workers is the amount of goroutines processing each step of the
+	// model: see realtime.WithWorkers.
+	Workers int
 }
 
 func Run(config Config) (err error) {
-	model, err := wavenet.LoadFromJSONModelDataFile(config.ModelDataPath)
+	model, err := realtime.LoadFromJSONModelDataFile(config.ModelDataPath, realtime.WithWorkers(config.Workers))
 	if err != nil {
 		return err
 	}
+	// Deferred first, so that the workers are stopped after the stream.
+	defer model.Close()
+
+	fpb := config.FramesPerBuffer
+	if fpb <= 0 {
+		fpb = framesPerBuffer
+	}
+	// Memory is allocated upfront, to avoid GC pauses in the callback.
+	model.SetMaxBlockSize(fpb)
 
 	if err = portaudio.Initialize(); err != nil {
 		return fmt.Errorf("failed to initialize PortAudio: %w", err)
@@ -57,8 +72,8 @@ func Run(config Config) (err error) {
 	stream, err := portaudio.OpenDefaultStream(
 		numInputChannels,
 		numOutputChannels,
-		sampleRate,
-		framesPerBuffer,
+		float64(model.SampleRate()),
+		fpb,
 		process,
 	)
 	if err != nil {
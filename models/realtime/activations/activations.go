@@ -17,19 +17,43 @@ package activations
 import (
 	"fmt"
 	"github.com/nlpodyssey/waveny/models/realtime/mat"
+	"strings"
 )
 
-type Activation interface {
-	Apply(matrix mat.Matrix)
+type Activation[T mat.Float] interface {
+	Apply(matrix mat.Matrix[T])
 }
 
-func New(name string) Activation {
-	switch name {
-	case "Sigmoid":
-		return NewSigmoid()
-	case "Tanh":
-		return NewTanh()
+// Default parameters of the parametric activations, as in NAM.
+const (
+	defaultLeakyReLUSlope     = 0.01
+	defaultLeakyHardtanhSlope = 0.01
+)
+
+// New returns the activation with the given name, matched regardless of
+// case, so that both NAM names (such as "Hardtanh") and SpaGO names (such
+// as "HardTanh") are accepted.
+func New[T mat.Float](name string) (Activation[T], error) {
+	switch strings.ToLower(name) {
+	case "tanh":
+		return NewTanh[T](), nil
+	case "fasttanh":
+		return NewFasttanh[T](), nil
+	case "sigmoid":
+		return NewSigmoid[T](), nil
+	case "relu":
+		return NewReLU[T](), nil
+	case "leakyrelu":
+		return NewLeakyReLU[T](defaultLeakyReLUSlope), nil
+	case "hardtanh":
+		return NewHardtanh[T](-1, 1), nil
+	case "leakyhardtanh":
+		return NewLeakyHardtanh[T](-1, 1, defaultLeakyHardtanhSlope, defaultLeakyHardtanhSlope), nil
+	case "silu", "swish":
+		return NewSiLU[T](), nil
+	case "softsign":
+		return NewSoftsign[T](), nil
 	default:
-		panic(fmt.Sprintf("unknown or unsupported activation %q", name))
+		return nil, fmt.Errorf("unknown or unsupported activation %q", name)
 	}
 }
@@ -0,0 +1,80 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package activations
+
+import (
+	"github.com/nlpodyssey/waveny/models/realtime/mat"
+	"math"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	inputs := []float32{-3, -1.5, -0.5, 0, 0.25, 1, 2.5}
+	sigmoid := func(x float64) float64 { return 1 / (1 + math.Exp(-x)) }
+	testCases := []struct {
+		names    []string
+		expected func(x float64) float64
+		delta    float64
+	}{
+		{[]string{"Tanh"}, math.Tanh, 1e-6},
+		{[]string{"Fasttanh"}, math.Tanh, 5e-4},
+		{[]string{"Sigmoid"}, sigmoid, 1e-6},
+		{[]string{"ReLU", "relu"}, func(x float64) float64 { return max(x, 0) }, 0},
+		{[]string{"LeakyReLU"}, func(x float64) float64 {
+			if x < 0 {
+				return 0.01 * x
+			}
+			return x
+		}, 1e-7},
+		{[]string{"Hardtanh", "HardTanh"}, func(x float64) float64 { return min(max(x, -1), 1) }, 0},
+		{[]string{"LeakyHardtanh"}, func(x float64) float64 {
+			switch {
+			case x < -1:
+				return -1 + 0.01*(x+1)
+			case x > 1:
+				return 1 + 0.01*(x-1)
+			default:
+				return x
+			}
+		}, 1e-7},
+		{[]string{"SiLU", "Swish"}, func(x float64) float64 { return x * sigmoid(x) }, 1e-6},
+		{[]string{"Softsign"}, func(x float64) float64 { return x / (1 + math.Abs(x)) }, 1e-7},
+	}
+	for _, tc := range testCases {
+		for _, name := range tc.names {
+			activation, err := New[float32](name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := mat.NewMatrixFromSlices([][]float32{inputs, inputs})
+			activation.Apply(m.ViewMiddleColumns(1, len(inputs)-1))
+			for r := 0; r < m.Rows(); r++ {
+				if m.Get(r, 0) != inputs[0] {
+					t.Errorf("%s: column outside of the view was modified", name)
+				}
+				for j := 1; j < len(inputs); j++ {
+					expected := tc.expected(float64(inputs[j]))
+					if actual := float64(m.Get(r, j)); math.Abs(actual-expected) > tc.delta {
+						t.Errorf("%s(%g): expected %g, actual %g", name, inputs[j], expected, actual)
+					}
+				}
+			}
+		}
+	}
+
+	if _, err := New[float32]("Unknown"); err == nil {
+		t.Error("expected error for unknown activation")
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package activations
+
+import "github.com/nlpodyssey/waveny/models/realtime/mat"
+
+// Fasttanh is the rational approximation of tanh used by NAM, cheaper
+// than the exact function, with an absolute error below 5e-4.
+type Fasttanh[T mat.Float] struct{}
+
+func NewFasttanh[T mat.Float]() Fasttanh[T] {
+	return Fasttanh[T]{}
+}
+
+func (f Fasttanh[T]) Apply(m mat.Matrix[T]) {
+	m.ApplyInPlace(fastTanh[T])
+}
+
+func fastTanh[T mat.Float](x T) T {
+	ax := abs(x)
+	x2 := x * x
+	return x * (2.45550750702956 + 2.45550750702956*ax + (0.893229853513558+0.821226666969744*ax)*x2) /
+		(2.44506634652299 + (2.44506634652299+x2)*abs(x+0.814642734961073*x*ax))
+}
@@ -0,0 +1,54 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package activations
+
+import "github.com/nlpodyssey/waveny/models/realtime/mat"
+
+// Hardtanh clamps values to the [min, max] range.
+type Hardtanh[T mat.Float] struct {
+	min, max T
+}
+
+func NewHardtanh[T mat.Float](min, max T) Hardtanh[T] {
+	return Hardtanh[T]{min: min, max: max}
+}
+
+func (h Hardtanh[T]) Apply(m mat.Matrix[T]) {
+	m.ClampInPlace(h.min, h.max)
+}
+
+// LeakyHardtanh is like Hardtanh, but values out of the [min, max] range
+// keep growing past the bounds, with the given slopes.
+type LeakyHardtanh[T mat.Float] struct {
+	min, max           T
+	minSlope, maxSlope T
+}
+
+func NewLeakyHardtanh[T mat.Float](min, max, minSlope, maxSlope T) LeakyHardtanh[T] {
+	return LeakyHardtanh[T]{min: min, max: max, minSlope: minSlope, maxSlope: maxSlope}
+}
+
+func (h LeakyHardtanh[T]) Apply(m mat.Matrix[T]) {
+	m.ApplyInPlace(func(v T) T {
+		switch {
+		case v < h.min:
+			return h.min + (v-h.min)*h.minSlope
+		case v > h.max:
+			return h.max + (v-h.max)*h.maxSlope
+		default:
+			return v
+		}
+	})
+}
@@ -0,0 +1,46 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package activations
+
+import "github.com/nlpodyssey/waveny/models/realtime/mat"
+
+type ReLU[T mat.Float] struct{}
+
+func NewReLU[T mat.Float]() ReLU[T] {
+	return ReLU[T]{}
+}
+
+func (r ReLU[T]) Apply(m mat.Matrix[T]) {
+	m.ReLUInPlace()
+}
+
+// LeakyReLU scales negative values by the slope, instead of zeroing them.
+type LeakyReLU[T mat.Float] struct {
+	slope T
+}
+
+func NewLeakyReLU[T mat.Float](slope T) LeakyReLU[T] {
+	return LeakyReLU[T]{slope: slope}
+}
+
+func (r LeakyReLU[T]) Apply(m mat.Matrix[T]) {
+	slope := r.slope
+	m.ApplyInPlace(func(v T) T {
+		if v < 0 {
+			return v * slope
+		}
+		return v
+	})
+}
@@ -16,12 +16,12 @@ package activations
 
 import "github.com/nlpodyssey/waveny/models/realtime/mat"
 
-type Sigmoid struct{}
+type Sigmoid[T mat.Float] struct{}
 
-func NewSigmoid() Sigmoid {
-	return Sigmoid{}
+func NewSigmoid[T mat.Float]() Sigmoid[T] {
+	return Sigmoid[T]{}
 }
 
-func (t Sigmoid) Apply(m mat.Matrix) {
+func (t Sigmoid[T]) Apply(m mat.Matrix[T]) {
 	m.SigmoidInPlace()
 }
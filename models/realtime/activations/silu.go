@@ -0,0 +1,33 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package activations
+
+import (
+	"github.com/nlpodyssey/waveny/models/realtime/mat"
+	"math"
+)
+
+// SiLU (also known as Swish) is x * sigmoid(x).
+type SiLU[T mat.Float] struct{}
+
+func NewSiLU[T mat.Float]() SiLU[T] {
+	return SiLU[T]{}
+}
+
+func (s SiLU[T]) Apply(m mat.Matrix[T]) {
+	m.ApplyInPlace(func(v T) T {
+		return v / T(1+math.Exp(float64(-v)))
+	})
+}
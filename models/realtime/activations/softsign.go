@@ -0,0 +1,37 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package activations
+
+import "github.com/nlpodyssey/waveny/models/realtime/mat"
+
+// Softsign is x / (1 + |x|).
+type Softsign[T mat.Float] struct{}
+
+func NewSoftsign[T mat.Float]() Softsign[T] {
+	return Softsign[T]{}
+}
+
+func (s Softsign[T]) Apply(m mat.Matrix[T]) {
+	m.ApplyInPlace(func(v T) T {
+		return v / (1 + abs(v))
+	})
+}
+
+func abs[T mat.Float](v T) T {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -16,12 +16,12 @@ package activations
 
 import "github.com/nlpodyssey/waveny/models/realtime/mat"
 
-type Tanh struct{}
+type Tanh[T mat.Float] struct{}
 
-func NewTanh() Tanh {
-	return Tanh{}
+func NewTanh[T mat.Float]() Tanh[T] {
+	return Tanh[T]{}
 }
 
-func (t Tanh) Apply(m mat.Matrix) {
+func (t Tanh[T]) Apply(m mat.Matrix[T]) {
 	m.TanhInPlace()
 }
@@ -0,0 +1,120 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package buffer implements the input buffer of a causal convolution,
+// keeping the history of past frames needed across processed blocks.
+package buffer
+
+import "github.com/nlpodyssey/waveny/models/realtime/mat"
+
+// A Buffer holds frames as columns, in a ring buffer of capacity frames,
+// as large as the history plus the largest block. Like the layer buffers of
+// WaveNet, the ring is stored twice, in consecutive columns, so that the
+// history columns preceding Start, initially zero, and the columns of the
+// frames being processed are always contiguous: new frames are copied to
+// the other copy when advancing. The position is the index of the next
+// frame in the first copy.
+type Buffer struct {
+	matrix   mat.Matrix[float32]
+	history  int
+	capacity int
+	position int
+}
+
+// New creates a new Buffer with the given rows, keeping the given amount of
+// past frames.
+func New(rows, history int) *Buffer {
+	capacity := history + 1
+	return &Buffer{
+		matrix:   mat.NewMatrix[float32](rows, 2*capacity),
+		history:  history,
+		capacity: capacity,
+		position: history,
+	}
+}
+
+// Reserve grows the buffer, if needed, so that blocks of up to numFrames
+// frames can be prepared without allocating.
+func (b *Buffer) Reserve(numFrames int) {
+	capacity := b.history + numFrames
+	if capacity <= b.capacity {
+		return
+	}
+	m := mat.NewMatrix[float32](b.matrix.Rows(), 2*capacity)
+	if b.history > 0 {
+		mat.Copy(
+			m.ViewMiddleColumns(0, b.history),
+			b.matrix.ViewMiddleColumns(b.Start()-b.history, b.history),
+		)
+	}
+	b.matrix = m
+	b.capacity = capacity
+	b.position = b.history
+	b.mirror(0, b.history)
+}
+
+// Prepare makes room for numFrames new frames, growing the buffer if
+// needed. It doesn't copy any frame otherwise.
+func (b *Buffer) Prepare(numFrames int) {
+	b.Reserve(numFrames)
+}
+
+// Matrix returns the whole buffer.
+func (b *Buffer) Matrix() mat.Matrix[float32] {
+	return b.matrix
+}
+
+// Start returns the column of the first frame being processed: the one of
+// the position, in the first copy, unless the history would precede it.
+func (b *Buffer) Start() int {
+	if b.position < b.history {
+		return b.position + b.capacity
+	}
+	return b.position
+}
+
+// Frames returns a view of the columns of the frames being processed.
+func (b *Buffer) Frames(numFrames int) mat.Matrix[float32] {
+	return b.matrix.ViewMiddleColumns(b.Start(), numFrames)
+}
+
+// Advance moves past the processed frames, which become history.
+func (b *Buffer) Advance(numFrames int) {
+	b.mirror(b.Start(), numFrames)
+	b.position = (b.position + numFrames) % b.capacity
+}
+
+// mirror copies numFrames columns, from the given one, to the other copy of
+// the ring buffer.
+func (b *Buffer) mirror(fromColumn, numFrames int) {
+	if n := min(numFrames, b.capacity-fromColumn); n > 0 {
+		mat.Copy(
+			b.matrix.ViewMiddleColumns(fromColumn+b.capacity, n),
+			b.matrix.ViewMiddleColumns(fromColumn, n),
+		)
+		fromColumn, numFrames = fromColumn+n, numFrames-n
+	}
+	if numFrames > 0 {
+		mat.Copy(
+			b.matrix.ViewMiddleColumns(fromColumn-b.capacity, numFrames),
+			b.matrix.ViewMiddleColumns(fromColumn, numFrames),
+		)
+	}
+}
+
+// Reset clears the history.
+func (b *Buffer) Reset() {
+	b.matrix.SetZero()
+	b.position = b.history
+}
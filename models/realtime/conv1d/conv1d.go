@@ -27,38 +27,44 @@ type Config struct {
 	Dilation    int
 }
 
-type Model struct {
-	weight   []mat.Matrix // [kernel](OutChannels, InChannels)
-	bias     mat.Vector
+type Model[T mat.Float] struct {
+	weight   []mat.Matrix[T] // [kernel](OutChannels, InChannels)
+	bias     mat.Vector[T]
 	dilation int
 	hasBias  bool
+	// inputMixin is the weight of an optional 1x1 convolution of a
+	// separate input, fused with the convolution
+	inputMixin mat.Matrix[T]
+	fused      mat.FusedProduct[T]
 }
 
-func New(config Config) *Model {
-	return &Model{
-		weight:   makeWeight(config),
-		bias:     makeBias(config),
+func New[T mat.Float](config Config) *Model[T] {
+	m := &Model[T]{
+		weight:   makeWeight[T](config),
+		bias:     makeBias[T](config),
 		dilation: config.Dilation,
 		hasBias:  config.Bias,
 	}
+	m.setFusedWeights()
+	return m
 }
 
-func makeWeight(config Config) []mat.Matrix {
-	weight := make([]mat.Matrix, config.KernelSize)
+func makeWeight[T mat.Float](config Config) []mat.Matrix[T] {
+	weight := make([]mat.Matrix[T], config.KernelSize)
 	for i := range weight {
-		weight[i] = mat.NewMatrix(config.OutChannels, config.InChannels)
+		weight[i] = mat.NewMatrix[T](config.OutChannels, config.InChannels)
 	}
 	return weight
 }
 
-func makeBias(config Config) mat.Vector {
+func makeBias[T mat.Float](config Config) mat.Vector[T] {
 	if !config.Bias {
-		return mat.Vector{}
+		return mat.Vector[T]{}
 	}
-	return mat.NewVector(config.OutChannels)
+	return mat.NewVector[T](config.OutChannels)
 }
 
-func (m *Model) SetParams(params *floats.Reader) {
+func (m *Model[T]) SetParams(params *floats.Reader) {
 	if len(m.weight) > 0 {
 		outChannels := m.weight[0].Rows()
 		inChannels := m.weight[0].Columns()
@@ -66,7 +72,7 @@ func (m *Model) SetParams(params *floats.Reader) {
 		for i := 0; i < outChannels; i++ {
 			for j := 0; j < inChannels; j++ {
 				for k := range m.weight {
-					m.weight[k].Set(i, j, params.Next())
+					m.weight[k].Set(i, j, T(params.Next()))
 				}
 			}
 		}
@@ -74,62 +80,112 @@ func (m *Model) SetParams(params *floats.Reader) {
 
 	if m.hasBias {
 		for i := 0; i < m.bias.Size(); i++ {
-			m.bias.Set(i, params.Next())
+			m.bias.Set(i, T(params.Next()))
 		}
 	}
+	m.setFusedWeights()
 }
 
-func (m *Model) GetInChannels() int {
+// FoldAffine folds an affine transformation of each output channel, such
+// as a batch normalization, into the weights and the bias: the output of
+// channel i becomes scale[i] * output[i] + shift[i]. A bias is added if the
+// model doesn't have one.
+func (m *Model[T]) FoldAffine(scale, shift mat.Vector[T]) {
+	if !m.hasBias {
+		m.bias = mat.NewVector[T](m.GetOutChannels())
+		m.hasBias = true
+	}
+	for i := 0; i < m.GetOutChannels(); i++ {
+		s := scale.Get(i)
+		for _, w := range m.weight {
+			for j := 0; j < w.Columns(); j++ {
+				w.Set(i, j, w.Get(i, j)*s)
+			}
+		}
+		m.bias.Set(i, m.bias.Get(i)*s+shift.Get(i))
+	}
+	m.setFusedWeights()
+}
+
+func (m *Model[T]) GetInChannels() int {
 	if len(m.weight) == 0 {
 		return 0
 	}
 	return m.weight[0].Columns()
 }
 
-func (m *Model) GetOutChannels() int {
+func (m *Model[T]) GetOutChannels() int {
 	if len(m.weight) == 0 {
 		return 0
 	}
 	return m.weight[0].Rows()
 }
 
-func (m *Model) GetKernelSize() int {
+func (m *Model[T]) GetKernelSize() int {
 	return len(m.weight)
 }
 
-func (m *Model) GetDilation() int {
+func (m *Model[T]) GetDilation() int {
 	return m.dilation
 }
 
-func (m *Model) GetNumParams() int {
+func (m *Model[T]) GetNumParams() int {
 	return m.dilation
 }
 
-func (m *Model) Process(input, output mat.Matrix, inputStartColumn, numColumns, outputStartColumn int) {
-	weight := m.weight
-	kernelSize := len(weight)
-	dilation := m.dilation
-
-	offset := dilation * (1 - kernelSize)
-	mat.Product(
-		weight[0],
-		input.ViewMiddleColumns(inputStartColumn+offset, numColumns),
-		output.ViewMiddleColumns(outputStartColumn, numColumns),
-	)
-
-	for k := 1; k < kernelSize; k++ {
-		offset = dilation * (k + 1 - kernelSize)
-		mat.AddProduct(
-			weight[k],
-			input.ViewMiddleColumns(inputStartColumn+offset, numColumns),
-			output.ViewMiddleColumns(outputStartColumn, numColumns),
-		)
+// SetInputMixin sets the weight of a 1x1 convolution of a separate input,
+// added to the result of ProcessFused. The weight is shared, and must be
+// set again when it changes.
+func (m *Model[T]) SetInputMixin(weight mat.Matrix[T]) {
+	m.inputMixin = weight
+	m.setFusedWeights()
+}
+
+func (m *Model[T]) setFusedWeights() {
+	if len(m.weight) == 0 {
+		return
+	}
+	weights := m.weight
+	if m.inputMixin.Columns() > 0 {
+		weights = append(weights[:len(weights):len(weights)], m.inputMixin)
 	}
+	m.fused.SetWeights(m.bias, weights...)
+}
 
-	if m.hasBias {
-		mat.AddInPlaceColumnWise(
-			output.ViewMiddleColumns(outputStartColumn, numColumns),
-			m.bias,
-		)
+// Process computes the convolution of numColumns columns of the input,
+// from inputStartColumn on, into the output, from outputStartColumn on.
+// The columns preceding inputStartColumn must hold the past input, as far
+// as the receptive field.
+//
+// When the input mixin is set, ProcessFused must be used instead.
+func (m *Model[T]) Process(input, output mat.Matrix[T], inputStartColumn, numColumns, outputStartColumn int) {
+	m.ProcessFused(input, output, inputStartColumn, numColumns, outputStartColumn, mat.Matrix[T]{}, nil)
+}
+
+// ProcessFused is like Process, additionally adding the input mixin of
+// mixinInput, which must have numColumns columns (ignored if the input mixin
+// is not set), and applying the epilogue, if not nil, to the result, in a
+// single pass.
+func (m *Model[T]) ProcessFused(input, output mat.Matrix[T], inputStartColumn, numColumns, outputStartColumn int, mixinInput mat.Matrix[T], epilogue mat.Epilogue[T]) {
+	m.PrepareFused(input, inputStartColumn, numColumns, mixinInput)
+	m.ComputeFused(output, outputStartColumn, numColumns, 0, output.Rows(), epilogue)
+}
+
+// PrepareFused and ComputeFused split ProcessFused, so that separate output
+// rows can be computed concurrently, once prepared: see
+// mat.FusedProduct.ComputeRows.
+func (m *Model[T]) PrepareFused(input mat.Matrix[T], inputStartColumn, numColumns int, mixinInput mat.Matrix[T]) {
+	kernelSize := len(m.weight)
+	for k := range m.weight {
+		offset := m.dilation * (k + 1 - kernelSize)
+		m.fused.AddInput(input, inputStartColumn+offset)
+	}
+	if m.inputMixin.Columns() > 0 {
+		m.fused.AddInput(mixinInput, 0)
 	}
+	m.fused.Prepare(numColumns)
+}
+
+func (m *Model[T]) ComputeFused(output mat.Matrix[T], outputStartColumn, numColumns, fromRow, toRow int, epilogue mat.Epilogue[T]) {
+	m.fused.ComputeRows(output.ViewMiddleColumns(outputStartColumn, numColumns), fromRow, toRow, epilogue)
 }
@@ -0,0 +1,143 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conv1d
+
+import (
+	"fmt"
+	"github.com/nlpodyssey/waveny/floats"
+	"github.com/nlpodyssey/waveny/models/realtime/activations"
+	"github.com/nlpodyssey/waveny/models/realtime/mat"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+type fixture struct {
+	model      *Model[float32]
+	mixin      mat.Matrix[float32]
+	input      mat.Matrix[float32] // past input, followed by the columns to process
+	condition  mat.Matrix[float32]
+	inputStart int
+}
+
+func newFixture(channels, kernelSize, dilation, numColumns int) fixture {
+	rnd := rand.New(rand.NewSource(1))
+	randMatrix := func(rows, columns int) mat.Matrix[float32] {
+		m := mat.NewMatrix[float32](rows, columns)
+		for i := 0; i < rows; i++ {
+			for j := 0; j < columns; j++ {
+				m.Set(i, j, float32(rnd.NormFloat64()*0.3))
+			}
+		}
+		return m
+	}
+
+	model := New[float32](Config{
+		InChannels:  channels,
+		OutChannels: channels,
+		KernelSize:  kernelSize,
+		Bias:        true,
+		Dilation:    dilation,
+	})
+	params := make([]float64, channels*channels*kernelSize+channels)
+	for i := range params {
+		params[i] = rnd.NormFloat64() * 0.3
+	}
+	model.SetParams(floats.NewReader(params))
+
+	inputStart := dilation * (kernelSize - 1)
+	return fixture{
+		model:      model,
+		mixin:      randMatrix(channels, 1),
+		input:      randMatrix(channels, inputStart+numColumns),
+		condition:  randMatrix(1, numColumns),
+		inputStart: inputStart,
+	}
+}
+
+// processSeparately computes the convolution, the input mixin and the
+// activation in separate passes.
+func (f fixture) processSeparately(output, mixinOutput mat.Matrix[float32], withMixin bool) {
+	m := f.model
+	numColumns := output.Columns()
+	kernelSize := len(m.weight)
+	for k, w := range m.weight {
+		offset := m.dilation * (k + 1 - kernelSize)
+		input := f.input.ViewMiddleColumns(f.inputStart+offset, numColumns)
+		if k == 0 {
+			mat.Product(w, input, output)
+		} else {
+			mat.AddProduct(w, input, output)
+		}
+	}
+	mat.AddInPlaceColumnWise(output, m.bias)
+	if withMixin {
+		mat.Product(f.mixin, f.condition, mixinOutput)
+		mat.AddInPlace(output, mixinOutput)
+		output.TanhInPlace()
+	}
+}
+
+func TestProcess(t *testing.T) {
+	for _, numColumns := range []int{1, 7, 64, 300} {
+		f := newFixture(6, 3, 4, numColumns)
+		expected := mat.NewMatrix[float32](6, numColumns)
+		actual := mat.NewMatrix[float32](6, numColumns+2)
+
+		f.processSeparately(expected, mat.Matrix[float32]{}, false)
+		f.model.Process(f.input, actual, f.inputStart, numColumns, 2)
+		assertEqual(t, expected, actual.ViewMiddleColumns(2, numColumns))
+
+		f.processSeparately(expected, mat.NewMatrix[float32](6, numColumns), true)
+		f.model.SetInputMixin(f.mixin)
+		f.model.ProcessFused(f.input, actual, f.inputStart, numColumns, 2, f.condition, activations.NewTanh[float32]())
+		assertEqual(t, expected, actual.ViewMiddleColumns(2, numColumns))
+	}
+}
+
+func assertEqual(t *testing.T, expected, actual mat.Matrix[float32]) {
+	t.Helper()
+	for i := 0; i < expected.Rows(); i++ {
+		for j := 0; j < expected.Columns(); j++ {
+			e, a := expected.Get(i, j), actual.Get(i, j)
+			if math.Abs(float64(e-a)) > 1e-5 {
+				t.Fatalf("(%d, %d): expected %g, actual %g", i, j, e, a)
+			}
+		}
+	}
+}
+
+// BenchmarkProcess compares the fused computation of a layer of the
+// standard NAM WaveNet, with the separate passes.
+func BenchmarkProcess(b *testing.B) {
+	for _, numColumns := range []int{64, 4096} {
+		f := newFixture(16, 3, 64, numColumns)
+		output := mat.NewMatrix[float32](16, numColumns)
+		mixinOutput := mat.NewMatrix[float32](16, numColumns)
+
+		b.Run(fmt.Sprintf("separate/columns=%d", numColumns), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				f.processSeparately(output, mixinOutput, true)
+			}
+		})
+		b.Run(fmt.Sprintf("fused/columns=%d", numColumns), func(b *testing.B) {
+			f.model.SetInputMixin(f.mixin)
+			tanh := activations.NewTanh[float32]()
+			for i := 0; i < b.N; i++ {
+				f.model.ProcessFused(f.input, output, f.inputStart, numColumns, 0, f.condition, tanh)
+			}
+		})
+	}
+}
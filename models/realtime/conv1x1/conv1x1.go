@@ -25,43 +25,65 @@ type Config struct {
 	Bias        bool
 }
 
-type Model struct {
-	weight  mat.Matrix
-	bias    mat.Vector
+type Model[T mat.Float] struct {
+	weight  mat.Matrix[T]
+	bias    mat.Vector[T]
 	hasBias bool
 }
 
-func New(config Config) *Model {
-	c := &Model{
-		weight:  mat.NewMatrix(config.OutChannels, config.InChannels),
+func New[T mat.Float](config Config) *Model[T] {
+	c := &Model[T]{
+		weight:  mat.NewMatrix[T](config.OutChannels, config.InChannels),
 		hasBias: config.Bias,
 	}
 	if config.Bias {
-		c.bias = mat.NewVector(config.OutChannels)
+		c.bias = mat.NewVector[T](config.OutChannels)
 	}
 	return c
 }
 
-func (m *Model) GetOutChannels() int {
+func (m *Model[T]) GetInChannels() int {
+	return m.weight.Columns()
+}
+
+func (m *Model[T]) GetOutChannels() int {
 	return m.weight.Rows()
 }
 
-func (m *Model) SetParams(params *floats.Reader) {
+func (m *Model[T]) GetWeight() mat.Matrix[T] {
+	return m.weight
+}
+
+func (m *Model[T]) SetParams(params *floats.Reader) {
 	for i := 0; i < m.weight.Rows(); i++ {
 		for j := 0; j < m.weight.Columns(); j++ {
-			m.weight.Set(i, j, params.Next())
+			m.weight.Set(i, j, T(params.Next()))
 		}
 	}
 	if m.hasBias {
 		for i := 0; i < m.bias.Size(); i++ {
-			m.bias.Set(i, params.Next())
+			m.bias.Set(i, T(params.Next()))
 		}
 	}
 }
 
-func (m *Model) Process(input, output mat.Matrix) {
+func (m *Model[T]) Process(input, output mat.Matrix[T]) {
 	mat.Product(m.weight, input, output)
 	if m.hasBias {
 		mat.AddInPlaceColumnWise(output, m.bias)
 	}
 }
+
+// ProcessRows is like Process, for the output rows from fromRow to toRow,
+// excluded, only.
+func (m *Model[T]) ProcessRows(input, output mat.Matrix[T], fromRow, toRow int) {
+	numRows := toRow - fromRow
+	if numRows <= 0 {
+		return
+	}
+	outputRows := output.ViewMiddleRows(fromRow, numRows)
+	mat.Product(m.weight.ViewMiddleRows(fromRow, numRows), input, outputRows)
+	if m.hasBias {
+		mat.AddInPlaceColumnWise(outputRows, mat.Vector[T]{Matrix: m.bias.ViewMiddleRows(fromRow, numRows)})
+	}
+}
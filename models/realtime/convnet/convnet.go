@@ -0,0 +1,260 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package convnet implements the NAM ConvNet model: a stack of dilated
+// convolutions with kernel size 2, each one optionally followed by a batch
+// normalization, and by an activation, then a linear head.
+//
+// Batch normalizations are folded into the convolutions at load time.
+package convnet
+
+import (
+	"fmt"
+	"github.com/nlpodyssey/waveny/floats"
+	"github.com/nlpodyssey/waveny/models/realtime"
+	"github.com/nlpodyssey/waveny/models/realtime/activations"
+	"github.com/nlpodyssey/waveny/models/realtime/buffer"
+	"github.com/nlpodyssey/waveny/models/realtime/conv1d"
+	"github.com/nlpodyssey/waveny/models/realtime/conv1x1"
+	"github.com/nlpodyssey/waveny/models/realtime/mat"
+	"math"
+)
+
+type Config struct {
+	Channels   int    `json:"channels"`
+	Dilations  []int  `json:"dilations"`
+	BatchNorm  bool   `json:"batchnorm"`
+	Activation string `json:"activation"`
+}
+
+// kernelSize is the kernel size of all block convolutions.
+const kernelSize = 2
+
+type Model struct {
+	blocks     []*block
+	batchNorm  bool
+	head       *conv1x1.Model[float32]
+	headInput  mat.Matrix[float32] // output of the last block
+	headOutput mat.Matrix[float32]
+	sampleRate int
+
+	// headInput and headOutput are views of these
+	headInputData  mat.Matrix[float32]
+	headOutputData mat.Matrix[float32]
+}
+
+type block struct {
+	conv       *conv1d.Model[float32]
+	input      *buffer.Buffer
+	activation activations.Activation[float32]
+}
+
+// New creates a new ConvNet Model, reading the parameters in NAM order.
+// For each block: the convolution weights, its bias if there is no batch
+// normalization, otherwise the batch normalization running mean, running
+// variance, weight, bias and epsilon. Then, the head weights and bias.
+func New(config Config, params *floats.Reader) (*Model, error) {
+	if config.Channels < 1 {
+		return nil, fmt.Errorf("invalid channels %d", config.Channels)
+	}
+	if len(config.Dilations) == 0 {
+		return nil, fmt.Errorf("expected at least one dilation")
+	}
+
+	m := &Model{
+		blocks:    make([]*block, len(config.Dilations)),
+		batchNorm: config.BatchNorm,
+		head: conv1x1.New[float32](conv1x1.Config{
+			InChannels:  config.Channels,
+			OutChannels: 1,
+			Bias:        true,
+		}),
+		sampleRate: realtime.DefaultSampleRate,
+
+		headInputData:  mat.NewMatrix[float32](config.Channels, 0),
+		headOutputData: mat.NewMatrix[float32](1, 0),
+	}
+	inChannels := 1
+	for i, dilation := range config.Dilations {
+		if dilation < 1 {
+			return nil, fmt.Errorf("invalid dilation %d", dilation)
+		}
+		activation, err := activations.New[float32](config.Activation)
+		if err != nil {
+			return nil, err
+		}
+		m.blocks[i] = &block{
+			conv: conv1d.New[float32](conv1d.Config{
+				InChannels:  inChannels,
+				OutChannels: config.Channels,
+				KernelSize:  kernelSize,
+				Bias:        !config.BatchNorm,
+				Dilation:    dilation,
+			}),
+			input:      buffer.New(inChannels, (kernelSize-1)*dilation),
+			activation: activation,
+		}
+		inChannels = config.Channels
+	}
+
+	if err := m.SetParams(params); err != nil {
+		return nil, err
+	}
+
+	m.warmUp()
+	return m, nil
+}
+
+func (m *Model) numParams() int {
+	channels := m.head.GetInChannels()
+	n := channels + 1 // head
+	for _, b := range m.blocks {
+		n += kernelSize * b.conv.GetInChannels() * channels
+		if m.batchNorm {
+			n += 4*channels + 1
+		} else {
+			n += channels
+		}
+	}
+	return n
+}
+
+func (m *Model) SetParams(params *floats.Reader) error {
+	if expected, actual := m.numParams(), params.Remaining(); actual < expected {
+		return fmt.Errorf("too few parameters: expected %d, actual %d", expected, actual)
+	}
+	for _, b := range m.blocks {
+		b.conv.SetParams(params)
+		if m.batchNorm {
+			foldBatchNorm(b.conv, params)
+		}
+	}
+	m.head.SetParams(params)
+	if params.HasNext() {
+		return fmt.Errorf("too many parameters")
+	}
+	return nil
+}
+
+// foldBatchNorm reads the batch normalization parameters, and folds them
+// into the convolution.
+func foldBatchNorm(conv *conv1d.Model[float32], params *floats.Reader) {
+	channels := conv.GetOutChannels()
+	read := func() []float32 {
+		v := make([]float32, channels)
+		for i := range v {
+			v[i] = float32(params.Next())
+		}
+		return v
+	}
+	runningMean := read()
+	runningVar := read()
+	weight := read()
+	bias := read()
+	eps := float32(params.Next())
+
+	scale := mat.NewVector[float32](channels)
+	shift := mat.NewVector[float32](channels)
+	for i := 0; i < channels; i++ {
+		s := weight[i] / float32(math.Sqrt(float64(eps+runningVar[i])))
+		scale.Set(i, s)
+		shift.Set(i, bias[i]-s*runningMean[i])
+	}
+	conv.FoldAffine(scale, shift)
+}
+
+// warmUp processes zeros, so that the buffers hold the steady state of a
+// silent input.
+func (m *Model) warmUp() {
+	samples := []float32{0}
+	for i := 0; i < m.ReceptiveField(); i++ {
+		m.Process(samples, samples)
+		m.Finalize(1)
+		samples[0] = 0
+	}
+}
+
+// Latency is always zero: the model is causal.
+func (m *Model) Latency() int {
+	return 0
+}
+
+func (m *Model) SampleRate() int {
+	return m.sampleRate
+}
+
+// Close does nothing, since the model starts no goroutines.
+func (m *Model) Close() {}
+
+func (m *Model) ReceptiveField() int {
+	receptiveField := 1
+	for _, b := range m.blocks {
+		receptiveField += (kernelSize - 1) * b.conv.GetDilation()
+	}
+	return receptiveField
+}
+
+// SetMaxBlockSize allocates the memory needed to process blocks of up to
+// maxBlockSize frames, so that processing them doesn't allocate. Larger
+// blocks can be processed anyway, allocating more memory.
+func (m *Model) SetMaxBlockSize(maxBlockSize int) {
+	for _, b := range m.blocks {
+		b.input.Reserve(maxBlockSize)
+	}
+	mat.ResizeView(&m.headInputData, maxBlockSize)
+	mat.ResizeView(&m.headOutputData, maxBlockSize)
+}
+
+func (m *Model) Process(input, output []float32) {
+	numFrames := len(input)
+	for _, b := range m.blocks {
+		b.input.Prepare(numFrames)
+	}
+	m.headInput = mat.ResizeView(&m.headInputData, numFrames)
+	m.headOutput = mat.ResizeView(&m.headOutputData, numFrames)
+
+	frames := m.blocks[0].input.Frames(numFrames)
+	for j, v := range input {
+		frames.Set(0, j, v)
+	}
+
+	for i, b := range m.blocks {
+		blockOutput, outputStart := m.headInput, 0
+		if i+1 < len(m.blocks) {
+			next := m.blocks[i+1].input
+			blockOutput, outputStart = next.Matrix(), next.Start()
+		}
+		b.conv.Process(b.input.Matrix(), blockOutput, b.input.Start(), numFrames, outputStart)
+		b.activation.Apply(blockOutput.ViewMiddleColumns(outputStart, numFrames))
+	}
+
+	m.head.Process(m.headInput, m.headOutput)
+	for j := range output {
+		output[j] = m.headOutput.Get(0, j)
+	}
+}
+
+func (m *Model) Finalize(numFrames int) {
+	for _, b := range m.blocks {
+		b.input.Advance(numFrames)
+	}
+}
+
+// Reset restores the state of a silent input.
+func (m *Model) Reset() {
+	for _, b := range m.blocks {
+		b.input.Reset()
+	}
+	m.warmUp()
+}
@@ -0,0 +1,230 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package convnet
+
+import (
+	"github.com/nlpodyssey/waveny/floats"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// torchBlock holds the parameters of a PyTorch ConvNet block, with a
+// separate batch normalization, as a reference implementation.
+type torchBlock struct {
+	dilation int
+	weight   [][][2]float64 // out x in x kernel
+	bias     []float64      // nil with batch normalization
+	// Batch normalization, if any.
+	runningMean, runningVar, bnWeight, bnBias []float64
+	eps                                       float64
+}
+
+type torchConvNet struct {
+	blocks     []*torchBlock
+	headWeight []float64
+	headBias   float64
+}
+
+func newTorchConvNet(rnd *rand.Rand, config Config) *torchConvNet {
+	t := &torchConvNet{
+		headWeight: randVector(rnd, config.Channels),
+		headBias:   rnd.Float64() - 0.5,
+	}
+	inChannels := 1
+	for _, dilation := range config.Dilations {
+		b := &torchBlock{
+			dilation: dilation,
+			weight:   make([][][2]float64, config.Channels),
+		}
+		for i := range b.weight {
+			b.weight[i] = make([][2]float64, inChannels)
+			for j := range b.weight[i] {
+				b.weight[i][j] = [2]float64{rnd.Float64() - 0.5, rnd.Float64() - 0.5}
+			}
+		}
+		if config.BatchNorm {
+			b.runningMean = randVector(rnd, config.Channels)
+			b.runningVar = randVector(rnd, config.Channels)
+			for i := range b.runningVar {
+				b.runningVar[i] += 1
+			}
+			b.bnWeight = randVector(rnd, config.Channels)
+			b.bnBias = randVector(rnd, config.Channels)
+			b.eps = 1e-5
+		} else {
+			b.bias = randVector(rnd, config.Channels)
+		}
+		t.blocks = append(t.blocks, b)
+		inChannels = config.Channels
+	}
+	return t
+}
+
+func randVector(rnd *rand.Rand, size int) []float64 {
+	v := make([]float64, size)
+	for i := range v {
+		v[i] = rnd.Float64() - 0.5
+	}
+	return v
+}
+
+// exportWeights returns the parameters in NAM order.
+func (t *torchConvNet) exportWeights() []float64 {
+	w := floats.NewWriter()
+	write := func(values []float64) {
+		for _, v := range values {
+			w.Write(v)
+		}
+	}
+	for _, b := range t.blocks {
+		for _, row := range b.weight {
+			for _, kernel := range row {
+				write(kernel[:])
+			}
+		}
+		if b.runningMean == nil {
+			write(b.bias)
+			continue
+		}
+		write(b.runningMean)
+		write(b.runningVar)
+		write(b.bnWeight)
+		write(b.bnBias)
+		w.Write(b.eps)
+	}
+	write(t.headWeight)
+	w.Write(t.headBias)
+	return w.Floats()
+}
+
+// forward processes the input preceded by enough zeros to fill the
+// receptive field of the model.
+func (t *torchConvNet) forward(input []float32) []float64 {
+	receptiveField := 1
+	for _, b := range t.blocks {
+		receptiveField += b.dilation
+	}
+	x := [][]float64{make([]float64, receptiveField+len(input))}
+	for i, v := range input {
+		x[0][receptiveField+i] = float64(v)
+	}
+
+	for _, b := range t.blocks {
+		length := len(x[0]) - b.dilation
+		y := make([][]float64, len(b.weight))
+		for i, row := range b.weight {
+			y[i] = make([]float64, length)
+			for n := range y[i] {
+				var v float64
+				for j, kernel := range row {
+					v += kernel[0]*x[j][n] + kernel[1]*x[j][n+b.dilation]
+				}
+				if b.runningMean == nil {
+					v += b.bias[i]
+				} else {
+					v = (v-b.runningMean[i])/math.Sqrt(b.runningVar[i]+b.eps)*b.bnWeight[i] + b.bnBias[i]
+				}
+				y[i][n] = math.Tanh(v)
+			}
+		}
+		x = y
+	}
+
+	output := make([]float64, len(input))
+	offset := len(x[0]) - len(input)
+	for n := range output {
+		v := t.headBias
+		for i, w := range t.headWeight {
+			v += w * x[i][offset+n]
+		}
+		output[n] = v
+	}
+	return output
+}
+
+func TestConvNet(t *testing.T) {
+	for _, config := range []Config{
+		{Channels: 4, Dilations: []int{1, 2, 4, 8}, BatchNorm: false, Activation: "Tanh"},
+		{Channels: 3, Dilations: []int{1, 3, 9}, BatchNorm: true, Activation: "Tanh"},
+	} {
+		rnd := rand.New(rand.NewSource(1))
+		reference := newTorchConvNet(rnd, config)
+		model, err := New(config, floats.NewReader(reference.exportWeights()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		input := make([]float32, 300)
+		for i := range input {
+			input[i] = float32(math.Sin(float64(i) / 7))
+		}
+		expected := reference.forward(input)
+		actual := make([]float32, len(input))
+		// Blocks of varying size wrap the input buffers around, and grow
+		// them while holding history.
+		blockSizes := []int{64, 1, 7, 100, 3}
+		process := func() {
+			for from, i := 0, 0; from < len(input); i++ {
+				to := min(from+blockSizes[i%len(blockSizes)], len(input))
+				model.Process(input[from:to], actual[from:to])
+				model.Finalize(to - from)
+				from = to
+			}
+		}
+		process()
+		assertClose(t, expected, actual)
+
+		model.Reset()
+		process()
+		assertClose(t, expected, actual)
+
+		weights := reference.exportWeights()
+		if _, err = New(config, floats.NewReader(weights[1:])); err == nil {
+			t.Error("expected error with too few parameters")
+		}
+		if _, err = New(config, floats.NewReader(append(weights, 0))); err == nil {
+			t.Error("expected error with too many parameters")
+		}
+	}
+}
+
+func assertClose(t *testing.T, expected []float64, actual []float32) {
+	t.Helper()
+	for i, v := range expected {
+		if math.Abs(float64(actual[i])-v) > 1e-5 {
+			t.Fatalf("sample %d: expected %g, actual %g", i, v, actual[i])
+		}
+	}
+}
+
+func TestProcessAllocations(t *testing.T) {
+	config := Config{Channels: 4, Dilations: []int{1, 2, 4, 8}, BatchNorm: true, Activation: "Tanh"}
+	model, err := New(config, floats.NewReader(newTorchConvNet(rand.New(rand.NewSource(1)), config).exportWeights()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	model.SetMaxBlockSize(256)
+	input, output := make([]float32, 256), make([]float32, 256)
+	allocs := testing.AllocsPerRun(10, func() {
+		for _, n := range []int{1, 256, 7, 64, 255, 3} {
+			model.Process(input[:n], output[:n])
+			model.Finalize(n)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, actual %g", allocs)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package linear implements the NAM Linear model: a single causal
+// convolution over the most recent input samples.
+package linear
+
+import (
+	"fmt"
+	"github.com/nlpodyssey/waveny/floats"
+	"github.com/nlpodyssey/waveny/models/realtime"
+	"github.com/nlpodyssey/waveny/models/realtime/buffer"
+	"github.com/nlpodyssey/waveny/models/realtime/conv1d"
+	"github.com/nlpodyssey/waveny/models/realtime/mat"
+)
+
+type Config struct {
+	ReceptiveField int  `json:"receptive_field"`
+	Bias           bool `json:"bias"`
+}
+
+type Model struct {
+	conv       *conv1d.Model[float32]
+	input      *buffer.Buffer
+	output     mat.Matrix[float32] // view of outputData
+	outputData mat.Matrix[float32]
+	sampleRate int
+}
+
+// New creates a new Linear Model, reading the kernel weights, from the
+// oldest sample to the current one, followed by the bias, if any.
+func New(config Config, params *floats.Reader) (*Model, error) {
+	if config.ReceptiveField < 1 {
+		return nil, fmt.Errorf("invalid receptive field %d", config.ReceptiveField)
+	}
+	m := &Model{
+		conv: conv1d.New[float32](conv1d.Config{
+			InChannels:  1,
+			OutChannels: 1,
+			KernelSize:  config.ReceptiveField,
+			Bias:        config.Bias,
+			Dilation:    1,
+		}),
+		input:      buffer.New(1, config.ReceptiveField-1),
+		outputData: mat.NewMatrix[float32](1, 0),
+		sampleRate: realtime.DefaultSampleRate,
+	}
+
+	expected := config.ReceptiveField
+	if config.Bias {
+		expected++
+	}
+	if actual := params.Remaining(); actual < expected {
+		return nil, fmt.Errorf("too few parameters: expected %d, actual %d", expected, actual)
+	}
+	m.conv.SetParams(params)
+	if params.HasNext() {
+		return nil, fmt.Errorf("too many parameters")
+	}
+	return m, nil
+}
+
+// SetMaxBlockSize allocates the memory needed to process blocks of up to
+// maxBlockSize frames, so that processing them doesn't allocate. Larger
+// blocks can be processed anyway, allocating more memory.
+func (m *Model) SetMaxBlockSize(maxBlockSize int) {
+	m.input.Reserve(maxBlockSize)
+	mat.ResizeView(&m.outputData, maxBlockSize)
+}
+
+func (m *Model) Process(input, output []float32) {
+	numFrames := len(input)
+	m.input.Prepare(numFrames)
+	frames := m.input.Frames(numFrames)
+	for j, v := range input {
+		frames.Set(0, j, v)
+	}
+
+	m.output = mat.ResizeView(&m.outputData, numFrames)
+	m.conv.Process(m.input.Matrix(), m.output, m.input.Start(), numFrames, 0)
+	for j := range output {
+		output[j] = m.output.Get(0, j)
+	}
+}
+
+func (m *Model) Finalize(numFrames int) {
+	m.input.Advance(numFrames)
+}
+
+// Reset clears the input history.
+func (m *Model) Reset() {
+	m.input.Reset()
+}
+
+// Latency is always zero: the model is causal.
+func (m *Model) Latency() int {
+	return 0
+}
+
+func (m *Model) ReceptiveField() int {
+	return m.conv.GetKernelSize()
+}
+
+func (m *Model) SampleRate() int {
+	return m.sampleRate
+}
+
+// Close does nothing, since the model starts no goroutines.
+func (m *Model) Close() {}
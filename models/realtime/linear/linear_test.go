@@ -0,0 +1,97 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package linear
+
+import (
+	"github.com/nlpodyssey/waveny/floats"
+	"math"
+	"testing"
+)
+
+func TestLinear(t *testing.T) {
+	// The last weight applies to the current sample.
+	weights := []float64{0.5, -0.25, 2, 1, 0.125}
+	model, err := New(Config{ReceptiveField: 4, Bias: true}, floats.NewReader(weights))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := make([]float32, 200)
+	for i := range input {
+		input[i] = float32(math.Sin(float64(i) / 5))
+	}
+	expected := make([]float32, len(input))
+	for n := range expected {
+		v := float32(weights[4])
+		for k := 0; k < 4; k++ {
+			if i := n - 3 + k; i >= 0 {
+				v += float32(weights[k]) * input[i]
+			}
+		}
+		expected[n] = v
+	}
+
+	actual := make([]float32, len(input))
+	// Blocks of varying size wrap the input buffer around, and grow it
+	// while holding history.
+	blockSizes := []int{7, 1, 30, 2}
+	process := func() {
+		for from, i := 0, 0; from < len(input); i++ {
+			to := min(from+blockSizes[i%len(blockSizes)], len(input))
+			model.Process(input[from:to], actual[from:to])
+			model.Finalize(to - from)
+			from = to
+		}
+	}
+	assertClose := func() {
+		t.Helper()
+		for i, v := range expected {
+			if math.Abs(float64(actual[i]-v)) > 1e-6 {
+				t.Fatalf("sample %d: expected %g, actual %g", i, v, actual[i])
+			}
+		}
+	}
+	process()
+	assertClose()
+
+	model.Reset()
+	process()
+	assertClose()
+
+	if _, err = New(Config{ReceptiveField: 4, Bias: true}, floats.NewReader(weights[1:])); err == nil {
+		t.Error("expected error with too few parameters")
+	}
+	if _, err = New(Config{ReceptiveField: 4, Bias: false}, floats.NewReader(weights)); err == nil {
+		t.Error("expected error with too many parameters")
+	}
+}
+
+func TestProcessAllocations(t *testing.T) {
+	model, err := New(Config{ReceptiveField: 4, Bias: true}, floats.NewReader([]float64{0.5, -0.25, 2, 1, 0.125}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	model.SetMaxBlockSize(256)
+	input, output := make([]float32, 256), make([]float32, 256)
+	allocs := testing.AllocsPerRun(10, func() {
+		for _, n := range []int{1, 256, 7, 64, 255, 3} {
+			model.Process(input[:n], output[:n])
+			model.Finalize(n)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, actual %g", allocs)
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lstm implements the NAM LSTM model: a stack of LSTM layers,
+// processing one sample at a time, followed by a linear head.
+package lstm
+
+import (
+	"fmt"
+	"github.com/nlpodyssey/waveny/floats"
+	"github.com/nlpodyssey/waveny/models/realtime"
+	"github.com/nlpodyssey/waveny/models/realtime/mat"
+	"math"
+)
+
+type Config struct {
+	NumLayers  int `json:"num_layers"`
+	InputSize  int `json:"input_size"`
+	HiddenSize int `json:"hidden_size"`
+}
+
+type Model struct {
+	layers     []*layer
+	headWeight mat.Vector[float32]
+	headBias   float32
+	sampleRate int
+}
+
+// layer is a single LSTM cell, keeping its state across samples.
+type layer struct {
+	inputSize  int
+	hiddenSize int
+	// weight maps the input and the hidden state to the input, forget,
+	// cell and output gates, in this order.
+	weight mat.Matrix[float32] // 4*hiddenSize x (inputSize+hiddenSize)
+	bias   mat.Vector[float32]
+	xh     mat.Matrix[float32] // input followed by hidden state, as a column
+	gates  mat.Matrix[float32]
+	cell   []float32
+	// Initial hidden and cell states, restored on reset.
+	initialHidden []float32
+	initialCell   []float32
+}
+
+// New creates a new LSTM Model, reading the parameters in NAM order. For
+// each layer: the weights (input and hidden, stacked by columns), the bias
+// (input and hidden, summed), the initial hidden and cell states. Then, the
+// head weights and bias.
+func New(config Config, params *floats.Reader) (*Model, error) {
+	if config.NumLayers < 1 {
+		return nil, fmt.Errorf("expected at least one layer, actual %d", config.NumLayers)
+	}
+	if config.InputSize != 1 {
+		return nil, fmt.Errorf("only input size 1 is supported, actual %d", config.InputSize)
+	}
+	if config.HiddenSize < 1 {
+		return nil, fmt.Errorf("invalid hidden size %d", config.HiddenSize)
+	}
+
+	m := &Model{
+		layers:     make([]*layer, config.NumLayers),
+		headWeight: mat.NewVector[float32](config.HiddenSize),
+		sampleRate: realtime.DefaultSampleRate,
+	}
+	inputSize := config.InputSize
+	for i := range m.layers {
+		m.layers[i] = newLayer(inputSize, config.HiddenSize)
+		inputSize = config.HiddenSize
+	}
+
+	if err := m.SetParams(params); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func newLayer(inputSize, hiddenSize int) *layer {
+	return &layer{
+		inputSize:  inputSize,
+		hiddenSize: hiddenSize,
+		weight:     mat.NewMatrix[float32](4*hiddenSize, inputSize+hiddenSize),
+		bias:       mat.NewVector[float32](4 * hiddenSize),
+		xh:         mat.NewMatrix[float32](inputSize+hiddenSize, 1),
+		gates:      mat.NewMatrix[float32](4*hiddenSize, 1),
+		cell:       make([]float32, hiddenSize),
+
+		initialHidden: make([]float32, hiddenSize),
+		initialCell:   make([]float32, hiddenSize),
+	}
+}
+
+func (m *Model) numParams() int {
+	n := 0
+	for _, l := range m.layers {
+		n += l.weight.Rows()*l.weight.Columns() + l.bias.Size() + 2*l.hiddenSize
+	}
+	return n + m.headWeight.Size() + 1
+}
+
+func (m *Model) SetParams(params *floats.Reader) error {
+	if expected, actual := m.numParams(), params.Remaining(); actual < expected {
+		return fmt.Errorf("too few parameters: expected %d, actual %d", expected, actual)
+	}
+	for _, l := range m.layers {
+		l.setParams(params)
+	}
+	for i := 0; i < m.headWeight.Size(); i++ {
+		m.headWeight.Set(i, float32(params.Next()))
+	}
+	m.headBias = float32(params.Next())
+	if params.HasNext() {
+		return fmt.Errorf("too many parameters")
+	}
+	return nil
+}
+
+func (l *layer) setParams(params *floats.Reader) {
+	for i := 0; i < l.weight.Rows(); i++ {
+		for j := 0; j < l.weight.Columns(); j++ {
+			l.weight.Set(i, j, float32(params.Next()))
+		}
+	}
+	for i := 0; i < l.bias.Size(); i++ {
+		l.bias.Set(i, float32(params.Next()))
+	}
+	for i := range l.initialHidden {
+		l.initialHidden[i] = float32(params.Next())
+	}
+	for i := range l.initialCell {
+		l.initialCell[i] = float32(params.Next())
+	}
+	l.reset()
+}
+
+func (l *layer) reset() {
+	for i, v := range l.initialHidden {
+		l.xh.Set(l.inputSize+i, 0, v)
+	}
+	copy(l.cell, l.initialCell)
+}
+
+// Process processes the input samples one at a time, updating the state of
+// the layers.
+func (m *Model) Process(input, output []float32) {
+	last := m.layers[len(m.layers)-1]
+	for i, x := range input {
+		m.layers[0].xh.Set(0, 0, x)
+		m.layers[0].process()
+		for j := 1; j < len(m.layers); j++ {
+			m.layers[j].setInput(m.layers[j-1])
+			m.layers[j].process()
+		}
+
+		y := m.headBias
+		for k := 0; k < last.hiddenSize; k++ {
+			y += m.headWeight.Get(k) * last.hidden(k)
+		}
+		output[i] = y
+	}
+}
+
+// SetMaxBlockSize is a no-op: samples are processed one at a time, without
+// allocating, whatever the block size.
+func (m *Model) SetMaxBlockSize(int) {}
+
+// Finalize is a no-op: the state is updated while processing.
+func (m *Model) Finalize(int) {}
+
+// Reset restores the initial state of the layers.
+func (m *Model) Reset() {
+	for _, l := range m.layers {
+		l.reset()
+	}
+}
+
+// Latency is always zero: the model is causal.
+func (m *Model) Latency() int {
+	return 0
+}
+
+// ReceptiveField returns 1, since past samples are summarized by the
+// recurrent state, rather than by a bounded window.
+func (m *Model) ReceptiveField() int {
+	return 1
+}
+
+func (m *Model) SampleRate() int {
+	return m.sampleRate
+}
+
+// Close does nothing, since the model starts no goroutines.
+func (m *Model) Close() {}
+
+func (l *layer) hidden(i int) float32 {
+	return l.xh.Get(l.inputSize+i, 0)
+}
+
+func (l *layer) setInput(previous *layer) {
+	for i := 0; i < previous.hiddenSize; i++ {
+		l.xh.Set(i, 0, previous.hidden(i))
+	}
+}
+
+func (l *layer) process() {
+	mat.Product(l.weight, l.xh, l.gates)
+	mat.AddInPlaceColumnWise(l.gates, l.bias)
+
+	h := l.hiddenSize
+	for i := 0; i < h; i++ {
+		inputGate := sigmoid(l.gates.Get(i, 0))
+		forgetGate := sigmoid(l.gates.Get(h+i, 0))
+		cellGate := float32(math.Tanh(float64(l.gates.Get(2*h+i, 0))))
+		outputGate := sigmoid(l.gates.Get(3*h+i, 0))
+
+		l.cell[i] = forgetGate*l.cell[i] + inputGate*cellGate
+		l.xh.Set(l.inputSize+i, 0, outputGate*float32(math.Tanh(float64(l.cell[i]))))
+	}
+}
+
+func sigmoid(v float32) float32 {
+	return float32(1 / (1 + math.Exp(float64(-v))))
+}
@@ -0,0 +1,204 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lstm
+
+import (
+	"github.com/nlpodyssey/waveny/floats"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// torchLSTM holds the parameters of a PyTorch LSTM, with separate input and
+// hidden weights and biases, as a reference implementation.
+type torchLSTM struct {
+	weightIH, weightHH [][][]float64 // per layer, 4*hidden x input/hidden
+	biasIH, biasHH     [][]float64
+	h0, c0             [][]float64
+	headWeight         []float64
+	headBias           float64
+}
+
+func newTorchLSTM(rnd *rand.Rand, config Config) *torchLSTM {
+	randMatrix := func(rows, columns int) [][]float64 {
+		m := make([][]float64, rows)
+		for i := range m {
+			m[i] = randVector(rnd, columns)
+		}
+		return m
+	}
+	t := &torchLSTM{
+		headWeight: randVector(rnd, config.HiddenSize),
+		headBias:   rnd.Float64() - 0.5,
+	}
+	inputSize := config.InputSize
+	for i := 0; i < config.NumLayers; i++ {
+		t.weightIH = append(t.weightIH, randMatrix(4*config.HiddenSize, inputSize))
+		t.weightHH = append(t.weightHH, randMatrix(4*config.HiddenSize, config.HiddenSize))
+		t.biasIH = append(t.biasIH, randVector(rnd, 4*config.HiddenSize))
+		t.biasHH = append(t.biasHH, randVector(rnd, 4*config.HiddenSize))
+		t.h0 = append(t.h0, randVector(rnd, config.HiddenSize))
+		t.c0 = append(t.c0, randVector(rnd, config.HiddenSize))
+		inputSize = config.HiddenSize
+	}
+	return t
+}
+
+func randVector(rnd *rand.Rand, size int) []float64 {
+	v := make([]float64, size)
+	for i := range v {
+		v[i] = rnd.Float64() - 0.5
+	}
+	return v
+}
+
+// exportWeights returns the parameters in NAM order.
+func (t *torchLSTM) exportWeights() []float64 {
+	w := floats.NewWriter()
+	for i := range t.weightIH {
+		for r := range t.weightIH[i] {
+			for _, v := range t.weightIH[i][r] {
+				w.Write(v)
+			}
+			for _, v := range t.weightHH[i][r] {
+				w.Write(v)
+			}
+		}
+		for r := range t.biasIH[i] {
+			w.Write(t.biasIH[i][r] + t.biasHH[i][r])
+		}
+		for _, v := range t.h0[i] {
+			w.Write(v)
+		}
+		for _, v := range t.c0[i] {
+			w.Write(v)
+		}
+	}
+	for _, v := range t.headWeight {
+		w.Write(v)
+	}
+	w.Write(t.headBias)
+	return w.Floats()
+}
+
+func (t *torchLSTM) forward(input []float32) []float64 {
+	sigmoid := func(v float64) float64 { return 1 / (1 + math.Exp(-v)) }
+	h := make([][]float64, len(t.h0))
+	c := make([][]float64, len(t.c0))
+	for i := range h {
+		h[i] = append([]float64(nil), t.h0[i]...)
+		c[i] = append([]float64(nil), t.c0[i]...)
+	}
+	output := make([]float64, len(input))
+	for n, sample := range input {
+		x := []float64{float64(sample)}
+		for l := range t.weightIH {
+			size := len(h[l])
+			gates := make([]float64, 4*size)
+			for r := range gates {
+				v := t.biasIH[l][r] + t.biasHH[l][r]
+				for j, w := range t.weightIH[l][r] {
+					v += w * x[j]
+				}
+				for j, w := range t.weightHH[l][r] {
+					v += w * h[l][j]
+				}
+				gates[r] = v
+			}
+			for k := 0; k < size; k++ {
+				i, f := sigmoid(gates[k]), sigmoid(gates[size+k])
+				g, o := math.Tanh(gates[2*size+k]), sigmoid(gates[3*size+k])
+				c[l][k] = f*c[l][k] + i*g
+				h[l][k] = o * math.Tanh(c[l][k])
+			}
+			x = h[l]
+		}
+		y := t.headBias
+		for k, w := range t.headWeight {
+			y += w * x[k]
+		}
+		output[n] = y
+	}
+	return output
+}
+
+func TestLSTM(t *testing.T) {
+	for _, config := range []Config{
+		{NumLayers: 1, InputSize: 1, HiddenSize: 8},
+		{NumLayers: 3, InputSize: 1, HiddenSize: 5},
+	} {
+		rnd := rand.New(rand.NewSource(1))
+		reference := newTorchLSTM(rnd, config)
+		model, err := New(config, floats.NewReader(reference.exportWeights()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		input := make([]float32, 300)
+		for i := range input {
+			input[i] = float32(math.Sin(float64(i) / 7))
+		}
+		expected := reference.forward(input)
+		actual := make([]float32, len(input))
+		process := func() {
+			for from := 0; from < len(input); from += 64 {
+				to := min(from+64, len(input))
+				model.Process(input[from:to], actual[from:to])
+				model.Finalize(to - from)
+			}
+		}
+		assertClose := func() {
+			t.Helper()
+			for i, v := range expected {
+				if math.Abs(float64(actual[i])-v) > 1e-5 {
+					t.Fatalf("%+v: sample %d: expected %g, actual %g", config, i, v, actual[i])
+				}
+			}
+		}
+		process()
+		assertClose()
+
+		model.Reset()
+		process()
+		assertClose()
+
+		weights := reference.exportWeights()
+		if _, err = New(config, floats.NewReader(weights[1:])); err == nil {
+			t.Error("expected error with too few parameters")
+		}
+		if _, err = New(config, floats.NewReader(append(weights, 0))); err == nil {
+			t.Error("expected error with too many parameters")
+		}
+	}
+}
+
+func TestProcessAllocations(t *testing.T) {
+	config := Config{NumLayers: 2, InputSize: 1, HiddenSize: 8}
+	model, err := New(config, floats.NewReader(newTorchLSTM(rand.New(rand.NewSource(1)), config).exportWeights()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	model.SetMaxBlockSize(256)
+	input, output := make([]float32, 256), make([]float32, 256)
+	allocs := testing.AllocsPerRun(10, func() {
+		for _, n := range []int{1, 256, 7, 64, 255, 3} {
+			model.Process(input[:n], output[:n])
+			model.Finalize(n)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, actual %g", allocs)
+	}
+}
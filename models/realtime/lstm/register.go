@@ -0,0 +1,39 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lstm
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nlpodyssey/waveny/floats"
+	"github.com/nlpodyssey/waveny/models/realtime"
+)
+
+func init() {
+	realtime.Register("LSTM", newFromModelData)
+}
+
+func newFromModelData(modelData *realtime.ModelData, _ realtime.Options) (realtime.Model, error) {
+	var config Config
+	if err := json.Unmarshal(modelData.Config, &config); err != nil {
+		return nil, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+	model, err := New(config, floats.NewReader(modelData.Weights))
+	if err != nil {
+		return nil, err
+	}
+	model.sampleRate = modelData.GetSampleRate()
+	return model, nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mat
+
+// fusedTileColumns is the amount of columns of the output computed at
+// once by FusedProduct, so that a tile stays in the L1 cache while the
+// epilogue is applied.
+const fusedTileColumns = 128
+
+// panelRows is the amount of output rows computed at once, sharing the
+// loads of the input rows.
+const panelRows = 4
+
+// An Epilogue is applied in place to each tile of the output of a
+// FusedProduct, right after computing it.
+type Epilogue[T Float] interface {
+	Apply(tile Matrix[T])
+}
+
+// A FusedProduct computes a sum of matrix products sharing the same output,
+// such as the taps of a convolution, plus a bias, in a single pass over
+// the output, tile by tile.
+//
+// The weights are set once, with SetWeights, then each computation takes
+// an input for each weight, added with AddInput.
+type FusedProduct[T Float] struct {
+	// weights side by side, interleaved by panels of panelRows rows,
+	// followed by the remaining rows
+	weight  []T
+	bias    []T
+	columns []int // columns of each weight
+	inner   int   // total columns of the weights
+
+	inputs []fusedInput[T]
+	rows   [][]T // input rows of the current computation
+}
+
+type fusedInput[T Float] struct {
+	input       Matrix[T]
+	startColumn int
+}
+
+// SetWeights sets the weights of the terms of the sum, at least one, all
+// with the same amount of rows, and the bias, which may be empty. Weights
+// are copied: SetWeights must be called again when they change.
+func (f *FusedProduct[T]) SetWeights(bias Vector[T], weights ...Matrix[T]) {
+	rows := weights[0].rows
+	f.columns = f.columns[:0]
+	f.inner = 0
+	for _, w := range weights {
+		f.columns = append(f.columns, w.viewColumns)
+		f.inner += w.viewColumns
+	}
+	f.weight = resize(f.weight, rows*f.inner)
+	f.bias = resize(f.bias, rows)
+	if cap(f.inputs) < len(weights) {
+		f.inputs = make([]fusedInput[T], 0, len(weights))
+	}
+	if cap(f.rows) < f.inner {
+		f.rows = make([][]T, f.inner)
+	}
+
+	numPanelRows := rows - rows%panelRows
+	k := 0
+	for _, w := range weights {
+		for i := 0; i < rows; i++ {
+			for j, v := range w.getRow(i) {
+				if i < numPanelRows {
+					// Interleaved, for each column of the panel.
+					f.weight[(i/panelRows)*panelRows*f.inner+(k+j)*panelRows+i%panelRows] = v
+				} else {
+					f.weight[i*f.inner+k+j] = v
+				}
+			}
+		}
+		k += w.viewColumns
+	}
+
+	for i := range f.bias {
+		f.bias[i] = 0
+		if bias.Size() > 0 {
+			f.bias[i] = bias.Get(i)
+		}
+	}
+}
+
+// AddInput adds the input of the next weight, for the next computation,
+// starting from the given column.
+func (f *FusedProduct[T]) AddInput(input Matrix[T], startColumn int) {
+	f.inputs = append(f.inputs, fusedInput[T]{input: input, startColumn: startColumn})
+}
+
+// Compute computes output = bias + the sum of the products of each weight
+// by its input, and applies the epilogue, if not nil. The inputs are
+// discarded afterwards.
+func (f *FusedProduct[T]) Compute(output Matrix[T], epilogue Epilogue[T]) {
+	f.Prepare(output.viewColumns)
+	f.ComputeRows(output, 0, output.rows, epilogue)
+}
+
+// Prepare gathers the inputs added so far, discarding them, for computing
+// numColumns columns with ComputeRows.
+func (f *FusedProduct[T]) Prepare(numColumns int) {
+	if len(f.inputs) != len(f.columns) {
+		panic("mat: FusedProduct inputs don't match weights")
+	}
+	f.gatherRows(numColumns)
+}
+
+// ComputeRows is like Compute, for the rows of the output from fromRow,
+// which must be a multiple of 4, to toRow, excluded, with the inputs
+// gathered by Prepare. The epilogue is applied to the computed rows only.
+//
+// Separate rows can be computed concurrently. PartitionRows splits the
+// rows accordingly.
+func (f *FusedProduct[T]) ComputeRows(output Matrix[T], fromRow, toRow int, epilogue Epilogue[T]) {
+	if fromRow%panelRows != 0 {
+		panic("mat: FusedProduct rows must start at a multiple of 4")
+	}
+	if fromRow >= toRow {
+		return
+	}
+	numColumns, stride := output.viewColumns, output.dataColumns
+	numPanelRows := min(toRow, output.rows-output.rows%panelRows)
+	panelSize := panelRows * f.inner
+	k := kernelsOf[T]()
+
+	for from := 0; from < numColumns; from += fusedTileColumns {
+		n := min(fusedTileColumns, numColumns-from)
+		i := fromRow
+		for ; i+panelRows <= numPanelRows; i += panelRows {
+			p := i / panelRows
+			k.gatherProductPanel(output.data[i*stride+from:], stride, n,
+				f.weight[p*panelSize:(p+1)*panelSize], f.rows, from, f.bias[i:i+panelRows])
+		}
+		for ; i < toRow; i++ {
+			a := f.weight[i*f.inner : (i+1)*f.inner]
+			k.gatherProductRow(output.getRow(i)[from:from+n], a, f.rows, from, f.bias[i])
+		}
+		if epilogue != nil {
+			epilogue.Apply(output.View(fromRow, from, toRow-fromRow, n))
+		}
+	}
+}
+
+// PartitionRows returns the range of rows [from, to) of the given part of
+// the rows, split in numParts parts, as evenly as possible, at multiples
+// of 4, as required by FusedProduct.ComputeRows. Parts can be empty.
+func PartitionRows(rows, part, numParts int) (from, to int) {
+	numPanels := (rows + panelRows - 1) / panelRows
+	from = min(part*numPanels/numParts*panelRows, rows)
+	to = min((part+1)*numPanels/numParts*panelRows, rows)
+	return from, to
+}
+
+// gatherRows collects the rows of the inputs, from their start columns on.
+func (f *FusedProduct[T]) gatherRows(numColumns int) {
+	f.rows = f.rows[:f.inner]
+	k := 0
+	for t, in := range f.inputs {
+		start, end := in.startColumn, in.startColumn+numColumns
+		for r := 0; r < f.columns[t]; r++ {
+			f.rows[k+r] = in.input.getRow(r)[start:end:end]
+		}
+		k += f.columns[t]
+	}
+	f.inputs = f.inputs[:0]
+}
+
+// resize returns a slice of length n, reusing s if large enough.
+func resize[T Float](s []T, n int) []T {
+	if cap(s) < n {
+		return make([]T, n)
+	}
+	return s[:n]
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mat
+
+import (
+	"math/rand"
+	"testing"
+)
+
+type tanhEpilogue struct{}
+
+func (tanhEpilogue) Apply(tile Matrix[float32]) {
+	tile.TanhInPlace()
+}
+
+func FuzzFusedProduct(f *testing.F) {
+	f.Add(int64(1), uint8(1), uint8(1), uint8(1), uint8(1), true)
+	f.Add(int64(2), uint8(16), uint8(3), uint8(16), uint8(64), false)
+	f.Add(int64(3), uint8(8), uint8(4), uint8(7), uint8(200), true)
+	f.Add(int64(4), uint8(19), uint8(13), uint8(5), uint8(37), true)
+	f.Fuzz(func(t *testing.T, seed int64, rows, numTerms, inner, columns uint8, withBias bool) {
+		rnd := rand.New(rand.NewSource(seed))
+		r, n, c := int(rows%20)+1, int(numTerms%4)+1, int(columns)
+		output := randView(rnd, r, c)
+		var bias Vector[float32]
+		if withBias {
+			bias = NewVectorFromSlice(randSlice(rnd, r, 1))
+		}
+
+		expected := NewMatrix[float32](r, c)
+		for i := 0; i < r; i++ {
+			if withBias {
+				addScalarRowGeneric(expected.getRow(i), bias.Get(i))
+			}
+		}
+		var weights []Matrix[float32]
+		var fp FusedProduct[float32]
+		for i := 0; i < n; i++ {
+			k := int(inner%20) + i
+			start := rnd.Intn(8)
+			weight := randView(rnd, r, k)
+			input := randView(rnd, k, start+c+rnd.Intn(3))
+			weights = append(weights, weight)
+			fp.AddInput(input, start)
+			if k > 0 {
+				expected = referenceProduct(weight, input.ViewMiddleColumns(start, c), expected, true)
+			}
+		}
+		fp.SetWeights(bias, weights...)
+		var epilogue Epilogue[float32]
+		if n%2 == 1 {
+			expected.TanhInPlace()
+			epilogue = tanhEpilogue{}
+		}
+		// Rows are computed in parts, as done concurrently, if more than one.
+		if numParts := int(numTerms/4%4) + 1; numParts == 1 {
+			fp.Compute(output, epilogue)
+		} else {
+			fp.Prepare(c)
+			for part := 0; part < numParts; part++ {
+				from, to := PartitionRows(r, part, numParts)
+				fp.ComputeRows(output, from, to, epilogue)
+			}
+		}
+		for i := 0; i < r; i++ {
+			assertClose(t, "fused product", expected.getRow(i), output.getRow(i), float64(n*(int(inner%20)+n)+1)*1e-6)
+		}
+	})
+}
+
+func TestPartitionRows(t *testing.T) {
+	for rows := 0; rows < 40; rows++ {
+		for numParts := 1; numParts < 6; numParts++ {
+			next := 0
+			for part := 0; part < numParts; part++ {
+				from, to := PartitionRows(rows, part, numParts)
+				if from != next || to < from || from%4 != 0 {
+					t.Fatalf("rows %d, part %d of %d: unexpected range [%d, %d)", rows, part, numParts, from, to)
+				}
+				next = to
+			}
+			if next != rows {
+				t.Fatalf("rows %d, %d parts: covered %d rows", rows, numParts, next)
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mat
+
+import (
+	"math"
+	"unsafe"
+)
+
+// kernels are the row kernels of an element type, operating on contiguous
+// rows of matrices.
+type kernels[T Float] struct {
+	// productRow computes the row c of a matrix product, from the row a of
+	// the left matrix, and the data of the right matrix, whose rows are
+	// bStride elements apart. With add, the result is added to c.
+	productRow func(c, a, b []T, bStride int, add bool)
+	// gatherProductRow computes c[j] = bias + sum(a[k] * b[k][offset+j]).
+	gatherProductRow func(c, a []T, b [][]T, offset int, bias T)
+	// gatherProductPanel is like gatherProductRow, for n columns of
+	// panelRows rows of c at once, cStride elements apart. The weights of
+	// the rows are interleaved in a.
+	gatherProductPanel func(c []T, cStride, n int, a []T, b [][]T, offset int, bias []T)
+	addRow             func(dst, src []T)
+	mulRow             func(dst, src []T)
+	addScalarRow       func(dst []T, v T)
+	tanhRow            func(dst []T)
+	sigmoidRow         func(dst []T)
+	reluRow            func(dst []T)
+	clampRow           func(dst []T, lo, hi T)
+}
+
+// The kernels default to the portable Go implementations below. The float32
+// ones are replaced by vectorized implementations, when supported by the
+// CPU, at initialization.
+var (
+	kernels32 = genericKernels[float32]()
+	kernels64 = genericKernels[float64]()
+)
+
+func genericKernels[T Float]() kernels[T] {
+	return kernels[T]{
+		productRow:         productRowGeneric[T],
+		gatherProductRow:   gatherProductRowGeneric[T],
+		gatherProductPanel: gatherProductPanelGeneric[T],
+		addRow:             addRowGeneric[T],
+		mulRow:             mulRowGeneric[T],
+		addScalarRow:       addScalarRowGeneric[T],
+		tanhRow:            tanhRowGeneric[T],
+		sigmoidRow:         sigmoidRowGeneric[T],
+		reluRow:            reluRowGeneric[T],
+		clampRow:           clampRowGeneric[T],
+	}
+}
+
+// kernelsOf returns the kernels of the element type T.
+func kernelsOf[T Float]() *kernels[T] {
+	var zero T
+	if unsafe.Sizeof(zero) == 4 {
+		return (*kernels[T])(unsafe.Pointer(&kernels32))
+	}
+	return (*kernels[T])(unsafe.Pointer(&kernels64))
+}
+
+func productRowGeneric[T Float](c, a, b []T, bStride int, add bool) {
+	for j := range c {
+		v := T(0)
+		if add {
+			v = c[j]
+		}
+		bOffset := j
+		for _, aValue := range a {
+			v += aValue * b[bOffset]
+			bOffset += bStride
+		}
+		c[j] = v
+	}
+}
+
+func gatherProductRowGeneric[T Float](c, a []T, b [][]T, offset int, bias T) {
+	b = b[:len(a)]
+	for j := range c {
+		v := bias
+		for k, aValue := range a {
+			v += aValue * b[k][offset+j]
+		}
+		c[j] = v
+	}
+}
+
+func gatherProductPanelGeneric[T Float](c []T, cStride, n int, a []T, b [][]T, offset int, bias []T) {
+	b = b[:len(a)/panelRows]
+	for r := 0; r < panelRows; r++ {
+		row := c[r*cStride : r*cStride+n]
+		for j := range row {
+			v := bias[r]
+			for k, bRow := range b {
+				v += a[k*panelRows+r] * bRow[offset+j]
+			}
+			row[j] = v
+		}
+	}
+}
+
+func addRowGeneric[T Float](dst, src []T) {
+	dst = dst[:len(src)]
+	for j, v := range src {
+		dst[j] += v
+	}
+}
+
+func mulRowGeneric[T Float](dst, src []T) {
+	dst = dst[:len(src)]
+	for j, v := range src {
+		dst[j] *= v
+	}
+}
+
+func addScalarRowGeneric[T Float](dst []T, v T) {
+	for j := range dst {
+		dst[j] += v
+	}
+}
+
+func tanhRowGeneric[T Float](dst []T) {
+	for j, v := range dst {
+		dst[j] = T(math.Tanh(float64(v)))
+	}
+}
+
+func sigmoidRowGeneric[T Float](dst []T) {
+	for j, v := range dst {
+		dst[j] = T(1 / (1 + math.Exp(float64(-v))))
+	}
+}
+
+func reluRowGeneric[T Float](dst []T) {
+	for j, v := range dst {
+		if v < 0 {
+			dst[j] = 0
+		}
+	}
+}
+
+func clampRowGeneric[T Float](dst []T, lo, hi T) {
+	for j, v := range dst {
+		dst[j] = min(max(v, lo), hi)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !purego
+
+package mat
+
+import "golang.org/x/sys/cpu"
+
+// avx2Width is the amount of float32 values in an AVX2 register.
+const avx2Width = 8
+
+func init() {
+	if !cpu.X86.HasAVX2 || !cpu.X86.HasFMA {
+		return
+	}
+	kernels32.productRow = productRowAVX2Go
+	kernels32.gatherProductRow = gatherProductRowAVX2Go
+	kernels32.gatherProductPanel = gatherProductPanelAVX2Go
+	kernels32.addRow = addRowAVX2Go
+	kernels32.mulRow = mulRowAVX2Go
+	kernels32.addScalarRow = addScalarRowAVX2Go
+	kernels32.tanhRow = tanhRowAVX2Go
+	kernels32.sigmoidRow = sigmoidRowAVX2Go
+	kernels32.reluRow = reluRowAVX2Go
+	kernels32.clampRow = clampRowAVX2Go
+}
+
+// The AVX2 kernels process the largest prefix of the rows multiple of
+// avx2Width, leaving the rest to the Go implementations.
+
+//go:noescape
+func productRowAVX2(c, a, b []float32, bStride int, add bool)
+
+//go:noescape
+func gatherProductRowAVX2(c, a []float32, b [][]float32, offset int, bias float32)
+
+//go:noescape
+func gatherProductPanelAVX2(c []float32, cStride, n int, a []float32, b [][]float32, offset int, bias []float32)
+
+//go:noescape
+func addRowAVX2(dst, src []float32)
+
+//go:noescape
+func mulRowAVX2(dst, src []float32)
+
+//go:noescape
+func addScalarRowAVX2(dst []float32, v float32)
+
+//go:noescape
+func tanhRowAVX2(dst []float32)
+
+//go:noescape
+func sigmoidRowAVX2(dst []float32)
+
+//go:noescape
+func reluRowAVX2(dst []float32)
+
+//go:noescape
+func clampRowAVX2(dst []float32, lo, hi float32)
+
+func productRowAVX2Go(c, a, b []float32, bStride int, add bool) {
+	n := len(c) &^ (avx2Width - 1)
+	if n > 0 {
+		if len(a) > 0 {
+			_ = b[(len(a)-1)*bStride+n-1]
+		}
+		productRowAVX2(c[:n], a, b, bStride, add)
+	}
+	if n < len(c) {
+		if len(a) > 0 {
+			b = b[n:]
+		}
+		productRowGeneric(c[n:], a, b, bStride, add)
+	}
+}
+
+func gatherProductRowAVX2Go(c, a []float32, b [][]float32, offset int, bias float32) {
+	n := len(c) &^ (avx2Width - 1)
+	if n > 0 {
+		for _, row := range b[:len(a)] {
+			_ = row[offset+n-1]
+		}
+		gatherProductRowAVX2(c[:n], a, b, offset, bias)
+	}
+	gatherProductRowGeneric(c[n:], a, b, offset+n, bias)
+}
+
+func gatherProductPanelAVX2Go(c []float32, cStride, n int, a []float32, b [][]float32, offset int, bias []float32) {
+	m := n &^ (avx2Width - 1)
+	if m > 0 {
+		_ = c[(panelRows-1)*cStride+m-1]
+		_ = bias[panelRows-1]
+		for _, row := range b[:len(a)/panelRows] {
+			_ = row[offset+m-1]
+		}
+		gatherProductPanelAVX2(c, cStride, m, a, b, offset, bias)
+	}
+	if m < n {
+		gatherProductPanelGeneric(c[m:], cStride, n-m, a, b, offset+m, bias)
+	}
+}
+
+func addRowAVX2Go(dst, src []float32) {
+	dst = dst[:len(src)]
+	n := len(src) &^ (avx2Width - 1)
+	addRowAVX2(dst[:n], src)
+	addRowGeneric(dst[n:], src[n:])
+}
+
+func mulRowAVX2Go(dst, src []float32) {
+	dst = dst[:len(src)]
+	n := len(src) &^ (avx2Width - 1)
+	mulRowAVX2(dst[:n], src)
+	mulRowGeneric(dst[n:], src[n:])
+}
+
+func addScalarRowAVX2Go(dst []float32, v float32) {
+	n := len(dst) &^ (avx2Width - 1)
+	addScalarRowAVX2(dst[:n], v)
+	addScalarRowGeneric(dst[n:], v)
+}
+
+func tanhRowAVX2Go(dst []float32) {
+	n := len(dst) &^ (avx2Width - 1)
+	tanhRowAVX2(dst[:n])
+	tanhRowGeneric(dst[n:])
+}
+
+func sigmoidRowAVX2Go(dst []float32) {
+	n := len(dst) &^ (avx2Width - 1)
+	sigmoidRowAVX2(dst[:n])
+	sigmoidRowGeneric(dst[n:])
+}
+
+func reluRowAVX2Go(dst []float32) {
+	n := len(dst) &^ (avx2Width - 1)
+	reluRowAVX2(dst[:n])
+	reluRowGeneric(dst[n:])
+}
+
+func clampRowAVX2Go(dst []float32, lo, hi float32) {
+	n := len(dst) &^ (avx2Width - 1)
+	clampRowAVX2(dst[:n], lo, hi)
+	clampRowGeneric(dst[n:], lo, hi)
+}
@@ -0,0 +1,553 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !purego
+
+#include "textflag.h"
+
+// Constants of the exponential function approximation, from Cephes expf:
+// x is reduced as x = g + n*ln(2), with |g| <= ln(2)/2, so that
+// exp(x) = 2^n * exp(g), and exp(g) is approximated by a polynomial.
+DATA expOne<>+0(SB)/4, $0x3f800000   // 1
+GLOBL expOne<>(SB), RODATA|NOPTR, $4
+DATA expHi<>+0(SB)/4, $0x42b0c0a5    // 88.3762626647949
+GLOBL expHi<>(SB), RODATA|NOPTR, $4
+DATA expLo<>+0(SB)/4, $0xc2b0c0a5    // -88.3762626647949
+GLOBL expLo<>(SB), RODATA|NOPTR, $4
+DATA expLog2e<>+0(SB)/4, $0x3fb8aa3b // log2(e)
+GLOBL expLog2e<>(SB), RODATA|NOPTR, $4
+DATA expHalf<>+0(SB)/4, $0x3f000000  // 0.5
+GLOBL expHalf<>(SB), RODATA|NOPTR, $4
+DATA expC1<>+0(SB)/4, $0x3f318000    // ln(2), high part
+GLOBL expC1<>(SB), RODATA|NOPTR, $4
+DATA expC2<>+0(SB)/4, $0xb95e8083    // ln(2), low part, negated
+GLOBL expC2<>(SB), RODATA|NOPTR, $4
+DATA expP0<>+0(SB)/4, $0x39506967    // 1.9875691500e-4
+GLOBL expP0<>(SB), RODATA|NOPTR, $4
+DATA expP1<>+0(SB)/4, $0x3ab743ce    // 1.3981999507e-3
+GLOBL expP1<>(SB), RODATA|NOPTR, $4
+DATA expP2<>+0(SB)/4, $0x3c088908    // 8.3334519073e-3
+GLOBL expP2<>(SB), RODATA|NOPTR, $4
+DATA expP3<>+0(SB)/4, $0x3d2aa9c1    // 4.1665795894e-2
+GLOBL expP3<>(SB), RODATA|NOPTR, $4
+DATA expP4<>+0(SB)/4, $0x3e2aaaaa    // 1.6666665459e-1
+GLOBL expP4<>(SB), RODATA|NOPTR, $4
+
+// Exponent bias of float32 values, for each lane.
+DATA expBias<>+0(SB)/4, $127
+DATA expBias<>+4(SB)/4, $127
+DATA expBias<>+8(SB)/4, $127
+DATA expBias<>+12(SB)/4, $127
+DATA expBias<>+16(SB)/4, $127
+DATA expBias<>+20(SB)/4, $127
+DATA expBias<>+24(SB)/4, $127
+DATA expBias<>+28(SB)/4, $127
+GLOBL expBias<>(SB), RODATA|NOPTR, $32
+
+// EXP_CONSTANTS loads the constants used by EXP into Y4-Y15.
+#define EXP_CONSTANTS \
+	VBROADCASTSS expOne<>(SB), Y4   \
+	VBROADCASTSS expP4<>(SB), Y5    \
+	VBROADCASTSS expP3<>(SB), Y6    \
+	VBROADCASTSS expP2<>(SB), Y7    \
+	VBROADCASTSS expP1<>(SB), Y8    \
+	VBROADCASTSS expP0<>(SB), Y9    \
+	VBROADCASTSS expC2<>(SB), Y10   \
+	VBROADCASTSS expC1<>(SB), Y11   \
+	VBROADCASTSS expHalf<>(SB), Y12 \
+	VBROADCASTSS expLog2e<>(SB), Y13 \
+	VBROADCASTSS expLo<>(SB), Y14   \
+	VBROADCASTSS expHi<>(SB), Y15
+
+// EXP computes Y0 = exp(Y0), clobbering Y1-Y3. The last coefficient of
+// the polynomial rounds to 0.5, so Y12 is used for it.
+#define EXP \
+	VMINPS      Y15, Y0, Y0              \
+	VMAXPS      Y14, Y0, Y0              \
+	VMOVAPS     Y12, Y1                  \
+	VFMADD231PS Y13, Y0, Y1              \
+	VROUNDPS    $1, Y1, Y1               \
+	VFNMADD231PS Y11, Y1, Y0             \
+	VFNMADD231PS Y10, Y1, Y0             \
+	VMULPS      Y0, Y0, Y2               \
+	VMOVAPS     Y9, Y3                   \
+	VFMADD213PS Y8, Y0, Y3               \
+	VFMADD213PS Y7, Y0, Y3               \
+	VFMADD213PS Y6, Y0, Y3               \
+	VFMADD213PS Y5, Y0, Y3               \
+	VFMADD213PS Y12, Y0, Y3              \
+	VFMADD213PS Y0, Y2, Y3               \
+	VADDPS      Y4, Y3, Y3               \
+	VCVTTPS2DQ  Y1, Y1                   \
+	VPADDD      expBias<>(SB), Y1, Y1    \
+	VPSLLD      $23, Y1, Y1              \
+	VMULPS      Y1, Y3, Y0
+
+// func productRowAVX2(c, a, b []float32, bStride int, add bool)
+//
+// len(c) must be a multiple of 8.
+TEXT ·productRowAVX2(SB), NOSPLIT, $0-81
+	MOVQ    c_base+0(FP), DI
+	MOVQ    c_len+8(FP), CX
+	MOVQ    a_base+24(FP), SI
+	MOVQ    a_len+32(FP), DX
+	MOVQ    b_base+48(FP), BX
+	MOVQ    bStride+72(FP), R8
+	SHLQ    $2, R8
+	MOVBLZX add+80(FP), R9
+
+block32:
+	CMPQ  CX, $32
+	JL    block8
+	TESTQ R9, R9
+	JZ    zero32
+	VMOVUPS (DI), Y0
+	VMOVUPS 32(DI), Y1
+	VMOVUPS 64(DI), Y2
+	VMOVUPS 96(DI), Y3
+	JMP   accumulate32
+
+zero32:
+	VXORPS Y0, Y0, Y0
+	VXORPS Y1, Y1, Y1
+	VXORPS Y2, Y2, Y2
+	VXORPS Y3, Y3, Y3
+
+accumulate32:
+	MOVQ SI, R10
+	MOVQ BX, R11
+	MOVQ DX, R12
+
+loop32:
+	TESTQ R12, R12
+	JZ    store32
+	VBROADCASTSS (R10), Y4
+	VFMADD231PS  (R11), Y4, Y0
+	VFMADD231PS  32(R11), Y4, Y1
+	VFMADD231PS  64(R11), Y4, Y2
+	VFMADD231PS  96(R11), Y4, Y3
+	ADDQ $4, R10
+	ADDQ R8, R11
+	DECQ R12
+	JMP  loop32
+
+store32:
+	VMOVUPS Y0, (DI)
+	VMOVUPS Y1, 32(DI)
+	VMOVUPS Y2, 64(DI)
+	VMOVUPS Y3, 96(DI)
+	ADDQ $128, DI
+	ADDQ $128, BX
+	SUBQ $32, CX
+	JMP  block32
+
+block8:
+	CMPQ  CX, $8
+	JL    done
+	TESTQ R9, R9
+	JZ    zero8
+	VMOVUPS (DI), Y0
+	JMP   accumulate8
+
+zero8:
+	VXORPS Y0, Y0, Y0
+
+accumulate8:
+	MOVQ SI, R10
+	MOVQ BX, R11
+	MOVQ DX, R12
+
+loop8:
+	TESTQ R12, R12
+	JZ    store8
+	VBROADCASTSS (R10), Y4
+	VFMADD231PS  (R11), Y4, Y0
+	ADDQ $4, R10
+	ADDQ R8, R11
+	DECQ R12
+	JMP  loop8
+
+store8:
+	VMOVUPS Y0, (DI)
+	ADDQ $32, DI
+	ADDQ $32, BX
+	SUBQ $8, CX
+	JMP  block8
+
+done:
+	VZEROUPPER
+	RET
+
+// func gatherProductRowAVX2(c, a []float32, b [][]float32, offset int, bias float32)
+//
+// len(c) must be a multiple of 8.
+TEXT ·gatherProductRowAVX2(SB), NOSPLIT, $0-84
+	MOVQ         c_base+0(FP), DI
+	MOVQ         c_len+8(FP), CX
+	MOVQ         a_base+24(FP), SI
+	MOVQ         a_len+32(FP), DX
+	MOVQ         b_base+48(FP), BX
+	MOVQ         offset+72(FP), R8
+	SHLQ         $2, R8
+	VBROADCASTSS bias+80(FP), Y5
+
+gather32:
+	CMPQ    CX, $32
+	JL      gather8
+	VMOVAPS Y5, Y0
+	VMOVAPS Y5, Y1
+	VMOVAPS Y5, Y2
+	VMOVAPS Y5, Y3
+	MOVQ    SI, R10
+	MOVQ    BX, R11
+	MOVQ    DX, R12
+
+gatherLoop32:
+	TESTQ R12, R12
+	JZ    gatherStore32
+	MOVQ  (R11), R13
+	VBROADCASTSS (R10), Y4
+	VFMADD231PS  (R13)(R8*1), Y4, Y0
+	VFMADD231PS  32(R13)(R8*1), Y4, Y1
+	VFMADD231PS  64(R13)(R8*1), Y4, Y2
+	VFMADD231PS  96(R13)(R8*1), Y4, Y3
+	ADDQ $4, R10
+	ADDQ $24, R11
+	DECQ R12
+	JMP  gatherLoop32
+
+gatherStore32:
+	VMOVUPS Y0, (DI)
+	VMOVUPS Y1, 32(DI)
+	VMOVUPS Y2, 64(DI)
+	VMOVUPS Y3, 96(DI)
+	ADDQ $128, DI
+	ADDQ $128, R8
+	SUBQ $32, CX
+	JMP  gather32
+
+gather8:
+	CMPQ    CX, $8
+	JL      gatherDone
+	VMOVAPS Y5, Y0
+	MOVQ    SI, R10
+	MOVQ    BX, R11
+	MOVQ    DX, R12
+
+gatherLoop8:
+	TESTQ R12, R12
+	JZ    gatherStore8
+	MOVQ  (R11), R13
+	VBROADCASTSS (R10), Y4
+	VFMADD231PS  (R13)(R8*1), Y4, Y0
+	ADDQ $4, R10
+	ADDQ $24, R11
+	DECQ R12
+	JMP  gatherLoop8
+
+gatherStore8:
+	VMOVUPS Y0, (DI)
+	ADDQ $32, DI
+	ADDQ $32, R8
+	SUBQ $8, CX
+	JMP  gather8
+
+gatherDone:
+	VZEROUPPER
+	RET
+
+// func gatherProductPanelAVX2(c []float32, cStride, n int, a []float32, b [][]float32, offset int, bias []float32)
+//
+// n must be a multiple of 8.
+TEXT ·gatherProductPanelAVX2(SB), NOSPLIT, $0-120
+	MOVQ         c_base+0(FP), DI
+	MOVQ         cStride+24(FP), R9
+	SHLQ         $2, R9
+	MOVQ         n+32(FP), CX
+	MOVQ         a_base+40(FP), SI
+	MOVQ         a_len+48(FP), DX
+	SHRQ         $2, DX
+	MOVQ         b_base+64(FP), BX
+	MOVQ         offset+88(FP), R8
+	SHLQ         $2, R8
+	MOVQ         bias_base+96(FP), AX
+	VBROADCASTSS (AX), Y11
+	VBROADCASTSS 4(AX), Y12
+	VBROADCASTSS 8(AX), Y13
+	VBROADCASTSS 12(AX), Y14
+
+panel16:
+	CMPQ    CX, $16
+	JL      panel8
+	VMOVAPS Y11, Y0
+	VMOVAPS Y11, Y1
+	VMOVAPS Y12, Y2
+	VMOVAPS Y12, Y3
+	VMOVAPS Y13, Y4
+	VMOVAPS Y13, Y5
+	VMOVAPS Y14, Y6
+	VMOVAPS Y14, Y7
+	MOVQ    SI, R10
+	MOVQ    BX, R11
+	MOVQ    DX, R12
+
+panelLoop16:
+	TESTQ R12, R12
+	JZ    panelStore16
+	MOVQ  (R11), R13
+	VMOVUPS      (R13)(R8*1), Y8
+	VMOVUPS      32(R13)(R8*1), Y9
+	VBROADCASTSS (R10), Y10
+	VFMADD231PS  Y8, Y10, Y0
+	VFMADD231PS  Y9, Y10, Y1
+	VBROADCASTSS 4(R10), Y10
+	VFMADD231PS  Y8, Y10, Y2
+	VFMADD231PS  Y9, Y10, Y3
+	VBROADCASTSS 8(R10), Y10
+	VFMADD231PS  Y8, Y10, Y4
+	VFMADD231PS  Y9, Y10, Y5
+	VBROADCASTSS 12(R10), Y10
+	VFMADD231PS  Y8, Y10, Y6
+	VFMADD231PS  Y9, Y10, Y7
+	ADDQ $16, R10
+	ADDQ $24, R11
+	DECQ R12
+	JMP  panelLoop16
+
+panelStore16:
+	VMOVUPS Y0, (DI)
+	VMOVUPS Y1, 32(DI)
+	LEAQ    (DI)(R9*1), R13
+	VMOVUPS Y2, (R13)
+	VMOVUPS Y3, 32(R13)
+	ADDQ    R9, R13
+	VMOVUPS Y4, (R13)
+	VMOVUPS Y5, 32(R13)
+	ADDQ    R9, R13
+	VMOVUPS Y6, (R13)
+	VMOVUPS Y7, 32(R13)
+	ADDQ    $64, DI
+	ADDQ    $64, R8
+	SUBQ    $16, CX
+	JMP     panel16
+
+panel8:
+	CMPQ    CX, $8
+	JL      panelDone
+	VMOVAPS Y11, Y0
+	VMOVAPS Y12, Y2
+	VMOVAPS Y13, Y4
+	VMOVAPS Y14, Y6
+	MOVQ    SI, R10
+	MOVQ    BX, R11
+	MOVQ    DX, R12
+
+panelLoop8:
+	TESTQ R12, R12
+	JZ    panelStore8
+	MOVQ  (R11), R13
+	VMOVUPS      (R13)(R8*1), Y8
+	VBROADCASTSS (R10), Y10
+	VFMADD231PS  Y8, Y10, Y0
+	VBROADCASTSS 4(R10), Y10
+	VFMADD231PS  Y8, Y10, Y2
+	VBROADCASTSS 8(R10), Y10
+	VFMADD231PS  Y8, Y10, Y4
+	VBROADCASTSS 12(R10), Y10
+	VFMADD231PS  Y8, Y10, Y6
+	ADDQ $16, R10
+	ADDQ $24, R11
+	DECQ R12
+	JMP  panelLoop8
+
+panelStore8:
+	VMOVUPS Y0, (DI)
+	LEAQ    (DI)(R9*1), R13
+	VMOVUPS Y2, (R13)
+	ADDQ    R9, R13
+	VMOVUPS Y4, (R13)
+	ADDQ    R9, R13
+	VMOVUPS Y6, (R13)
+	ADDQ    $32, DI
+	ADDQ    $32, R8
+	SUBQ    $8, CX
+	JMP     panel8
+
+panelDone:
+	VZEROUPPER
+	RET
+
+// func addRowAVX2(dst, src []float32)
+//
+// len(dst) must be a multiple of 8, and not greater than len(src).
+TEXT ·addRowAVX2(SB), NOSPLIT, $0-48
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	MOVQ src_base+24(FP), SI
+
+addLoop:
+	CMPQ CX, $8
+	JL   addDone
+	VMOVUPS (DI), Y0
+	VADDPS  (SI), Y0, Y0
+	VMOVUPS Y0, (DI)
+	ADDQ $32, DI
+	ADDQ $32, SI
+	SUBQ $8, CX
+	JMP  addLoop
+
+addDone:
+	VZEROUPPER
+	RET
+
+// func mulRowAVX2(dst, src []float32)
+//
+// len(dst) must be a multiple of 8, and not greater than len(src).
+TEXT ·mulRowAVX2(SB), NOSPLIT, $0-48
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	MOVQ src_base+24(FP), SI
+
+mulLoop:
+	CMPQ CX, $8
+	JL   mulDone
+	VMOVUPS (DI), Y0
+	VMULPS  (SI), Y0, Y0
+	VMOVUPS Y0, (DI)
+	ADDQ $32, DI
+	ADDQ $32, SI
+	SUBQ $8, CX
+	JMP  mulLoop
+
+mulDone:
+	VZEROUPPER
+	RET
+
+// func addScalarRowAVX2(dst []float32, v float32)
+//
+// len(dst) must be a multiple of 8.
+TEXT ·addScalarRowAVX2(SB), NOSPLIT, $0-28
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	VBROADCASTSS v+24(FP), Y1
+
+addScalarLoop:
+	CMPQ CX, $8
+	JL   addScalarDone
+	VADDPS  (DI), Y1, Y0
+	VMOVUPS Y0, (DI)
+	ADDQ $32, DI
+	SUBQ $8, CX
+	JMP  addScalarLoop
+
+addScalarDone:
+	VZEROUPPER
+	RET
+
+// func reluRowAVX2(dst []float32)
+//
+// len(dst) must be a multiple of 8.
+TEXT ·reluRowAVX2(SB), NOSPLIT, $0-24
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	VXORPS Y1, Y1, Y1
+
+reluLoop:
+	CMPQ CX, $8
+	JL   reluDone
+	VMAXPS  (DI), Y1, Y0
+	VMOVUPS Y0, (DI)
+	ADDQ $32, DI
+	SUBQ $8, CX
+	JMP  reluLoop
+
+reluDone:
+	VZEROUPPER
+	RET
+
+// func clampRowAVX2(dst []float32, lo, hi float32)
+//
+// len(dst) must be a multiple of 8.
+TEXT ·clampRowAVX2(SB), NOSPLIT, $0-32
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	VBROADCASTSS lo+24(FP), Y1
+	VBROADCASTSS hi+28(FP), Y2
+
+clampLoop:
+	CMPQ CX, $8
+	JL   clampDone
+	VMOVUPS (DI), Y0
+	VMAXPS  Y1, Y0, Y0
+	VMINPS  Y2, Y0, Y0
+	VMOVUPS Y0, (DI)
+	ADDQ $32, DI
+	SUBQ $8, CX
+	JMP  clampLoop
+
+clampDone:
+	VZEROUPPER
+	RET
+
+// func tanhRowAVX2(dst []float32)
+//
+// len(dst) must be a multiple of 8. It computes tanh(x) = 1 - 2/(exp(2x)+1).
+TEXT ·tanhRowAVX2(SB), NOSPLIT, $0-24
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	EXP_CONSTANTS
+
+tanhLoop:
+	CMPQ CX, $8
+	JL   tanhDone
+	VMOVUPS (DI), Y0
+	VADDPS  Y0, Y0, Y0
+	EXP
+	VADDPS  Y4, Y0, Y0
+	VADDPS  Y4, Y4, Y1
+	VDIVPS  Y0, Y1, Y0
+	VSUBPS  Y0, Y4, Y0
+	VMOVUPS Y0, (DI)
+	ADDQ $32, DI
+	SUBQ $8, CX
+	JMP  tanhLoop
+
+tanhDone:
+	VZEROUPPER
+	RET
+
+// func sigmoidRowAVX2(dst []float32)
+//
+// len(dst) must be a multiple of 8. It computes 1/(1+exp(-x)).
+TEXT ·sigmoidRowAVX2(SB), NOSPLIT, $0-24
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	EXP_CONSTANTS
+
+sigmoidLoop:
+	CMPQ CX, $8
+	JL   sigmoidDone
+	VXORPS  Y0, Y0, Y0
+	VSUBPS  (DI), Y0, Y0
+	EXP
+	VADDPS  Y4, Y0, Y0
+	VDIVPS  Y0, Y4, Y0
+	VMOVUPS Y0, (DI)
+	ADDQ $32, DI
+	SUBQ $8, CX
+	JMP  sigmoidLoop
+
+sigmoidDone:
+	VZEROUPPER
+	RET
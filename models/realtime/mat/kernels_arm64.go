@@ -0,0 +1,157 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !purego && go1.27
+
+package mat
+
+import "golang.org/x/sys/cpu"
+
+// neonWidth is the amount of float32 values in a NEON register.
+const neonWidth = 4
+
+func init() {
+	if !cpu.ARM64.HasASIMD {
+		return
+	}
+	kernels32.productRow = productRowNEONGo
+	kernels32.gatherProductRow = gatherProductRowNEONGo
+	kernels32.gatherProductPanel = gatherProductPanelNEONGo
+	kernels32.addRow = addRowNEONGo
+	kernels32.mulRow = mulRowNEONGo
+	kernels32.addScalarRow = addScalarRowNEONGo
+	kernels32.tanhRow = tanhRowNEONGo
+	kernels32.sigmoidRow = sigmoidRowNEONGo
+	kernels32.reluRow = reluRowNEONGo
+	kernels32.clampRow = clampRowNEONGo
+}
+
+// The NEON kernels process the largest prefix of the rows multiple of
+// neonWidth, leaving the rest to the Go implementations.
+
+//go:noescape
+func productRowNEON(c, a, b []float32, bStride int, add bool)
+
+//go:noescape
+func gatherProductRowNEON(c, a []float32, b [][]float32, offset int, bias float32)
+
+//go:noescape
+func gatherProductPanelNEON(c []float32, cStride, n int, a []float32, b [][]float32, offset int, bias []float32)
+
+//go:noescape
+func addRowNEON(dst, src []float32)
+
+//go:noescape
+func mulRowNEON(dst, src []float32)
+
+//go:noescape
+func addScalarRowNEON(dst []float32, v float32)
+
+//go:noescape
+func tanhRowNEON(dst []float32)
+
+//go:noescape
+func sigmoidRowNEON(dst []float32)
+
+//go:noescape
+func reluRowNEON(dst []float32)
+
+//go:noescape
+func clampRowNEON(dst []float32, lo, hi float32)
+
+func productRowNEONGo(c, a, b []float32, bStride int, add bool) {
+	n := len(c) &^ (neonWidth - 1)
+	if n > 0 {
+		if len(a) > 0 {
+			_ = b[(len(a)-1)*bStride+n-1]
+		}
+		productRowNEON(c[:n], a, b, bStride, add)
+	}
+	if n < len(c) {
+		if len(a) > 0 {
+			b = b[n:]
+		}
+		productRowGeneric(c[n:], a, b, bStride, add)
+	}
+}
+
+func gatherProductRowNEONGo(c, a []float32, b [][]float32, offset int, bias float32) {
+	n := len(c) &^ (neonWidth - 1)
+	if n > 0 {
+		for _, row := range b[:len(a)] {
+			_ = row[offset+n-1]
+		}
+		gatherProductRowNEON(c[:n], a, b, offset, bias)
+	}
+	gatherProductRowGeneric(c[n:], a, b, offset+n, bias)
+}
+
+func gatherProductPanelNEONGo(c []float32, cStride, n int, a []float32, b [][]float32, offset int, bias []float32) {
+	m := n &^ (neonWidth - 1)
+	if m > 0 {
+		_ = c[(panelRows-1)*cStride+m-1]
+		_ = bias[panelRows-1]
+		for _, row := range b[:len(a)/panelRows] {
+			_ = row[offset+m-1]
+		}
+		gatherProductPanelNEON(c, cStride, m, a, b, offset, bias)
+	}
+	if m < n {
+		gatherProductPanelGeneric(c[m:], cStride, n-m, a, b, offset+m, bias)
+	}
+}
+
+func addRowNEONGo(dst, src []float32) {
+	dst = dst[:len(src)]
+	n := len(src) &^ (neonWidth - 1)
+	addRowNEON(dst[:n], src)
+	addRowGeneric(dst[n:], src[n:])
+}
+
+func mulRowNEONGo(dst, src []float32) {
+	dst = dst[:len(src)]
+	n := len(src) &^ (neonWidth - 1)
+	mulRowNEON(dst[:n], src)
+	mulRowGeneric(dst[n:], src[n:])
+}
+
+func addScalarRowNEONGo(dst []float32, v float32) {
+	n := len(dst) &^ (neonWidth - 1)
+	addScalarRowNEON(dst[:n], v)
+	addScalarRowGeneric(dst[n:], v)
+}
+
+func tanhRowNEONGo(dst []float32) {
+	n := len(dst) &^ (neonWidth - 1)
+	tanhRowNEON(dst[:n])
+	tanhRowGeneric(dst[n:])
+}
+
+func sigmoidRowNEONGo(dst []float32) {
+	n := len(dst) &^ (neonWidth - 1)
+	sigmoidRowNEON(dst[:n])
+	sigmoidRowGeneric(dst[n:])
+}
+
+func reluRowNEONGo(dst []float32) {
+	n := len(dst) &^ (neonWidth - 1)
+	reluRowNEON(dst[:n])
+	reluRowGeneric(dst[n:])
+}
+
+func clampRowNEONGo(dst []float32, lo, hi float32) {
+	n := len(dst) &^ (neonWidth - 1)
+	clampRowNEON(dst[:n], lo, hi)
+	clampRowGeneric(dst[n:], lo, hi)
+}
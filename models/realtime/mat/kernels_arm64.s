@@ -0,0 +1,539 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !purego && go1.27
+
+#include "textflag.h"
+
+// The vector floating-point instructions, such as VFADD and VFRINTM, need
+// the assembler of a recent toolchain: with Go releases before 1.27, only
+// the Go kernels are built.
+
+// Constants of the exponential function approximation, from Cephes expf:
+// x is reduced as x = g + n*ln(2), with |g| <= ln(2)/2, so that
+// exp(x) = 2^n * exp(g), and exp(g) is approximated by a polynomial.
+DATA expOne<>+0(SB)/4, $0x3f800000   // 1
+GLOBL expOne<>(SB), RODATA|NOPTR, $4
+DATA expTwo<>+0(SB)/4, $0x40000000   // 2
+GLOBL expTwo<>(SB), RODATA|NOPTR, $4
+DATA expHi<>+0(SB)/4, $0x42b0c0a5    // 88.3762626647949
+GLOBL expHi<>(SB), RODATA|NOPTR, $4
+DATA expLo<>+0(SB)/4, $0xc2b0c0a5    // -88.3762626647949
+GLOBL expLo<>(SB), RODATA|NOPTR, $4
+DATA expLog2e<>+0(SB)/4, $0x3fb8aa3b // log2(e)
+GLOBL expLog2e<>(SB), RODATA|NOPTR, $4
+DATA expHalf<>+0(SB)/4, $0x3f000000  // 0.5
+GLOBL expHalf<>(SB), RODATA|NOPTR, $4
+DATA expC1<>+0(SB)/4, $0x3f318000    // ln(2), high part
+GLOBL expC1<>(SB), RODATA|NOPTR, $4
+DATA expC2<>+0(SB)/4, $0xb95e8083    // ln(2), low part, negated
+GLOBL expC2<>(SB), RODATA|NOPTR, $4
+DATA expP0<>+0(SB)/4, $0x39506967    // 1.9875691500e-4
+GLOBL expP0<>(SB), RODATA|NOPTR, $4
+DATA expP1<>+0(SB)/4, $0x3ab743ce    // 1.3981999507e-3
+GLOBL expP1<>(SB), RODATA|NOPTR, $4
+DATA expP2<>+0(SB)/4, $0x3c088908    // 8.3334519073e-3
+GLOBL expP2<>(SB), RODATA|NOPTR, $4
+DATA expP3<>+0(SB)/4, $0x3d2aa9c1    // 4.1665795894e-2
+GLOBL expP3<>(SB), RODATA|NOPTR, $4
+DATA expP4<>+0(SB)/4, $0x3e2aaaaa    // 1.6666665459e-1
+GLOBL expP4<>(SB), RODATA|NOPTR, $4
+DATA expBias<>+0(SB)/4, $127         // float32 exponent bias
+GLOBL expBias<>(SB), RODATA|NOPTR, $4
+
+#define LOAD_CONSTANT(name, reg) \
+	MOVD  $name<>(SB), R3 \
+	VLD1R (R3), [reg.S4]
+
+// EXP_CONSTANTS loads the constants used by EXP into V16-V29.
+#define EXP_CONSTANTS \
+	LOAD_CONSTANT(expOne, V16)   \
+	LOAD_CONSTANT(expHi, V17)    \
+	LOAD_CONSTANT(expLo, V18)    \
+	LOAD_CONSTANT(expLog2e, V19) \
+	LOAD_CONSTANT(expHalf, V20)  \
+	LOAD_CONSTANT(expC1, V21)    \
+	LOAD_CONSTANT(expC2, V22)    \
+	LOAD_CONSTANT(expP0, V23)    \
+	LOAD_CONSTANT(expP1, V24)    \
+	LOAD_CONSTANT(expP2, V25)    \
+	LOAD_CONSTANT(expP3, V26)    \
+	LOAD_CONSTANT(expP4, V27)    \
+	LOAD_CONSTANT(expBias, V28)  \
+	LOAD_CONSTANT(expTwo, V29)
+
+// EXP computes V0 = exp(V0), clobbering V1-V4. The last coefficient of
+// the polynomial rounds to 0.5, so V20 is used for it.
+#define EXP \
+	VFMIN   V17.S4, V0.S4, V0.S4 \
+	VFMAX   V18.S4, V0.S4, V0.S4 \
+	VMOV    V20.B16, V1.B16      \
+	VFMLA   V19.S4, V0.S4, V1.S4 \
+	VFRINTM V1.S4, V1.S4         \
+	VFMLS   V21.S4, V1.S4, V0.S4 \
+	VFMLS   V22.S4, V1.S4, V0.S4 \
+	VFMUL   V0.S4, V0.S4, V2.S4  \
+	VMOV    V24.B16, V4.B16      \
+	VFMLA   V23.S4, V0.S4, V4.S4 \
+	VMOV    V25.B16, V3.B16      \
+	VFMLA   V4.S4, V0.S4, V3.S4  \
+	VMOV    V26.B16, V4.B16      \
+	VFMLA   V3.S4, V0.S4, V4.S4  \
+	VMOV    V27.B16, V3.B16      \
+	VFMLA   V4.S4, V0.S4, V3.S4  \
+	VMOV    V20.B16, V4.B16      \
+	VFMLA   V3.S4, V0.S4, V4.S4  \
+	VFMLA   V4.S4, V2.S4, V0.S4  \
+	VFADD   V16.S4, V0.S4, V0.S4 \
+	VFCVTZS V1.S4, V1.S4         \
+	VADD    V28.S4, V1.S4, V1.S4 \
+	VSHL    $23, V1.S4, V1.S4    \
+	VFMUL   V1.S4, V0.S4, V0.S4
+
+// func productRowNEON(c, a, b []float32, bStride int, add bool)
+//
+// len(c) must be a multiple of 4.
+TEXT ·productRowNEON(SB), NOSPLIT, $0-81
+	MOVD  c_base+0(FP), R0
+	MOVD  c_len+8(FP), R1
+	MOVD  a_base+24(FP), R2
+	MOVD  a_len+32(FP), R3
+	MOVD  b_base+48(FP), R4
+	MOVD  bStride+72(FP), R5
+	LSL   $2, R5
+	MOVBU add+80(FP), R6
+
+block16:
+	CMP  $16, R1
+	BLT  block4
+	CBZ  R6, zero16
+	VLD1 (R0), [V0.S4, V1.S4, V2.S4, V3.S4]
+	B    accumulate16
+
+zero16:
+	VEOR V0.B16, V0.B16, V0.B16
+	VEOR V1.B16, V1.B16, V1.B16
+	VEOR V2.B16, V2.B16, V2.B16
+	VEOR V3.B16, V3.B16, V3.B16
+
+accumulate16:
+	MOVD R2, R7
+	MOVD R4, R8
+	MOVD R3, R9
+
+loop16:
+	CBZ   R9, store16
+	VLD1R (R7), [V4.S4]
+	VLD1  (R8), [V5.S4, V6.S4, V7.S4, V8.S4]
+	VFMLA V4.S4, V5.S4, V0.S4
+	VFMLA V4.S4, V6.S4, V1.S4
+	VFMLA V4.S4, V7.S4, V2.S4
+	VFMLA V4.S4, V8.S4, V3.S4
+	ADD   $4, R7
+	ADD   R5, R8
+	SUB   $1, R9
+	B     loop16
+
+store16:
+	VST1.P [V0.S4, V1.S4, V2.S4, V3.S4], 64(R0)
+	ADD    $64, R4
+	SUB    $16, R1
+	B      block16
+
+block4:
+	CMP  $4, R1
+	BLT  done
+	CBZ  R6, zero4
+	VLD1 (R0), [V0.S4]
+	B    accumulate4
+
+zero4:
+	VEOR V0.B16, V0.B16, V0.B16
+
+accumulate4:
+	MOVD R2, R7
+	MOVD R4, R8
+	MOVD R3, R9
+
+loop4:
+	CBZ   R9, store4
+	VLD1R (R7), [V4.S4]
+	VLD1  (R8), [V5.S4]
+	VFMLA V4.S4, V5.S4, V0.S4
+	ADD   $4, R7
+	ADD   R5, R8
+	SUB   $1, R9
+	B     loop4
+
+store4:
+	VST1.P [V0.S4], 16(R0)
+	ADD    $16, R4
+	SUB    $4, R1
+	B      block4
+
+done:
+	RET
+
+// func gatherProductRowNEON(c, a []float32, b [][]float32, offset int, bias float32)
+//
+// len(c) must be a multiple of 4.
+TEXT ·gatherProductRowNEON(SB), NOSPLIT, $0-84
+	MOVD  c_base+0(FP), R0
+	MOVD  c_len+8(FP), R1
+	MOVD  a_base+24(FP), R2
+	MOVD  a_len+32(FP), R3
+	MOVD  b_base+48(FP), R4
+	MOVD  offset+72(FP), R5
+	LSL   $2, R5
+	MOVWU bias+80(FP), R6
+	VDUP  R6, V9.S4
+
+gather16:
+	CMP  $16, R1
+	BLT  gather4
+	VORR V9.B16, V9.B16, V0.B16
+	VORR V9.B16, V9.B16, V1.B16
+	VORR V9.B16, V9.B16, V2.B16
+	VORR V9.B16, V9.B16, V3.B16
+	MOVD R2, R7
+	MOVD R4, R8
+	MOVD R3, R9
+
+gatherLoop16:
+	CBZ   R9, gatherStore16
+	MOVD  (R8), R10
+	ADD   R5, R10
+	VLD1R (R7), [V4.S4]
+	VLD1  (R10), [V5.S4, V6.S4, V7.S4, V8.S4]
+	VFMLA V4.S4, V5.S4, V0.S4
+	VFMLA V4.S4, V6.S4, V1.S4
+	VFMLA V4.S4, V7.S4, V2.S4
+	VFMLA V4.S4, V8.S4, V3.S4
+	ADD   $4, R7
+	ADD   $24, R8
+	SUB   $1, R9
+	B     gatherLoop16
+
+gatherStore16:
+	VST1.P [V0.S4, V1.S4, V2.S4, V3.S4], 64(R0)
+	ADD    $64, R5
+	SUB    $16, R1
+	B      gather16
+
+gather4:
+	CMP  $4, R1
+	BLT  gatherDone
+	VORR V9.B16, V9.B16, V0.B16
+	MOVD R2, R7
+	MOVD R4, R8
+	MOVD R3, R9
+
+gatherLoop4:
+	CBZ   R9, gatherStore4
+	MOVD  (R8), R10
+	ADD   R5, R10
+	VLD1R (R7), [V4.S4]
+	VLD1  (R10), [V5.S4]
+	VFMLA V4.S4, V5.S4, V0.S4
+	ADD   $4, R7
+	ADD   $24, R8
+	SUB   $1, R9
+	B     gatherLoop4
+
+gatherStore4:
+	VST1.P [V0.S4], 16(R0)
+	ADD    $16, R5
+	SUB    $4, R1
+	B      gather4
+
+gatherDone:
+	RET
+
+// func gatherProductPanelNEON(c []float32, cStride, n int, a []float32, b [][]float32, offset int, bias []float32)
+//
+// n must be a multiple of 4.
+TEXT ·gatherProductPanelNEON(SB), NOSPLIT, $0-120
+	MOVD  c_base+0(FP), R0
+	MOVD  cStride+24(FP), R11
+	LSL   $2, R11
+	MOVD  n+32(FP), R1
+	MOVD  a_base+40(FP), R2
+	MOVD  a_len+48(FP), R3
+	LSR   $2, R3
+	MOVD  b_base+64(FP), R4
+	MOVD  offset+88(FP), R5
+	LSL   $2, R5
+	MOVD  bias_base+96(FP), R6
+	VLD1R.P 4(R6), [V21.S4]
+	VLD1R.P 4(R6), [V22.S4]
+	VLD1R.P 4(R6), [V23.S4]
+	VLD1R   (R6), [V24.S4]
+
+panel16:
+	CMP  $16, R1
+	BLT  panel4
+	VORR V21.B16, V21.B16, V0.B16
+	VORR V21.B16, V21.B16, V1.B16
+	VORR V21.B16, V21.B16, V2.B16
+	VORR V21.B16, V21.B16, V3.B16
+	VORR V22.B16, V22.B16, V4.B16
+	VORR V22.B16, V22.B16, V5.B16
+	VORR V22.B16, V22.B16, V6.B16
+	VORR V22.B16, V22.B16, V7.B16
+	VORR V23.B16, V23.B16, V8.B16
+	VORR V23.B16, V23.B16, V9.B16
+	VORR V23.B16, V23.B16, V10.B16
+	VORR V23.B16, V23.B16, V11.B16
+	VORR V24.B16, V24.B16, V12.B16
+	VORR V24.B16, V24.B16, V13.B16
+	VORR V24.B16, V24.B16, V14.B16
+	VORR V24.B16, V24.B16, V15.B16
+	MOVD R2, R7
+	MOVD R4, R8
+	MOVD R3, R9
+
+panelLoop16:
+	CBZ   R9, panelStore16
+	MOVD  (R8), R10
+	ADD   R5, R10
+	VLD1  (R10), [V16.S4, V17.S4, V18.S4, V19.S4]
+	VLD1R.P 4(R7), [V20.S4]
+	VFMLA V20.S4, V16.S4, V0.S4
+	VFMLA V20.S4, V17.S4, V1.S4
+	VFMLA V20.S4, V18.S4, V2.S4
+	VFMLA V20.S4, V19.S4, V3.S4
+	VLD1R.P 4(R7), [V20.S4]
+	VFMLA V20.S4, V16.S4, V4.S4
+	VFMLA V20.S4, V17.S4, V5.S4
+	VFMLA V20.S4, V18.S4, V6.S4
+	VFMLA V20.S4, V19.S4, V7.S4
+	VLD1R.P 4(R7), [V20.S4]
+	VFMLA V20.S4, V16.S4, V8.S4
+	VFMLA V20.S4, V17.S4, V9.S4
+	VFMLA V20.S4, V18.S4, V10.S4
+	VFMLA V20.S4, V19.S4, V11.S4
+	VLD1R.P 4(R7), [V20.S4]
+	VFMLA V20.S4, V16.S4, V12.S4
+	VFMLA V20.S4, V17.S4, V13.S4
+	VFMLA V20.S4, V18.S4, V14.S4
+	VFMLA V20.S4, V19.S4, V15.S4
+	ADD   $24, R8
+	SUB   $1, R9
+	B     panelLoop16
+
+panelStore16:
+	MOVD R0, R12
+	VST1 [V0.S4, V1.S4, V2.S4, V3.S4], (R12)
+	ADD  R11, R12
+	VST1 [V4.S4, V5.S4, V6.S4, V7.S4], (R12)
+	ADD  R11, R12
+	VST1 [V8.S4, V9.S4, V10.S4, V11.S4], (R12)
+	ADD  R11, R12
+	VST1 [V12.S4, V13.S4, V14.S4, V15.S4], (R12)
+	ADD  $64, R0
+	ADD  $64, R5
+	SUB  $16, R1
+	B    panel16
+
+panel4:
+	CMP  $4, R1
+	BLT  panelDone
+	VORR V21.B16, V21.B16, V0.B16
+	VORR V22.B16, V22.B16, V4.B16
+	VORR V23.B16, V23.B16, V8.B16
+	VORR V24.B16, V24.B16, V12.B16
+	MOVD R2, R7
+	MOVD R4, R8
+	MOVD R3, R9
+
+panelLoop4:
+	CBZ   R9, panelStore4
+	MOVD  (R8), R10
+	ADD   R5, R10
+	VLD1  (R10), [V16.S4]
+	VLD1R.P 4(R7), [V20.S4]
+	VFMLA V20.S4, V16.S4, V0.S4
+	VLD1R.P 4(R7), [V20.S4]
+	VFMLA V20.S4, V16.S4, V4.S4
+	VLD1R.P 4(R7), [V20.S4]
+	VFMLA V20.S4, V16.S4, V8.S4
+	VLD1R.P 4(R7), [V20.S4]
+	VFMLA V20.S4, V16.S4, V12.S4
+	ADD   $24, R8
+	SUB   $1, R9
+	B     panelLoop4
+
+panelStore4:
+	MOVD R0, R12
+	VST1 [V0.S4], (R12)
+	ADD  R11, R12
+	VST1 [V4.S4], (R12)
+	ADD  R11, R12
+	VST1 [V8.S4], (R12)
+	ADD  R11, R12
+	VST1 [V12.S4], (R12)
+	ADD  $16, R0
+	ADD  $16, R5
+	SUB  $4, R1
+	B    panel4
+
+panelDone:
+	RET
+
+// func addRowNEON(dst, src []float32)
+//
+// len(dst) must be a multiple of 4, and not greater than len(src).
+TEXT ·addRowNEON(SB), NOSPLIT, $0-48
+	MOVD dst_base+0(FP), R0
+	MOVD dst_len+8(FP), R1
+	MOVD src_base+24(FP), R2
+
+addLoop:
+	CBZ    R1, addDone
+	VLD1   (R0), [V0.S4]
+	VLD1.P 16(R2), [V1.S4]
+	VFADD  V1.S4, V0.S4, V0.S4
+	VST1.P [V0.S4], 16(R0)
+	SUB    $4, R1
+	B      addLoop
+
+addDone:
+	RET
+
+// func mulRowNEON(dst, src []float32)
+//
+// len(dst) must be a multiple of 4, and not greater than len(src).
+TEXT ·mulRowNEON(SB), NOSPLIT, $0-48
+	MOVD dst_base+0(FP), R0
+	MOVD dst_len+8(FP), R1
+	MOVD src_base+24(FP), R2
+
+mulLoop:
+	CBZ    R1, mulDone
+	VLD1   (R0), [V0.S4]
+	VLD1.P 16(R2), [V1.S4]
+	VFMUL  V1.S4, V0.S4, V0.S4
+	VST1.P [V0.S4], 16(R0)
+	SUB    $4, R1
+	B      mulLoop
+
+mulDone:
+	RET
+
+// func addScalarRowNEON(dst []float32, v float32)
+//
+// len(dst) must be a multiple of 4.
+TEXT ·addScalarRowNEON(SB), NOSPLIT, $0-28
+	MOVD  dst_base+0(FP), R0
+	MOVD  dst_len+8(FP), R1
+	MOVD  $v+24(FP), R2
+	VLD1R (R2), [V1.S4]
+
+addScalarLoop:
+	CBZ    R1, addScalarDone
+	VLD1   (R0), [V0.S4]
+	VFADD  V1.S4, V0.S4, V0.S4
+	VST1.P [V0.S4], 16(R0)
+	SUB    $4, R1
+	B      addScalarLoop
+
+addScalarDone:
+	RET
+
+// func reluRowNEON(dst []float32)
+//
+// len(dst) must be a multiple of 4.
+TEXT ·reluRowNEON(SB), NOSPLIT, $0-24
+	MOVD dst_base+0(FP), R0
+	MOVD dst_len+8(FP), R1
+	VEOR V1.B16, V1.B16, V1.B16
+
+reluLoop:
+	CBZ    R1, reluDone
+	VLD1   (R0), [V0.S4]
+	VFMAX  V1.S4, V0.S4, V0.S4
+	VST1.P [V0.S4], 16(R0)
+	SUB    $4, R1
+	B      reluLoop
+
+reluDone:
+	RET
+
+// func clampRowNEON(dst []float32, lo, hi float32)
+//
+// len(dst) must be a multiple of 4.
+TEXT ·clampRowNEON(SB), NOSPLIT, $0-32
+	MOVD  dst_base+0(FP), R0
+	MOVD  dst_len+8(FP), R1
+	MOVD  $lo+24(FP), R2
+	VLD1R (R2), [V1.S4]
+	MOVD  $hi+28(FP), R2
+	VLD1R (R2), [V2.S4]
+
+clampLoop:
+	CBZ    R1, clampDone
+	VLD1   (R0), [V0.S4]
+	VFMAX  V1.S4, V0.S4, V0.S4
+	VFMIN  V2.S4, V0.S4, V0.S4
+	VST1.P [V0.S4], 16(R0)
+	SUB    $4, R1
+	B      clampLoop
+
+clampDone:
+	RET
+
+// func tanhRowNEON(dst []float32)
+//
+// len(dst) must be a multiple of 4. It computes tanh(x) = 1 - 2/(exp(2x)+1).
+TEXT ·tanhRowNEON(SB), NOSPLIT, $0-24
+	MOVD dst_base+0(FP), R0
+	MOVD dst_len+8(FP), R1
+	EXP_CONSTANTS
+
+tanhLoop:
+	CBZ    R1, tanhDone
+	VLD1   (R0), [V0.S4]
+	VFADD  V0.S4, V0.S4, V0.S4
+	EXP
+	VFADD  V16.S4, V0.S4, V0.S4
+	VFDIV  V0.S4, V29.S4, V0.S4
+	VFSUB  V0.S4, V16.S4, V0.S4
+	VST1.P [V0.S4], 16(R0)
+	SUB    $4, R1
+	B      tanhLoop
+
+tanhDone:
+	RET
+
+// func sigmoidRowNEON(dst []float32)
+//
+// len(dst) must be a multiple of 4. It computes 1/(1+exp(-x)).
+TEXT ·sigmoidRowNEON(SB), NOSPLIT, $0-24
+	MOVD dst_base+0(FP), R0
+	MOVD dst_len+8(FP), R1
+	EXP_CONSTANTS
+
+sigmoidLoop:
+	CBZ    R1, sigmoidDone
+	VLD1   (R0), [V0.S4]
+	VFNEG  V0.S4, V0.S4
+	EXP
+	VFADD  V16.S4, V0.S4, V0.S4
+	VFDIV  V0.S4, V16.S4, V0.S4
+	VST1.P [V0.S4], 16(R0)
+	SUB    $4, R1
+	B      sigmoidLoop
+
+sigmoidDone:
+	RET
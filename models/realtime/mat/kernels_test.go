@@ -0,0 +1,245 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mat
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// The tests below compare the row kernels in use, possibly vectorized,
+// with the Go implementations. Slices start at varying offsets, so that
+// unaligned data and tails of any length are covered.
+
+func randSlice(rnd *rand.Rand, n int, scale float32) []float32 {
+	s := make([]float32, n)
+	for i := range s {
+		s[i] = (rnd.Float32()*2 - 1) * scale
+	}
+	return s
+}
+
+func assertClose(t *testing.T, name string, expected, actual []float32, tolerance float64) {
+	t.Helper()
+	for i, v := range expected {
+		if d := math.Abs(float64(actual[i] - v)); d > tolerance*max(1, math.Abs(float64(v))) {
+			t.Fatalf("%s: index %d of %d: expected %g, actual %g", name, i, len(expected), v, actual[i])
+		}
+	}
+}
+
+func TestRowKernels(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for n := 0; n < 80; n++ {
+		offset := rnd.Intn(8)
+		src := randSlice(rnd, n+offset, 4)[offset:]
+		dst := randSlice(rnd, n+offset, 4)[offset:]
+
+		elementWise := []struct {
+			name      string
+			actual    func(dst []float32)
+			expected  func(dst []float32)
+			tolerance float64
+		}{
+			{"add", func(d []float32) { kernels32.addRow(d, src) }, func(d []float32) { addRowGeneric(d, src) }, 0},
+			{"mul", func(d []float32) { kernels32.mulRow(d, src) }, func(d []float32) { mulRowGeneric(d, src) }, 0},
+			{"addScalar", func(d []float32) { kernels32.addScalarRow(d, 0.3) }, func(d []float32) { addScalarRowGeneric(d, 0.3) }, 0},
+			{"tanh", kernels32.tanhRow, tanhRowGeneric, 1e-6},
+			{"sigmoid", kernels32.sigmoidRow, sigmoidRowGeneric, 1e-6},
+			{"relu", kernels32.reluRow, reluRowGeneric, 0},
+			{"clamp", func(d []float32) { kernels32.clampRow(d, -1, 0.5) }, func(d []float32) { clampRowGeneric(d, -1, 0.5) }, 0},
+		}
+		for _, k := range elementWise {
+			expected := append([]float32(nil), dst...)
+			actual := append([]float32(nil), dst...)
+			k.expected(expected)
+			k.actual(actual)
+			assertClose(t, k.name, expected, actual, k.tolerance)
+		}
+	}
+}
+
+func TestActivationKernelsExtremes(t *testing.T) {
+	values := []float32{0, -0, 1e-8, -1e-8, 1e-3, 10, -10, 44, -44, 88, -88, 100, -100, 1e30, -1e30,
+		float32(math.Inf(1)), float32(math.Inf(-1))}
+	for len(values)%8 != 0 {
+		values = append(values, 0.5)
+	}
+	for _, k := range []struct {
+		name             string
+		actual, expected func([]float32)
+	}{
+		{"tanh", kernels32.tanhRow, tanhRowGeneric},
+		{"sigmoid", kernels32.sigmoidRow, sigmoidRowGeneric},
+	} {
+		expected := append([]float32(nil), values...)
+		actual := append([]float32(nil), values...)
+		k.expected(expected)
+		k.actual(actual)
+		for i, v := range expected {
+			if math.Abs(float64(actual[i]-v)) > 1e-6 {
+				t.Errorf("%s(%g): expected %g, actual %g", k.name, values[i], v, actual[i])
+			}
+		}
+	}
+}
+
+// randView returns a random matrix, possibly a view of a larger one.
+func randView(rnd *rand.Rand, rows, columns int) Matrix[float32] {
+	if rows == 0 {
+		return NewMatrix[float32](0, columns)
+	}
+	extra := rnd.Intn(3)
+	offset := rnd.Intn(extra + 1)
+	m := NewMatrix[float32](rows, columns+extra)
+	for i := range m.data {
+		m.data[i] = rnd.Float32()*2 - 1
+	}
+	return m.ViewMiddleColumns(offset, columns)
+}
+
+// referenceProduct computes C = A * B (or C += A * B) with the Go
+// implementation, into a new matrix.
+func referenceProduct(a, b, c Matrix[float32], add bool) Matrix[float32] {
+	result := c.Clone()
+	if result.rows == 0 {
+		result = NewMatrix[float32](c.rows, c.viewColumns)
+	}
+	for i := 0; i < c.rows; i++ {
+		productRowGeneric(result.getRow(i), a.getRow(i), b.data, b.dataColumns, add)
+	}
+	return result
+}
+
+func fuzzProduct(t *testing.T, seed int64, rows, inner, columns uint8, add bool) {
+	rnd := rand.New(rand.NewSource(seed))
+	r, k, c := int(rows%20), int(inner%40), int(columns%100)
+	a := randView(rnd, r, k)
+	b := randView(rnd, k, c)
+	out := randView(rnd, r, c)
+	expected := referenceProduct(a, b, out, add)
+	if add {
+		AddProduct(a, b, out)
+	} else {
+		Product(a, b, out)
+	}
+	for i := 0; i < r; i++ {
+		// Rounding differs with fused multiply-add.
+		assertClose(t, "product", expected.getRow(i), out.getRow(i), float64(k+1)*1e-6)
+	}
+}
+
+func FuzzProduct(f *testing.F) {
+	f.Add(int64(1), uint8(1), uint8(1), uint8(1))
+	f.Add(int64(2), uint8(16), uint8(32), uint8(64))
+	f.Add(int64(3), uint8(3), uint8(7), uint8(41))
+	f.Fuzz(func(t *testing.T, seed int64, rows, inner, columns uint8) {
+		fuzzProduct(t, seed, rows, inner, columns, false)
+	})
+}
+
+func FuzzAddProduct(f *testing.F) {
+	f.Add(int64(1), uint8(1), uint8(1), uint8(1))
+	f.Add(int64(2), uint8(16), uint8(32), uint8(64))
+	f.Add(int64(3), uint8(3), uint8(7), uint8(41))
+	f.Fuzz(func(t *testing.T, seed int64, rows, inner, columns uint8) {
+		fuzzProduct(t, seed, rows, inner, columns, true)
+	})
+}
+
+func FuzzElementWise(f *testing.F) {
+	f.Add(int64(1), uint8(5), uint8(8), float32(1))
+	f.Add(int64(2), uint8(16), uint8(33), float32(30))
+	f.Fuzz(func(t *testing.T, seed int64, rows, columns uint8, scale float32) {
+		if math.IsNaN(float64(scale)) || math.IsInf(float64(scale), 0) || math.Abs(float64(scale)) > 1e6 {
+			t.Skip()
+		}
+		rnd := rand.New(rand.NewSource(seed))
+		r, c := int(rows%20), int(columns%100)
+		a := randView(rnd, r, c)
+		b := randView(rnd, r, c)
+		v := NewVectorFromSlice(randSlice(rnd, r, scale))
+		for i := 0; i < r; i++ {
+			row := a.getRow(i)
+			for j := range row {
+				row[j] *= scale
+			}
+		}
+
+		for _, op := range []struct {
+			name      string
+			actual    func(m Matrix[float32])
+			expected  func(row []float32, i int)
+			tolerance float64
+		}{
+			{"AddInPlace", func(m Matrix[float32]) { AddInPlace(m, b) }, func(row []float32, i int) { addRowGeneric(row, b.getRow(i)) }, 0},
+			{"MulInPlace", func(m Matrix[float32]) { MulInPlace(m, b) }, func(row []float32, i int) { mulRowGeneric(row, b.getRow(i)) }, 0},
+			{"AddInPlaceColumnWise", func(m Matrix[float32]) { AddInPlaceColumnWise(m, v) }, func(row []float32, i int) { addScalarRowGeneric(row, v.Get(i)) }, 0},
+			{"TanhInPlace", Matrix[float32].TanhInPlace, func(row []float32, _ int) { tanhRowGeneric(row) }, 1e-6},
+			{"SigmoidInPlace", Matrix[float32].SigmoidInPlace, func(row []float32, _ int) { sigmoidRowGeneric(row) }, 1e-6},
+			{"ReLUInPlace", Matrix[float32].ReLUInPlace, func(row []float32, _ int) { reluRowGeneric(row) }, 0},
+			{"ClampInPlace", func(m Matrix[float32]) { m.ClampInPlace(-0.5, 0.5) }, func(row []float32, _ int) { clampRowGeneric(row, -0.5, 0.5) }, 0},
+		} {
+			actual := a.Clone()
+			expected := a.Clone()
+			op.actual(actual)
+			for i := 0; i < r; i++ {
+				op.expected(expected.getRow(i), i)
+			}
+			for i := 0; i < r; i++ {
+				assertClose(t, op.name, expected.getRow(i), actual.getRow(i), op.tolerance)
+			}
+		}
+	})
+}
+
+func TestFloat64Operators(t *testing.T) {
+	assertClose64 := func(name string, expected [][]float64, actual Matrix[float64]) {
+		t.Helper()
+		for i, row := range expected {
+			for j, v := range row {
+				if math.Abs(actual.Get(i, j)-v) > 1e-15 {
+					t.Errorf("%s: at %dx%d expected %v, actual %v", name, i, j, v, actual.Get(i, j))
+				}
+			}
+		}
+	}
+
+	// The small terms would be lost in float32.
+	a := NewMatrixFromSlices([][]float64{{1, 1e-10}, {-1e-12, 1}})
+	b := NewMatrixFromSlices([][]float64{{1, 2}, {3, 4}})
+	c := NewMatrix[float64](2, 2)
+	Product(a, b, c)
+	assertClose64("product", [][]float64{
+		{1 + 3e-10, 2 + 4e-10},
+		{3 - 1e-12, 4 - 2e-12},
+	}, c)
+
+	var fp FusedProduct[float64]
+	fp.SetWeights(NewVectorFromSlice([]float64{1e-11, 0}), a)
+	fp.AddInput(b, 0)
+	fp.Compute(c, nil)
+	assertClose64("fused product", [][]float64{
+		{1 + 3e-10 + 1e-11, 2 + 4e-10 + 1e-11},
+		{3 - 1e-12, 4 - 2e-12},
+	}, c)
+
+	c.TanhInPlace()
+	assertClose64("tanh", [][]float64{
+		{math.Tanh(1 + 3e-10 + 1e-11), math.Tanh(2 + 4e-10 + 1e-11)},
+		{math.Tanh(3 - 1e-12), math.Tanh(4 - 2e-12)},
+	}, c)
+}
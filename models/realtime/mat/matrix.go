@@ -19,61 +19,66 @@ import (
 	"strings"
 )
 
-type Matrix struct {
+// Float is the constraint of the element types of matrices.
+type Float interface {
+	float32 | float64
+}
+
+type Matrix[T Float] struct {
 	rows        int
 	dataColumns int
 	viewColumns int
-	data        []float32
+	data        []T
 }
 
-func NewMatrix(rows, columns int) Matrix {
-	return Matrix{
+func NewMatrix[T Float](rows, columns int) Matrix[T] {
+	return Matrix[T]{
 		rows:        rows,
 		dataColumns: columns,
 		viewColumns: columns,
-		data:        make([]float32, rows*columns),
+		data:        make([]T, rows*columns),
 	}
 }
 
-func NewMatrixFromSlices(data [][]float32) Matrix {
+func NewMatrixFromSlices[T Float](data [][]T) Matrix[T] {
 	rows := len(data)
 	if rows == 0 {
-		return Matrix{}
+		return Matrix[T]{}
 	}
 	columns := len(data[0])
-	m := NewMatrix(rows, columns)
+	m := NewMatrix[T](rows, columns)
 	for i, rowData := range data {
 		copy(m.data[i*columns:i*columns+columns], rowData)
 	}
 	return m
 }
 
-func (m Matrix) Rows() int {
+func (m Matrix[T]) Rows() int {
 	return m.rows
 }
 
-func (m Matrix) Columns() int {
+func (m Matrix[T]) Columns() int {
 	return m.viewColumns
 }
 
-func (m Matrix) Set(row, column int, value float32) {
+func (m Matrix[T]) Set(row, column int, value T) {
 	m.data[m.calcRowColumnOffset(row, column)] = value
 }
 
-func (m Matrix) Get(row, column int) float32 {
+func (m Matrix[T]) Get(row, column int) T {
 	return m.data[m.calcRowColumnOffset(row, column)]
 }
 
-func (m Matrix) Clone() Matrix {
+func (m Matrix[T]) Clone() Matrix[T] {
 	if m.rows == 0 || m.viewColumns == 0 {
-		return Matrix{}
+		return Matrix[T]{}
 	}
-	data := make([]float32, m.rows*m.viewColumns)
+	data := make([]T, m.rows*m.viewColumns)
 	for i := 0; i < m.rows; i++ {
 		from := i * m.viewColumns
 		copy(data[from:from+m.viewColumns], m.getRow(i))
 	}
-	return Matrix{
+	return Matrix[T]{
 		rows:        m.rows,
 		dataColumns: m.viewColumns,
 		viewColumns: m.viewColumns,
@@ -81,18 +86,18 @@ func (m Matrix) Clone() Matrix {
 	}
 }
 
-func (m Matrix) AsVector() Vector {
-	return Vector{Matrix: m}
+func (m Matrix[T]) AsVector() Vector[T] {
+	return Vector[T]{Matrix: m}
 }
 
-func (m Matrix) Resize(rows, columns int) Matrix {
+func (m Matrix[T]) Resize(rows, columns int) Matrix[T] {
 	if m.rows == rows && m.viewColumns == columns {
 		return m
 	}
-	return NewMatrix(rows, columns)
+	return NewMatrix[T](rows, columns)
 }
 
-func (m Matrix) String() string {
+func (m Matrix[T]) String() string {
 	sb := strings.Builder{}
 	_, _ = fmt.Fprintf(&sb, "Matrix(%d,%d)[", m.rows, m.viewColumns)
 	for r := 0; r < m.rows; r++ {
@@ -102,11 +107,11 @@ func (m Matrix) String() string {
 	return sb.String()
 }
 
-func (m Matrix) calcRowColumnOffset(row, column int) int {
+func (m Matrix[T]) calcRowColumnOffset(row, column int) int {
 	return row*m.dataColumns + column
 }
 
-func (m Matrix) getRow(row int) []float32 {
+func (m Matrix[T]) getRow(row int) []T {
 	from := row * m.dataColumns
 	return m.data[from : from+m.viewColumns]
 }
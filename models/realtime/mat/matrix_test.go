@@ -32,7 +32,7 @@ func TestMatrix_Clone(t *testing.T) {
 
 	view := m.View(1, 1, 2, 2)
 	viewClone := view.Clone()
-	requireDeepEqual(t, Matrix{
+	requireDeepEqual(t, Matrix[float32]{
 		rows:        2,
 		dataColumns: 2,
 		viewColumns: 2,
@@ -51,18 +51,18 @@ func TestMatrix_Clone(t *testing.T) {
 func TestNewMatrixFromSlices(t *testing.T) {
 	testCases := []struct {
 		name     string
-		actual   Matrix
-		expected Matrix
+		actual   Matrix[float32]
+		expected Matrix[float32]
 	}{
 		{
 			"nil slice",
-			NewMatrixFromSlices(nil),
-			Matrix{},
+			NewMatrixFromSlices[float32](nil),
+			Matrix[float32]{},
 		},
 		{
 			"empty slice",
 			NewMatrixFromSlices([][]float32{}),
-			Matrix{},
+			Matrix[float32]{},
 		},
 		{
 			"slice with data",
@@ -70,7 +70,7 @@ func TestNewMatrixFromSlices(t *testing.T) {
 				{1, 2, 3},
 				{4, 5, 6},
 			}),
-			Matrix{
+			Matrix[float32]{
 				rows:        2,
 				dataColumns: 3,
 				viewColumns: 3,
@@ -92,7 +92,7 @@ func requireDeepEqual(t *testing.T, expected, actual any) {
 	}
 }
 
-func assertMatrixEqual(t *testing.T, expected, actual Matrix) {
+func assertMatrixEqual(t *testing.T, expected, actual Matrix[float32]) {
 	t.Helper()
 	if expected.Rows() != actual.Rows() || expected.Columns() != actual.Columns() {
 		t.Errorf("different shapes\nexpected:\n%v\nactual:\n%v", expected, actual)
@@ -14,17 +14,15 @@
 
 package mat
 
-import "math"
-
 //go:nosplit
-func Copy(destination, source Matrix) {
+func Copy[T Float](destination, source Matrix[T]) {
 	for i := 0; i < destination.rows; i++ {
 		copy(destination.getRow(i), source.getRow(i))
 	}
 }
 
 //go:nosplit
-func (m Matrix) SetZero() {
+func (m Matrix[T]) SetZero() {
 	for i := 0; i < m.rows; i++ {
 		mRow := m.getRow(i)
 		for j := range mRow {
@@ -36,58 +34,40 @@ func (m Matrix) SetZero() {
 // Product computes matrix-matrix multiplication C = A * B.
 //
 //go:nosplit
-func Product(a, b, c Matrix) {
-	bDataColumns := b.dataColumns
-	bData := b.data
-	cRows := c.rows
-	for i := 0; i < cRows; i++ {
-		aRow := a.getRow(i)
-		cRow := c.getRow(i)
-		for j := range cRow {
-			v := float32(0)
-			bOffset := j
-			for _, aValue := range aRow {
-				v += aValue * bData[bOffset]
-				bOffset += bDataColumns
-			}
-			cRow[j] = v
-		}
+func Product[T Float](a, b, c Matrix[T]) {
+	productRow := kernelsOf[T]().productRow
+	for i := 0; i < c.rows; i++ {
+		productRow(c.getRow(i), a.getRow(i), b.data, b.dataColumns, false)
 	}
 }
 
 // AddProduct adds to C the result of matrix-matrix multiplication C += A * B.
 //
 //go:nosplit
-func AddProduct(a, b, c Matrix) {
-	bDataColumns := b.dataColumns
-	bData := b.data
-	cRows := c.rows
-	for i := 0; i < cRows; i++ {
-		aRow := a.getRow(i)
-		cRow := c.getRow(i)
-		for j := range cRow {
-			v := cRow[j]
-			bOffset := j
-			for _, aValue := range aRow {
-				v += aValue * bData[bOffset]
-				bOffset += bDataColumns
-			}
-			cRow[j] = v
-		}
+func AddProduct[T Float](a, b, c Matrix[T]) {
+	productRow := kernelsOf[T]().productRow
+	for i := 0; i < c.rows; i++ {
+		productRow(c.getRow(i), a.getRow(i), b.data, b.dataColumns, true)
 	}
 }
 
 // AddInPlace performs in-place element-wise addition A += B
 //
 //go:nosplit
-func AddInPlace(a, b Matrix) {
+func AddInPlace[T Float](a, b Matrix[T]) {
+	addRow := kernelsOf[T]().addRow
 	for i := 0; i < a.rows; i++ {
-		aRow := a.getRow(i)
-		bRow := b.getRow(i)
-		_ = aRow[len(bRow)-1]
-		for j, bValue := range bRow {
-			aRow[j] += bValue
-		}
+		addRow(a.getRow(i), b.getRow(i))
+	}
+}
+
+// MulInPlace performs in-place element-wise multiplication A *= B
+//
+//go:nosplit
+func MulInPlace[T Float](a, b Matrix[T]) {
+	mulRow := kernelsOf[T]().mulRow
+	for i := 0; i < a.rows; i++ {
+		mulRow(a.getRow(i), b.getRow(i))
 	}
 }
 
@@ -95,32 +75,55 @@ func AddInPlace(a, b Matrix) {
 // For each column c of M: M[c] += V.
 //
 //go:nosplit
-func AddInPlaceColumnWise(m Matrix, v Vector) {
+func AddInPlaceColumnWise[T Float](m Matrix[T], v Vector[T]) {
+	addScalarRow := kernelsOf[T]().addScalarRow
 	for i := 0; i < m.rows; i++ {
-		vValue := v.Get(i)
-		mRow := m.getRow(i)
-		for j := range mRow {
-			mRow[j] += vValue
-		}
+		addScalarRow(m.getRow(i), v.Get(i))
 	}
 }
 
 //go:nosplit
-func (m Matrix) TanhInPlace() {
+func (m Matrix[T]) TanhInPlace() {
+	tanhRow := kernelsOf[T]().tanhRow
 	for i := 0; i < m.rows; i++ {
-		mRow := m.getRow(i)
-		for j, v := range mRow {
-			mRow[j] = float32(math.Tanh(float64(v)))
-		}
+		tanhRow(m.getRow(i))
 	}
 }
 
 //go:nosplit
-func (m Matrix) SigmoidInPlace() {
+func (m Matrix[T]) SigmoidInPlace() {
+	sigmoidRow := kernelsOf[T]().sigmoidRow
+	for i := 0; i < m.rows; i++ {
+		sigmoidRow(m.getRow(i))
+	}
+}
+
+// ReLUInPlace replaces negative elements with zero.
+//
+//go:nosplit
+func (m Matrix[T]) ReLUInPlace() {
+	reluRow := kernelsOf[T]().reluRow
+	for i := 0; i < m.rows; i++ {
+		reluRow(m.getRow(i))
+	}
+}
+
+// ClampInPlace limits the elements to the [lo, hi] range.
+//
+//go:nosplit
+func (m Matrix[T]) ClampInPlace(lo, hi T) {
+	clampRow := kernelsOf[T]().clampRow
+	for i := 0; i < m.rows; i++ {
+		clampRow(m.getRow(i), lo, hi)
+	}
+}
+
+// ApplyInPlace replaces each element with the result of f.
+func (m Matrix[T]) ApplyInPlace(f func(T) T) {
 	for i := 0; i < m.rows; i++ {
 		mRow := m.getRow(i)
 		for j, v := range mRow {
-			mRow[j] = float32(1 / (1 + math.Exp(float64(-v))))
+			mRow[j] = f(v)
 		}
 	}
 }
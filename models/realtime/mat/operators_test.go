@@ -21,15 +21,15 @@ import (
 func TestProduct(t *testing.T) {
 	testCases := []struct {
 		name     string
-		a        Matrix
-		b        Matrix
-		expected Matrix
+		a        Matrix[float32]
+		b        Matrix[float32]
+		expected Matrix[float32]
 	}{
 		{
 			"nil",
-			NewMatrixFromSlices(nil),
-			NewMatrixFromSlices(nil),
-			NewMatrixFromSlices(nil),
+			NewMatrixFromSlices[float32](nil),
+			NewMatrixFromSlices[float32](nil),
+			NewMatrixFromSlices[float32](nil),
 		},
 		{
 			"1x1",
@@ -78,7 +78,7 @@ func TestProduct(t *testing.T) {
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			actual := NewMatrix(tc.a.Rows(), tc.b.Columns())
+			actual := NewMatrix[float32](tc.a.Rows(), tc.b.Columns())
 			Product(tc.a, tc.b, actual)
 			assertMatrixEqual(t, tc.expected, actual)
 		})
@@ -88,17 +88,17 @@ func TestProduct(t *testing.T) {
 func TestAddProduct(t *testing.T) {
 	testCases := []struct {
 		name     string
-		a        Matrix
-		b        Matrix
-		c        Matrix
-		expected Matrix
+		a        Matrix[float32]
+		b        Matrix[float32]
+		c        Matrix[float32]
+		expected Matrix[float32]
 	}{
 		{
 			"nil",
-			NewMatrixFromSlices(nil),
-			NewMatrixFromSlices(nil),
-			NewMatrixFromSlices(nil),
-			NewMatrixFromSlices(nil),
+			NewMatrixFromSlices[float32](nil),
+			NewMatrixFromSlices[float32](nil),
+			NewMatrixFromSlices[float32](nil),
+			NewMatrixFromSlices[float32](nil),
 		},
 		{
 			"1x1",
@@ -165,9 +165,9 @@ func TestAddProduct(t *testing.T) {
 func TestAddInPlace(t *testing.T) {
 	testCases := []struct {
 		name     string
-		a        Matrix
-		b        Matrix
-		expected Matrix
+		a        Matrix[float32]
+		b        Matrix[float32]
+		expected Matrix[float32]
 	}{
 		{
 			"matrices",
@@ -209,12 +209,59 @@ func TestAddInPlace(t *testing.T) {
 	}
 }
 
+func TestMulInPlace(t *testing.T) {
+	testCases := []struct {
+		name     string
+		a        Matrix[float32]
+		b        Matrix[float32]
+		expected Matrix[float32]
+	}{
+		{
+			"matrices",
+			NewMatrixFromSlices([][]float32{
+				{1, 2, 3},
+				{4, 5, 6}}),
+			NewMatrixFromSlices([][]float32{
+				{.5, 2, -1},
+				{0, .25, 3}}),
+			NewMatrixFromSlices([][]float32{
+				{.5, 4, -3},
+				{0, 1.25, 18}}),
+		},
+		{
+			"views",
+			NewMatrixFromSlices([][]float32{
+				{9, 9, 9, 9, 9},
+				{9, 1, 2, 3, 9},
+				{9, 4, 5, 6, 9},
+				{9, 9, 9, 9, 9},
+			}).View(1, 1, 2, 3),
+			NewMatrixFromSlices([][]float32{
+				{8, 8, 8, 8, 8},
+				{8, .5, 2, -1, 8},
+				{8, 0, .25, 3, 8},
+				{8, 8, 8, 8, 8},
+			}).View(1, 1, 2, 3),
+			NewMatrixFromSlices([][]float32{
+				{.5, 4, -3},
+				{0, 1.25, 18}}),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := tc.a.Clone()
+			MulInPlace(actual, tc.b)
+			assertMatrixEqual(t, tc.expected, actual)
+		})
+	}
+}
+
 func TestAddInPlaceColumnWise(t *testing.T) {
 	testCases := []struct {
 		name     string
-		m        Matrix
-		v        Vector
-		expected Matrix
+		m        Matrix[float32]
+		v        Vector[float32]
+		expected Matrix[float32]
 	}{
 		{
 			"matrices",
@@ -14,32 +14,32 @@
 
 package mat
 
-type Vector struct {
-	Matrix
+type Vector[T Float] struct {
+	Matrix[T]
 }
 
-func NewVector(size int) Vector {
-	return Vector{Matrix: NewMatrix(size, 1)}
+func NewVector[T Float](size int) Vector[T] {
+	return Vector[T]{Matrix: NewMatrix[T](size, 1)}
 }
 
-func NewVectorFromSlice(data []float32) Vector {
+func NewVectorFromSlice[T Float](data []T) Vector[T] {
 	size := len(data)
 	if size == 0 {
-		return Vector{}
+		return Vector[T]{}
 	}
-	v := NewVector(size)
+	v := NewVector[T](size)
 	copy(v.data, data)
 	return v
 }
 
-func (v Vector) Size() int {
+func (v Vector[T]) Size() int {
 	return v.Rows() * v.Columns()
 }
 
-func (v Vector) Set(index int, value float32) {
+func (v Vector[T]) Set(index int, value T) {
 	v.Matrix.Set(index, 0, value)
 }
 
-func (v Vector) Get(index int) float32 {
+func (v Vector[T]) Get(index int) T {
 	return v.Matrix.Get(index, 0)
 }
@@ -21,23 +21,23 @@ import (
 func TestNewVectorFromSlice(t *testing.T) {
 	testCases := []struct {
 		name     string
-		actual   Vector
-		expected Vector
+		actual   Vector[float32]
+		expected Vector[float32]
 	}{
 		{
 			"nil slice",
-			NewVectorFromSlice(nil),
-			Vector{},
+			NewVectorFromSlice[float32](nil),
+			Vector[float32]{},
 		},
 		{
 			"empty slice",
 			NewVectorFromSlice([]float32{}),
-			Vector{},
+			Vector[float32]{},
 		},
 		{
 			"slice with data",
 			NewVectorFromSlice([]float32{1, 2, 3}),
-			Vector{Matrix{
+			Vector[float32]{Matrix[float32]{
 				rows:        3,
 				dataColumns: 1,
 				viewColumns: 1,
@@ -14,9 +14,12 @@
 
 package mat
 
-func (m Matrix) View(fromRow, fromColumn, numRows, numColumns int) Matrix {
+func (m Matrix[T]) View(fromRow, fromColumn, numRows, numColumns int) Matrix[T] {
+	if numRows == 0 {
+		return Matrix[T]{dataColumns: m.dataColumns, viewColumns: numColumns}
+	}
 	start := fromRow*m.dataColumns + fromColumn
-	return Matrix{
+	return Matrix[T]{
 		rows:        numRows,
 		dataColumns: m.dataColumns,
 		viewColumns: numColumns,
@@ -24,10 +27,33 @@ func (m Matrix) View(fromRow, fromColumn, numRows, numColumns int) Matrix {
 	}
 }
 
-func (m Matrix) ViewMiddleColumns(fromColumn, numColumns int) Matrix {
+func (m Matrix[T]) ViewMiddleColumns(fromColumn, numColumns int) Matrix[T] {
 	return m.View(0, fromColumn, m.rows, numColumns)
 }
 
-func (m Matrix) ViewTopRows(n int) Matrix {
+func (m Matrix[T]) ViewLeftColumns(n int) Matrix[T] {
+	return m.View(0, 0, m.rows, n)
+}
+
+// ResizeView returns a view of the first numColumns columns of *m, first
+// replacing *m with a new matrix with the same rows, if it has less
+// columns. Since *m never shrinks, views of varying sizes don't allocate,
+// once the largest one has been requested.
+func ResizeView[T Float](m *Matrix[T], numColumns int) Matrix[T] {
+	if m.viewColumns < numColumns {
+		*m = NewMatrix[T](m.rows, numColumns)
+	}
+	return m.ViewLeftColumns(numColumns)
+}
+
+func (m Matrix[T]) ViewMiddleRows(fromRow, numRows int) Matrix[T] {
+	return m.View(fromRow, 0, numRows, m.viewColumns)
+}
+
+func (m Matrix[T]) ViewTopRows(n int) Matrix[T] {
 	return m.View(0, 0, n, m.viewColumns)
 }
+
+func (m Matrix[T]) ViewBottomRows(n int) Matrix[T] {
+	return m.View(m.rows-n, 0, n, m.viewColumns)
+}
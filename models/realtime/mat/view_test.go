@@ -23,7 +23,7 @@ func TestMatrix_View(t *testing.T) {
 		{120, 121, 122, 123},
 	})
 
-	requireDeepEqual(t, Matrix{
+	requireDeepEqual(t, Matrix[float32]{
 		rows:        3,
 		dataColumns: 4,
 		viewColumns: 4,
@@ -38,7 +38,7 @@ func TestMatrix_View(t *testing.T) {
 	requireDeepEqual(t, m, vIdentity)
 
 	vTop := m.View(0, 0, 2, 4)
-	requireDeepEqual(t, Matrix{
+	requireDeepEqual(t, Matrix[float32]{
 		rows:        2,
 		dataColumns: 4,
 		viewColumns: 4,
@@ -53,7 +53,7 @@ func TestMatrix_View(t *testing.T) {
 	}), vTop)
 
 	vBottom := m.View(1, 0, 2, 4)
-	requireDeepEqual(t, Matrix{
+	requireDeepEqual(t, Matrix[float32]{
 		rows:        2,
 		dataColumns: 4,
 		viewColumns: 4,
@@ -68,7 +68,7 @@ func TestMatrix_View(t *testing.T) {
 	}), vBottom)
 
 	vLeft := m.View(0, 0, 3, 2)
-	requireDeepEqual(t, Matrix{
+	requireDeepEqual(t, Matrix[float32]{
 		rows:        3,
 		dataColumns: 4,
 		viewColumns: 2,
@@ -85,7 +85,7 @@ func TestMatrix_View(t *testing.T) {
 	}), vLeft)
 
 	vRight := m.View(0, 2, 3, 2)
-	requireDeepEqual(t, Matrix{
+	requireDeepEqual(t, Matrix[float32]{
 		rows:        3,
 		dataColumns: 4,
 		viewColumns: 2,
@@ -110,7 +110,7 @@ func TestMatrix_View(t *testing.T) {
 		{150, 151, 152, 153, 154, 155},
 	})
 
-	requireDeepEqual(t, Matrix{
+	requireDeepEqual(t, Matrix[float32]{
 		rows:        6,
 		dataColumns: 6,
 		viewColumns: 6,
@@ -125,7 +125,7 @@ func TestMatrix_View(t *testing.T) {
 	}, m)
 
 	v1 := m.View(1, 1, 4, 4)
-	requireDeepEqual(t, Matrix{
+	requireDeepEqual(t, Matrix[float32]{
 		rows:        4,
 		dataColumns: 6,
 		viewColumns: 4,
@@ -144,7 +144,7 @@ func TestMatrix_View(t *testing.T) {
 	}), v1)
 
 	v2 := v1.View(1, 1, 2, 2)
-	requireDeepEqual(t, Matrix{
+	requireDeepEqual(t, Matrix[float32]{
 		rows:        2,
 		dataColumns: 6,
 		viewColumns: 2,
@@ -207,7 +207,7 @@ func TestMatrix_ViewMiddleColumns(t *testing.T) {
 	})
 
 	vLeft := m.ViewMiddleColumns(0, 2)
-	requireDeepEqual(t, Matrix{
+	requireDeepEqual(t, Matrix[float32]{
 		rows:        3,
 		dataColumns: 4,
 		viewColumns: 2,
@@ -224,7 +224,7 @@ func TestMatrix_ViewMiddleColumns(t *testing.T) {
 	}), vLeft)
 
 	vRight := m.ViewMiddleColumns(2, 2)
-	requireDeepEqual(t, Matrix{
+	requireDeepEqual(t, Matrix[float32]{
 		rows:        3,
 		dataColumns: 4,
 		viewColumns: 2,
@@ -241,7 +241,7 @@ func TestMatrix_ViewMiddleColumns(t *testing.T) {
 	}), vRight)
 
 	vMiddle := m.ViewMiddleColumns(1, 2)
-	requireDeepEqual(t, Matrix{
+	requireDeepEqual(t, Matrix[float32]{
 		rows:        3,
 		dataColumns: 4,
 		viewColumns: 2,
@@ -257,3 +257,61 @@ func TestMatrix_ViewMiddleColumns(t *testing.T) {
 		{121, 122},
 	}), vMiddle)
 }
+
+func TestMatrix_ViewBottomRows(t *testing.T) {
+	m := NewMatrixFromSlices([][]float32{
+		{100, 101, 102},
+		{110, 111, 112},
+		{120, 121, 122},
+		{130, 131, 132},
+	})
+
+	v := m.ViewBottomRows(2)
+	requireDeepEqual(t, Matrix[float32]{
+		rows:        2,
+		dataColumns: 3,
+		viewColumns: 3,
+		data: []float32{
+			120, 121, 122,
+			130, 131, 132,
+		},
+	}, v)
+	assertMatrixEqual(t, NewMatrixFromSlices([][]float32{
+		{120, 121, 122},
+		{130, 131, 132},
+	}), v)
+
+	vMiddle := m.ViewMiddleColumns(1, 2).ViewBottomRows(3)
+	assertMatrixEqual(t, NewMatrixFromSlices([][]float32{
+		{111, 112},
+		{121, 122},
+		{131, 132},
+	}), vMiddle)
+}
+
+func TestResizeView(t *testing.T) {
+	var m Matrix[float32]
+	m.rows = 2
+
+	v := ResizeView(&m, 3)
+	if v.Rows() != 2 || v.Columns() != 3 || m.Columns() != 3 {
+		t.Fatalf("expected 2x3 view of 2x3 matrix, actual %dx%d of %dx%d", v.Rows(), v.Columns(), m.Rows(), m.Columns())
+	}
+	v.Set(1, 2, 42)
+
+	// Smaller views share the data.
+	data := m.data
+	v = ResizeView(&m, 1)
+	if v.Columns() != 1 || &m.data[0] != &data[0] {
+		t.Fatalf("expected view of the same matrix")
+	}
+	if actual := ResizeView(&m, 3).Get(1, 2); actual != 42 {
+		t.Errorf("expected 42, actual %g", actual)
+	}
+
+	// Larger views replace the matrix.
+	v = ResizeView(&m, 5)
+	if v.Columns() != 5 || m.Columns() != 5 || m.Rows() != 2 {
+		t.Errorf("expected 2x5 view of 2x5 matrix, actual %dx%d of %dx%d", v.Rows(), v.Columns(), m.Rows(), m.Columns())
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package parallel implements a pool of goroutines, each locked to an OS
+// thread, running the parts of a task together with the calling goroutine,
+// for splitting the work of a real-time audio callback across CPU cores.
+//
+// Workers wait for tasks spinning on atomic variables, and the calling
+// goroutine waits for their completion the same way, so that no locks or
+// channel operations are involved while processing. Workers idle for long
+// enough go to sleep, and are woken up by the next task.
+package parallel
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// idleSpins is the amount of iterations a worker spins for a new task,
+	// before yielding the processor at each iteration.
+	idleSpins = 1 << 10
+	// idleYields is the amount of iterations a worker yields the processor,
+	// before going to sleep.
+	idleYields = 1 << 14
+	// waitSpins is the amount of iterations the calling goroutine spins
+	// for the completion of the workers, before yielding the processor at
+	// each iteration.
+	waitSpins = 1 << 12
+)
+
+// A Task can be split in parts, to be run concurrently.
+type Task interface {
+	// Run runs the given part, in [0, numParts).
+	Run(part, numParts int)
+}
+
+// A Pool runs the parts of a task on its workers, and the calling
+// goroutine. A Pool must be used by one goroutine at a time.
+type Pool struct {
+	workers []worker
+	// state holds the generation of the current task, incremented by each
+	// task, and its amount of parts, in the lowest partsBits bits.
+	state   atomic.Uint64
+	task    Task
+	pending atomic.Int32 // parts still running on the workers
+	closed  atomic.Bool
+	wg      sync.WaitGroup
+}
+
+type worker struct {
+	sleeping atomic.Bool
+	wake     chan struct{}
+}
+
+const partsBits = 16
+
+// NewPool creates a new Pool running tasks on up to n parts: n-1 workers,
+// plus the calling goroutine. The Pool must be closed to stop the workers.
+func NewPool(n int) *Pool {
+	p := &Pool{workers: make([]worker, max(n-1, 0))}
+	p.wg.Add(len(p.workers))
+	for i := range p.workers {
+		p.workers[i].wake = make(chan struct{}, 1)
+		go p.work(i)
+	}
+	return p
+}
+
+// Size returns the maximum amount of parts of a task: the workers, plus the
+// calling goroutine.
+func (p *Pool) Size() int {
+	return len(p.workers) + 1
+}
+
+// Run runs the parts of the task, returning when all of them are done. The
+// part 0 is run on the calling goroutine. The amount of parts is limited to
+// Size.
+func (p *Pool) Run(task Task, numParts int) {
+	numParts = min(numParts, p.Size())
+	if numParts <= 1 {
+		task.Run(0, 1)
+		return
+	}
+	p.task = task
+	p.pending.Store(int32(numParts - 1))
+	generation := p.state.Load()>>partsBits + 1
+	p.state.Store(generation<<partsBits | uint64(numParts))
+	for i := range p.workers[:numParts-1] {
+		p.wakeUp(i)
+	}
+
+	task.Run(0, numParts)
+
+	for spins := 0; p.pending.Load() > 0; spins++ {
+		if spins >= waitSpins {
+			runtime.Gosched()
+		}
+	}
+	p.task = nil
+}
+
+// Close stops the workers.
+func (p *Pool) Close() {
+	p.closed.Store(true)
+	for i := range p.workers {
+		p.wakeUp(i)
+	}
+	p.wg.Wait()
+}
+
+func (p *Pool) wakeUp(i int) {
+	w := &p.workers[i]
+	if w.sleeping.Load() {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (p *Pool) work(i int) {
+	defer p.wg.Done()
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	part := i + 1
+	w := &p.workers[i]
+	// The initial state, rather than the current one, since a task could
+	// have been started already.
+	var state uint64
+	for {
+		for spins := 0; ; spins++ {
+			if p.closed.Load() {
+				return
+			}
+			if s := p.state.Load(); s != state {
+				state = s
+				break
+			}
+			switch {
+			case spins < idleSpins:
+			case spins < idleSpins+idleYields:
+				runtime.Gosched()
+			default:
+				// The state is checked again after announcing the sleep, so
+				// that a task started meanwhile is not missed.
+				w.sleeping.Store(true)
+				if p.state.Load() == state && !p.closed.Load() {
+					<-w.wake
+				}
+				w.sleeping.Store(false)
+				spins = 0
+			}
+		}
+
+		if numParts := int(state & (1<<partsBits - 1)); part < numParts {
+			p.task.Run(part, numParts)
+			p.pending.Add(-1)
+		}
+	}
+}
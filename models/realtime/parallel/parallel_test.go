@@ -0,0 +1,79 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parallel
+
+import (
+	"testing"
+	"time"
+)
+
+// countTask counts the runs of each part.
+type countTask struct {
+	runs []int
+}
+
+func (t *countTask) Run(part, numParts int) {
+	if numParts > len(t.runs) {
+		panic("too many parts")
+	}
+	t.runs[part]++
+}
+
+func TestPool(t *testing.T) {
+	p := NewPool(4)
+	defer p.Close()
+	if p.Size() != 4 {
+		t.Fatalf("expected size 4, actual %d", p.Size())
+	}
+
+	task := &countTask{runs: make([]int, 4)}
+	for i := 0; i < 1000; i++ {
+		p.Run(task, 4)
+		p.Run(task, 2)
+		p.Run(task, 10) // limited to the size
+		p.Run(task, 1)
+		if i%100 == 0 {
+			// Workers go to sleep, and must be woken up.
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	for part, expected := range []int{4000, 3000, 2000, 2000} {
+		if task.runs[part] != expected {
+			t.Errorf("part %d: expected %d runs, actual %d", part, expected, task.runs[part])
+		}
+	}
+}
+
+func TestPoolAllocations(t *testing.T) {
+	p := NewPool(3)
+	defer p.Close()
+	task := &countTask{runs: make([]int, 3)}
+	allocs := testing.AllocsPerRun(100, func() {
+		p.Run(task, 3)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, actual %g", allocs)
+	}
+}
+
+func TestNewPoolSerial(t *testing.T) {
+	p := NewPool(1)
+	defer p.Close()
+	task := &countTask{runs: make([]int, 1)}
+	p.Run(task, 4)
+	if task.runs[0] != 1 {
+		t.Errorf("expected 1 run, actual %d", task.runs[0])
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package realtime loads real-time models from NAM model data, dispatching
+// on their architecture.
+//
+// Architectures are made available by registering them, usually from the
+// init function of their package. Programs import the packages of the
+// architectures they need, possibly only for their side effects:
+//
+//	import _ "github.com/nlpodyssey/waveny/models/realtime/wavenet"
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// DefaultSampleRate is the sample rate of models whose data don't specify
+// it.
+const DefaultSampleRate = 48_000
+
+// A Model processes a mono stream of samples, block by block.
+type Model interface {
+	// Process processes the input samples, storing the results into output,
+	// which must have the same length.
+	Process(input, output []float32)
+	// Finalize must be called after Process, with the amount of processed
+	// frames, to advance the internal state.
+	Finalize(numFrames int)
+	// SetMaxBlockSize allocates in advance the memory needed to process
+	// blocks of up to maxBlockSize samples, so that Process and Finalize
+	// don't allocate, as required by real-time audio callbacks. Larger
+	// blocks are still processed, allocating more memory.
+	SetMaxBlockSize(maxBlockSize int)
+	// Reset clears the internal state, as if no samples were processed.
+	Reset()
+	// Latency returns the delay of the output, in samples.
+	Latency() int
+	// ReceptiveField returns the amount of most recent input samples each
+	// output sample depends on, including the current one.
+	ReceptiveField() int
+	// SampleRate returns the sample rate the model operates at.
+	SampleRate() int
+	// Close stops the goroutines started by the model, if any.
+	Close()
+}
+
+// Options configure the models created by New.
+type Options struct {
+	// Workers is the amount of goroutines processing each step of the
+	// model, for architectures supporting it, such as WaveNet (see
+	// wavenet.WithWorkers). Models are processed serially if it's 0 or 1.
+	Workers int
+	// Precision is the size in bits of the floating-point values the model
+	// computes with: 32 (also if zero) or 64, for architectures registered
+	// with RegisterPrecision.
+	Precision int
+}
+
+// An Option sets one of the Options.
+type Option func(*Options)
+
+// WithPrecision makes models compute with floating-point values of the
+// given size in bits: 32 or 64.
+func WithPrecision(bits int) Option {
+	return func(o *Options) {
+		o.Precision = bits
+	}
+}
+
+// WithWorkers makes models process in parallel on n goroutines, where
+// supported by their architecture.
+// Models with workers must be closed to stop them.
+func WithWorkers(n int) Option {
+	return func(o *Options) {
+		o.Workers = n
+	}
+}
+
+// ModelData is the content of a NAM model data file, whose configuration
+// depends on the architecture.
+type ModelData struct {
+	Version      string          `json:"version"`
+	Architecture string          `json:"architecture"`
+	Config       json.RawMessage `json:"config"`
+	Weights      []float64       `json:"weights"`
+	SampleRate   float64         `json:"sample_rate,omitempty"`
+}
+
+// GetSampleRate returns the sample rate of the model, or DefaultSampleRate
+// if unspecified.
+func (d *ModelData) GetSampleRate() int {
+	if d.SampleRate <= 0 {
+		return DefaultSampleRate
+	}
+	return int(d.SampleRate)
+}
+
+func LoadFromJSONModelDataFile(filename string, opts ...Option) (Model, error) {
+	modelData, err := ReadModelDataJSONFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JSON model data from file %q: %w", filename, err)
+	}
+	return New(modelData, opts...)
+}
+
+func ReadModelDataJSONFile(filename string) (_ *ModelData, err error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer func() {
+		if e := file.Close(); e != nil && err == nil {
+			err = fmt.Errorf("failed to close file: %w", e)
+		}
+	}()
+
+	dec := json.NewDecoder(file)
+	var modelData ModelData
+	if err = dec.Decode(&modelData); err != nil {
+		return nil, fmt.Errorf("JSON decoding failed: %w", err)
+	}
+	return &modelData, nil
+}
+
+// New creates a new Model from the model data, with the constructor
+// registered for its architecture.
+func New(modelData *ModelData, opts ...Option) (Model, error) {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	switch options.Precision {
+	case 0:
+		options.Precision = 32
+	case 32, 64:
+	default:
+		return nil, fmt.Errorf("invalid precision %d: expected 32 or 64", options.Precision)
+	}
+	constructor, err := lookup(modelData.Architecture, options.Precision)
+	if err != nil {
+		return nil, err
+	}
+	model, err := constructor(modelData, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize %s from JSON configuration: %w", modelData.Architecture, err)
+	}
+	return model, nil
+}
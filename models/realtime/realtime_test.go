@@ -0,0 +1,147 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realtime_test
+
+import (
+	"encoding/json"
+	"github.com/nlpodyssey/waveny/models/realtime"
+	"github.com/nlpodyssey/waveny/models/realtime/lstm"
+	"github.com/nlpodyssey/waveny/models/realtime/wavenet"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// gain is a custom architecture, scaling the input by its only weight.
+type gain struct {
+	value   float32
+	options realtime.Options
+}
+
+func (g *gain) Process(input, output []float32) {
+	for i, v := range input {
+		output[i] = g.value * v
+	}
+}
+
+func (g *gain) Finalize(int)        {}
+func (g *gain) SetMaxBlockSize(int) {}
+func (g *gain) Reset()              {}
+func (g *gain) Latency() int        { return 0 }
+func (g *gain) ReceptiveField() int { return 1 }
+func (g *gain) SampleRate() int     { return realtime.DefaultSampleRate }
+func (g *gain) Close()              {}
+
+func init() {
+	realtime.Register("Gain", func(modelData *realtime.ModelData, options realtime.Options) (realtime.Model, error) {
+		return &gain{value: float32(modelData.Weights[0]), options: options}, nil
+	})
+}
+
+func TestNew(t *testing.T) {
+	// 1 layer, hidden size 2: weights 8x3, bias 8, h0 2, c0 2, head 2+1.
+	modelData := &realtime.ModelData{
+		Version:      "0.5.2",
+		Architecture: "LSTM",
+		Config:       json.RawMessage(`{"num_layers": 1, "input_size": 1, "hidden_size": 2}`),
+		Weights:      make([]float64, 24+8+2+2+3),
+		SampleRate:   44100,
+	}
+	model, err := realtime.New(modelData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := model.(*lstm.Model); !ok {
+		t.Errorf("expected *lstm.Model, actual %T", model)
+	}
+	if sr := model.SampleRate(); sr != 44100 {
+		t.Errorf("expected sample rate 44100, actual %d", sr)
+	}
+
+	modelData.Architecture = "Transformer"
+	if _, err = realtime.New(modelData); err == nil {
+		t.Error("expected error for unsupported architecture")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if !slices.Contains(realtime.Architectures(), "Gain") {
+		t.Fatalf("expected Gain among architectures %v", realtime.Architectures())
+	}
+
+	model, err := realtime.New(&realtime.ModelData{Architecture: "Gain", Weights: []float64{2}}, realtime.WithWorkers(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer model.Close()
+	if workers := model.(*gain).options.Workers; workers != 3 {
+		t.Errorf("expected 3 workers, actual %d", workers)
+	}
+	output := make([]float32, 2)
+	model.Process([]float32{1, -0.5}, output)
+	if output[0] != 2 || output[1] != -1 {
+		t.Errorf("unexpected output %v", output)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic registering an architecture twice")
+		}
+	}()
+	realtime.Register("Gain", func(*realtime.ModelData, realtime.Options) (realtime.Model, error) { return nil, nil })
+}
+
+func TestPrecision(t *testing.T) {
+	modelData := &realtime.ModelData{Architecture: "Gain", Weights: []float64{2}}
+	if _, err := realtime.New(modelData, realtime.WithPrecision(64)); err == nil {
+		t.Error("expected error for unsupported precision")
+	}
+	if _, err := realtime.New(modelData, realtime.WithPrecision(16)); err == nil {
+		t.Error("expected error for invalid precision")
+	}
+
+	model, err := realtime.New(modelData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if precision := model.(*gain).options.Precision; precision != 32 {
+		t.Errorf("expected 32-bit precision by default, actual %d", precision)
+	}
+
+	modelData.Architecture = "WaveNet"
+	layer := `{"input_size": 1, "condition_size": 1, "head_size": 1, "channels": 1, "kernel_size": 1,
+		"dilations": [1], "activation": "Tanh", "gated": false, "head_bias": true}`
+	modelData.Config = json.RawMessage(`{"head_scale": 1, "layers": [` + layer + `, ` + layer + `]}`)
+	// Each layer array: rechannel 1, layer 5, head rechannel 1+1.
+	modelData.Weights = make([]float64, 2*8+1)
+	model, err = realtime.New(modelData, realtime.WithPrecision(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := model.(*wavenet.Model[float64]); !ok {
+		t.Errorf("expected *wavenet.Model[float64], actual %T", model)
+	}
+}
+
+func TestLoadNullModelData(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "null.nam")
+	if err := os.WriteFile(name, []byte("null"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := realtime.LoadFromJSONModelDataFile(name); err == nil {
+		t.Error("expected error for null model data")
+	}
+}
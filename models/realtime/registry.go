@@ -0,0 +1,94 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package realtime
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// A Constructor creates a new Model from model data of its architecture.
+// Options not supported by the architecture are ignored.
+type Constructor func(modelData *ModelData, options Options) (Model, error)
+
+type registryKey struct {
+	architecture string
+	precision    int
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[registryKey]Constructor)
+)
+
+// Register makes an architecture available to New, by the name found in
+// the "architecture" field of model data, with 32-bit precision.
+// It panics if the constructor is nil, or if the architecture is already
+// registered.
+func Register(architecture string, constructor Constructor) {
+	RegisterPrecision(architecture, 32, constructor)
+}
+
+// RegisterPrecision is like Register, for models computing with values of
+// the given size in bits, 32 or 64, selected by WithPrecision.
+func RegisterPrecision(architecture string, precision int, constructor Constructor) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if constructor == nil {
+		panic("realtime: Register constructor is nil")
+	}
+	if precision != 32 && precision != 64 {
+		panic(fmt.Sprintf("realtime: Register called with invalid precision %d", precision))
+	}
+	key := registryKey{architecture: architecture, precision: precision}
+	if _, dup := registry[key]; dup {
+		panic(fmt.Sprintf("realtime: Register called twice for architecture %q with %d-bit precision", architecture, precision))
+	}
+	registry[key] = constructor
+}
+
+// Architectures returns the sorted names of the registered architectures.
+func Architectures() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return architectures()
+}
+
+func architectures() []string {
+	names := make([]string, 0, len(registry))
+	for key := range registry {
+		if !slices.Contains(names, key.architecture) {
+			names = append(names, key.architecture)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookup(architecture string, precision int) (Constructor, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	if constructor, ok := registry[registryKey{architecture: architecture, precision: precision}]; ok {
+		return constructor, nil
+	}
+	for key := range registry {
+		if key.architecture == architecture {
+			return nil, fmt.Errorf("architecture %q doesn't support %d-bit precision", architecture, precision)
+		}
+	}
+	return nil, fmt.Errorf("unsupported architecture %q: registered architectures are %v", architecture, architectures())
+}
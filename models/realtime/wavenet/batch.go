@@ -0,0 +1,173 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wavenet
+
+import (
+	"fmt"
+	"github.com/nlpodyssey/waveny/floats"
+	"github.com/nlpodyssey/waveny/models/realtime"
+	"github.com/nlpodyssey/waveny/models/realtime/mat"
+	"github.com/nlpodyssey/waveny/models/realtime/wavenet/layerarray"
+)
+
+// batchColumns is the maximum amount of columns, that is, frames of all
+// streams together, processed at once by a BatchModel.
+const batchColumns = 4096
+
+// A BatchModel processes several independent streams together, through the
+// same WaveNet, so that the weights are multiplied by matrices made of the
+// frames of all streams, rather than by the frames of each one.
+//
+// The frames of the streams are interleaved, as columns of a Model whose
+// dilations are multiplied by the amount of streams: each dilated
+// convolution of a stream then only involves columns of the same stream.
+type BatchModel[T mat.Float] struct {
+	model      *Model[T]
+	numStreams int
+	input      []float32 // interleaved frames
+	output     []float32 // interleaved frames
+}
+
+// NewBatch creates a new BatchModel of numStreams streams.
+func NewBatch[T mat.Float](config Config, params *floats.Reader, numStreams int, opts ...Option) (*BatchModel[T], error) {
+	if numStreams < 1 {
+		return nil, fmt.Errorf("invalid amount of streams %d", numStreams)
+	}
+	batchConfig := config
+	batchConfig.Layers = make([]layerarray.Config, len(config.Layers))
+	for i, layerArrayConfig := range config.Layers {
+		dilations := make([]int, len(layerArrayConfig.Dilations))
+		for j, dilation := range layerArrayConfig.Dilations {
+			dilations[j] = dilation * numStreams
+		}
+		layerArrayConfig.Dilations = dilations
+		batchConfig.Layers[i] = layerArrayConfig
+	}
+	model, err := New[T](batchConfig, params, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &BatchModel[T]{model: model, numStreams: numStreams}, nil
+}
+
+// NewBatchFromModelData is like NewBatch, for a WaveNet NAM model data.
+func NewBatchFromModelData[T mat.Float](modelData *realtime.ModelData, numStreams int, opts ...Option) (*BatchModel[T], error) {
+	config, err := decodeConfig(modelData)
+	if err != nil {
+		return nil, err
+	}
+	b, err := NewBatch[T](config, floats.NewReader(modelData.Weights), numStreams, opts...)
+	if err != nil {
+		return nil, err
+	}
+	b.model.sampleRate = modelData.GetSampleRate()
+	return b, nil
+}
+
+func (b *BatchModel[T]) NumStreams() int {
+	return b.numStreams
+}
+
+func (b *BatchModel[T]) SampleRate() int {
+	return b.model.SampleRate()
+}
+
+// Reset restores the state of a silent input, for all streams.
+func (b *BatchModel[T]) Reset() {
+	b.model.Reset()
+}
+
+// Snapshot returns a copy of the internal state of all streams, for
+// restoring it later on this BatchModel, or on another one with the same
+// configuration and amount of streams.
+func (b *BatchModel[T]) Snapshot() State[T] {
+	return b.model.Snapshot()
+}
+
+// Restore restores a snapshot of the internal state of all streams, or
+// returns an error if it was taken with another configuration or amount of
+// streams.
+func (b *BatchModel[T]) Restore(state State[T]) error {
+	return b.model.Restore(state)
+}
+
+// SetMaxBlockSize allocates the memory needed to process blocks of up to
+// maxBlockSize frames of each stream, so that processing them doesn't
+// allocate.
+func (b *BatchModel[T]) SetMaxBlockSize(maxBlockSize int) {
+	n := min(maxBlockSize, b.maxFrames()) * b.numStreams
+	b.model.SetMaxBlockSize(n)
+	b.reserve(n)
+}
+
+// Close stops the workers, if any.
+func (b *BatchModel[T]) Close() {
+	b.model.Close()
+}
+
+// ProcessBatch processes a block of each stream: the inputs, and the
+// outputs storing the results, must all have the same length, with one
+// slice for each stream. Unlike Model.Process, the state is advanced
+// already, without any Finalize.
+func (b *BatchModel[T]) ProcessBatch(inputs, outputs [][]float32) {
+	if len(inputs) != b.numStreams || len(outputs) != b.numStreams {
+		panic(fmt.Sprintf("wavenet: ProcessBatch expected %d streams, actual %d inputs and %d outputs",
+			b.numStreams, len(inputs), len(outputs)))
+	}
+	numFrames := len(inputs[0])
+	for i := range inputs {
+		if len(inputs[i]) != numFrames || len(outputs[i]) != numFrames {
+			panic("wavenet: ProcessBatch streams have different lengths")
+		}
+	}
+
+	maxFrames := b.maxFrames()
+	for from := 0; from < numFrames; from += maxFrames {
+		to := min(from+maxFrames, numFrames)
+		b.process(inputs, outputs, from, to)
+	}
+}
+
+// maxFrames returns the maximum amount of frames of each stream processed
+// at once.
+func (b *BatchModel[T]) maxFrames() int {
+	return max(batchColumns/b.numStreams, 1)
+}
+
+func (b *BatchModel[T]) process(inputs, outputs [][]float32, from, to int) {
+	n := (to - from) * b.numStreams
+	b.reserve(n)
+	input, output := b.input[:n], b.output[:n]
+
+	for s, streamInput := range inputs {
+		for j, v := range streamInput[from:to] {
+			input[j*b.numStreams+s] = v
+		}
+	}
+	b.model.Process(input, output)
+	b.model.Finalize(n)
+	for s, streamOutput := range outputs {
+		for j := range streamOutput[from:to] {
+			streamOutput[from+j] = output[j*b.numStreams+s]
+		}
+	}
+}
+
+func (b *BatchModel[T]) reserve(n int) {
+	if cap(b.input) < n {
+		b.input = make([]float32, n)
+		b.output = make([]float32, n)
+	}
+}
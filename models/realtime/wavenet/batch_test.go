@@ -0,0 +1,113 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wavenet
+
+import (
+	"fmt"
+	"github.com/nlpodyssey/waveny/floats"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestProcessBatch(t *testing.T) {
+	config := standardConfig()
+	params := randParams(config)
+	for _, numStreams := range []int{1, 3} {
+		t.Run(fmt.Sprintf("streams=%d", numStreams), func(t *testing.T) {
+			batch, err := NewBatch[float32](config, floats.NewReader(params), numStreams)
+			if err != nil {
+				t.Fatal(err)
+			}
+			models := make([]*Model[float32], numStreams)
+			for i := range models {
+				if models[i], err = New[float32](config, floats.NewReader(params)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			rnd := rand.New(rand.NewSource(2))
+			// The largest block is processed in parts.
+			for _, n := range []int{1, 64, 7, 2000, 2} {
+				inputs, outputs := make([][]float32, numStreams), make([][]float32, numStreams)
+				for i := range inputs {
+					inputs[i] = make([]float32, n)
+					for j := range inputs[i] {
+						inputs[i][j] = float32(rnd.NormFloat64() * 0.3)
+					}
+					outputs[i] = make([]float32, n)
+				}
+				batch.ProcessBatch(inputs, outputs)
+
+				for i, model := range models {
+					expected := make([]float32, n)
+					model.Process(inputs[i], expected)
+					model.Finalize(n)
+					for j, v := range expected {
+						// Rounding differs between vectorized and scalar columns.
+						if math.Abs(float64(outputs[i][j]-v)) > 1e-5 {
+							t.Fatalf("block of %d frames, stream %d, frame %d: expected %g, actual %g",
+								n, i, j, v, outputs[i][j])
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkProcessBatch compares processing 8 streams, in blocks of 64
+// frames, with separate models and with a BatchModel.
+func BenchmarkProcessBatch(b *testing.B) {
+	const numStreams, blockSize = 8, 64
+	config := standardConfig()
+	params := randParams(config)
+	rnd := rand.New(rand.NewSource(2))
+	inputs, outputs := make([][]float32, numStreams), make([][]float32, numStreams)
+	for i := range inputs {
+		inputs[i] = make([]float32, blockSize)
+		for j := range inputs[i] {
+			inputs[i][j] = float32(rnd.NormFloat64() * 0.3)
+		}
+		outputs[i] = make([]float32, blockSize)
+	}
+
+	b.Run("separate", func(b *testing.B) {
+		models := make([]*Model[float32], numStreams)
+		for i := range models {
+			var err error
+			if models[i], err = New[float32](config, floats.NewReader(params)); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			for i, model := range models {
+				model.Process(inputs[i], outputs[i])
+				model.Finalize(blockSize)
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		batch, err := NewBatch[float32](config, floats.NewReader(params), numStreams)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			batch.ProcessBatch(inputs, outputs)
+		}
+	})
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package head
+
+import (
+	"github.com/nlpodyssey/waveny/floats"
+	"github.com/nlpodyssey/waveny/models/realtime/activations"
+	"github.com/nlpodyssey/waveny/models/realtime/conv1x1"
+	"github.com/nlpodyssey/waveny/models/realtime/mat"
+)
+
+// Config is the configuration of the WaveNet head: a stack of layers, each
+// one made of an activation followed by a 1x1 convolution.
+type Config struct {
+	Channels    int    `json:"channels"`
+	Activation  string `json:"activation"`
+	NumLayers   int    `json:"num_layers"`
+	OutChannels int    `json:"out_channels"`
+}
+
+type Head[T mat.Float] struct {
+	activation activations.Activation[T]
+	layers     []*conv1x1.Model[T]
+	buffers    []mat.Matrix[T] // outputs of all layers but the last one
+	// buffers are views of these
+	buffersData []mat.Matrix[T]
+}
+
+// New creates a new Head, whose input has the given channels (the head size
+// of the last layer array).
+func New[T mat.Float](config Config, inChannels int) (*Head[T], error) {
+	activation, err := activations.New[T](config.Activation)
+	if err != nil {
+		return nil, err
+	}
+	h := &Head[T]{
+		activation: activation,
+		layers:     make([]*conv1x1.Model[T], config.NumLayers),
+		buffers:    make([]mat.Matrix[T], config.NumLayers-1),
+
+		buffersData: make([]mat.Matrix[T], config.NumLayers-1),
+	}
+	for i := range h.layers {
+		outChannels := config.Channels
+		if i == config.NumLayers-1 {
+			outChannels = config.OutChannels
+		}
+		h.layers[i] = conv1x1.New[T](conv1x1.Config{
+			InChannels:  inChannels,
+			OutChannels: outChannels,
+			Bias:        true,
+		})
+		inChannels = outChannels
+	}
+	for i := range h.buffersData {
+		h.buffersData[i] = mat.NewMatrix[T](config.Channels, 0)
+	}
+	return h, nil
+}
+
+func (h *Head[T]) SetNumFrames(numFrames int) {
+	for i := range h.buffers {
+		h.buffers[i] = mat.ResizeView(&h.buffersData[i], numFrames)
+	}
+}
+
+func (h *Head[T]) SetParams(params *floats.Reader) {
+	for _, l := range h.layers {
+		l.SetParams(params)
+	}
+}
+
+// Process applies the head to the input, which is overwritten by the first
+// activation, storing the result into output.
+func (h *Head[T]) Process(input, output mat.Matrix[T]) {
+	x := input
+	for i, l := range h.layers {
+		h.activation.Apply(x)
+		y := output
+		if i < len(h.buffers) {
+			y = h.buffers[i]
+		}
+		l.Process(x, y)
+		x = y
+	}
+}
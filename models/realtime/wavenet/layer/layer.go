@@ -20,6 +20,7 @@ import (
 	"github.com/nlpodyssey/waveny/models/realtime/conv1d"
 	"github.com/nlpodyssey/waveny/models/realtime/conv1x1"
 	"github.com/nlpodyssey/waveny/models/realtime/mat"
+	"github.com/nlpodyssey/waveny/models/realtime/parallel"
 )
 
 type Config struct {
@@ -31,98 +32,96 @@ type Config struct {
 	Gated         bool
 }
 
-type Layer struct {
-	frontConv  *conv1d.Model
-	inputMixin *conv1x1.Model
-	postConv   *conv1x1.Model
-	activation activations.Activation
+type Layer[T mat.Float] struct {
+	frontConv  *conv1d.Model[T]
+	inputMixin *conv1x1.Model[T]
+	postConv   *conv1x1.Model[T]
+	activation activations.Activation[T]
 	gated      bool
-	state      mat.Matrix
-	tmpState   mat.Matrix
+	epilogue   mat.Epilogue[T] // activation, possibly gated
+	state      mat.Matrix[T]   // view of stateData
+	stateData  mat.Matrix[T]
+
+	// The arguments of ProcessParallel, for the tasks run by the pool.
+	args      processArgs[T]
+	frontTask frontTask[T]
+	postTask  postTask[T]
 }
 
-func New(config Config) *Layer {
-	if config.Gated {
-		panic("gated layer is not implemented")
-	}
+type processArgs[T mat.Float] struct {
+	input, condition, headInput, output mat.Matrix[T]
+	inputStartColumn, outputStartColumn int
+}
 
+func New[T mat.Float](config Config) (*Layer[T], error) {
+	activation, err := activations.New[T](config.Activation)
+	if err != nil {
+		return nil, err
+	}
 	outChannels := config.Channels
+	var epilogue mat.Epilogue[T] = activation
 	if config.Gated {
 		outChannels *= 2
+		epilogue = gatedActivation[T]{activation: activation, channels: config.Channels}
 	}
-	return &Layer{
-		frontConv: conv1d.New(conv1d.Config{
+	l := &Layer[T]{
+		frontConv: conv1d.New[T](conv1d.Config{
 			InChannels:  config.Channels,
 			OutChannels: outChannels,
 			KernelSize:  config.KernelSize,
 			Bias:        true,
 			Dilation:    config.Dilation,
 		}),
-		inputMixin: conv1x1.New(conv1x1.Config{
+		inputMixin: conv1x1.New[T](conv1x1.Config{
 			InChannels:  config.ConditionSize,
 			OutChannels: outChannels,
 			Bias:        false,
 		}),
-		postConv: conv1x1.New(conv1x1.Config{
+		postConv: conv1x1.New[T](conv1x1.Config{
 			InChannels:  config.Channels,
 			OutChannels: config.Channels,
 			Bias:        true,
 		}),
-		activation: activations.New(config.Activation),
+		activation: activation,
 		gated:      config.Gated,
+		epilogue:   epilogue,
+		stateData:  mat.NewMatrix[T](outChannels, 0),
 	}
+	l.frontTask.l = l
+	l.postTask.l = l
+	return l, nil
 }
 
-func (l *Layer) SetNumFrames(numFrames int) {
-	convOutChannels := l.frontConv.GetOutChannels()
-	if l.state.Rows() == convOutChannels && l.state.Columns() == numFrames {
-		return
-	}
-
-	l.state = l.state.Resize(convOutChannels, numFrames)
-	l.state.SetZero()
-
-	l.tmpState = l.tmpState.Resize(convOutChannels, numFrames)
+func (l *Layer[T]) SetNumFrames(numFrames int) {
+	l.state = mat.ResizeView(&l.stateData, numFrames)
 }
 
-func (l *Layer) GetChannels() int {
+func (l *Layer[T]) GetChannels() int {
 	return l.frontConv.GetInChannels()
 }
 
-func (l *Layer) GetDilation() int {
+func (l *Layer[T]) GetDilation() int {
 	return l.frontConv.GetDilation()
 }
 
-func (l *Layer) GetKernelSize() int {
+func (l *Layer[T]) GetKernelSize() int {
 	return l.frontConv.GetKernelSize()
 }
 
-func (l *Layer) SetParams(params *floats.Reader) {
+func (l *Layer[T]) SetParams(params *floats.Reader) {
 	l.frontConv.SetParams(params)
 	l.inputMixin.SetParams(params)
 	l.postConv.SetParams(params)
+	l.frontConv.SetInputMixin(l.inputMixin.GetWeight())
 }
 
-func (l *Layer) Process(input, condition, headInput, output mat.Matrix, inputStartColumn, outputStartColumn int) {
+func (l *Layer[T]) Process(input, condition, headInput, output mat.Matrix[T], inputStartColumn, outputStartColumn int) {
 	numColumns := condition.Columns()
 	channels := l.GetChannels()
 
-	l.frontConv.Process(input, l.state, inputStartColumn, numColumns, 0)
-
-	l.inputMixin.Process(condition, l.tmpState)
-	mat.AddInPlace(l.state, l.tmpState)
-
-	l.activation.Apply(l.state)
-
-	// TODO: implement gated
-	//if l.gated {
-	//	activations::Activation::get_activation("Sigmoid")->apply(this->_z.block(channels, 0, channels, this->_z.cols()));
-	//
-	//	this->_z.topRows(channels).array() *= this->_z.bottomRows(channels).array();
-	//	// this->_z.topRows(channels) = this->_z.topRows(channels).cwiseProduct(
-	//	//   this->_z.bottomRows(channels)
-	//	// );
-	//}
+	// The convolution, the input mixin and the activation are computed in
+	// a single pass.
+	l.frontConv.ProcessFused(input, l.state, inputStartColumn, numColumns, 0, condition, l.epilogue)
 
 	topState := l.state.ViewTopRows(channels)
 	mat.AddInPlace(headInput, topState)
@@ -131,3 +130,86 @@ func (l *Layer) Process(input, condition, headInput, output mat.Matrix, inputSta
 	l.postConv.Process(topState, outputView)
 	mat.AddInPlace(outputView, input.ViewMiddleColumns(inputStartColumn, numColumns))
 }
+
+// ProcessParallel is like Process, splitting the channels in numParts
+// parts, run concurrently by the pool. The amount of channels must be a
+// multiple of 4.
+func (l *Layer[T]) ProcessParallel(pool *parallel.Pool, numParts int, input, condition, headInput, output mat.Matrix[T], inputStartColumn, outputStartColumn int) {
+	l.args = processArgs[T]{
+		input:             input,
+		condition:         condition,
+		headInput:         headInput,
+		output:            output,
+		inputStartColumn:  inputStartColumn,
+		outputStartColumn: outputStartColumn,
+	}
+	l.frontConv.PrepareFused(input, inputStartColumn, condition.Columns(), condition)
+	// The post-convolution of each channel needs the whole activated state,
+	// so it runs after all parts of the front convolution are done.
+	pool.Run(&l.frontTask, numParts)
+	pool.Run(&l.postTask, numParts)
+}
+
+// frontTask computes the front convolution, the input mixin and the
+// activation of a part of the channels.
+type frontTask[T mat.Float] struct {
+	l *Layer[T]
+}
+
+func (t *frontTask[T]) Run(part, numParts int) {
+	l := t.l
+	channels := l.GetChannels()
+	numColumns := l.args.condition.Columns()
+	from, to := mat.PartitionRows(channels, part, numParts)
+	if !l.gated {
+		l.frontConv.ComputeFused(l.state, 0, numColumns, from, to, l.epilogue)
+		return
+	}
+	l.frontConv.ComputeFused(l.state, 0, numColumns, from, to, nil)
+	l.frontConv.ComputeFused(l.state, 0, numColumns, channels+from, channels+to, nil)
+	applyGate(l.activation, l.state.ViewMiddleRows(from, to-from), l.state.ViewMiddleRows(channels+from, to-from))
+}
+
+// postTask adds a part of the channels of the activated state to the head
+// input, and computes them through the post-convolution, with the residual
+// connection.
+type postTask[T mat.Float] struct {
+	l *Layer[T]
+}
+
+func (t *postTask[T]) Run(part, numParts int) {
+	l := t.l
+	a := &l.args
+	channels := l.GetChannels()
+	numColumns := a.condition.Columns()
+	from, to := mat.PartitionRows(channels, part, numParts)
+	if from == to {
+		return
+	}
+	topState := l.state.ViewTopRows(channels)
+	mat.AddInPlace(a.headInput.ViewMiddleRows(from, to-from), topState.ViewMiddleRows(from, to-from))
+
+	outputView := a.output.ViewMiddleColumns(a.outputStartColumn, numColumns)
+	l.postConv.ProcessRows(topState, outputView, from, to)
+	mat.AddInPlace(
+		outputView.ViewMiddleRows(from, to-from),
+		a.input.View(from, a.inputStartColumn, to-from, numColumns),
+	)
+}
+
+// gatedActivation activates the top half of the state, then gates it by
+// the sigmoid of the bottom half.
+type gatedActivation[T mat.Float] struct {
+	activation activations.Activation[T]
+	channels   int
+}
+
+func (g gatedActivation[T]) Apply(state mat.Matrix[T]) {
+	applyGate(g.activation, state.ViewTopRows(g.channels), state.ViewBottomRows(g.channels))
+}
+
+func applyGate[T mat.Float](activation activations.Activation[T], top, gate mat.Matrix[T]) {
+	activation.Apply(top)
+	gate.SigmoidInPlace()
+	mat.MulInPlace(top, gate)
+}
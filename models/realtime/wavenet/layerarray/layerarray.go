@@ -15,9 +15,11 @@
 package layerarray
 
 import (
+	"fmt"
 	"github.com/nlpodyssey/waveny/floats"
 	"github.com/nlpodyssey/waveny/models/realtime/conv1x1"
 	"github.com/nlpodyssey/waveny/models/realtime/mat"
+	"github.com/nlpodyssey/waveny/models/realtime/parallel"
 	"github.com/nlpodyssey/waveny/models/realtime/wavenet/layer"
 )
 
@@ -33,26 +35,40 @@ type Config struct {
 	HeadBias      bool   `json:"head_bias"`
 }
 
-type LayerArray struct {
-	bufferStart   int
-	rechannel     *conv1x1.Model
-	layerBuffers  []mat.Matrix
-	layers        []*layer.Layer
-	headRechannel *conv1x1.Model
+// The layer buffers are ring buffers of capacity frames, each one stored
+// twice, in consecutive columns, so that the past frames needed by each
+// layer, followed by the frames of the current block, are always
+// contiguous: new frames are written to both copies. The position is the
+// index of the next frame in the first copy.
+type LayerArray[T mat.Float] struct {
+	position      int
+	capacity      int
+	rechannel     *conv1x1.Model[T]
+	layerBuffers  []mat.Matrix[T]
+	layers        []*layer.Layer[T]
+	headRechannel *conv1x1.Model[T]
+	pool          *parallel.Pool // nil if layers are processed serially
+	numParts      int
 }
 
-const layerArrayBufferSize = 65536
+// minParallelChannels is the minimum amount of channels for processing
+// layers in parallel: smaller layers don't make up for the synchronization.
+const minParallelChannels = 16
 
-func NewLayerArray(config Config) *LayerArray {
-	la := &LayerArray{
-		rechannel: conv1x1.New(conv1x1.Config{
+// minPartChannels is the minimum amount of channels processed by each part
+// of a parallel layer.
+const minPartChannels = 4
+
+func NewLayerArray[T mat.Float](config Config) (*LayerArray[T], error) {
+	la := &LayerArray[T]{
+		rechannel: conv1x1.New[T](conv1x1.Config{
 			InChannels:  config.InputSize,
 			OutChannels: config.Channels,
 			Bias:        false,
 		}),
-		layerBuffers: make([]mat.Matrix, len(config.Dilations)),
-		layers:       make([]*layer.Layer, len(config.Dilations)),
-		headRechannel: conv1x1.New(conv1x1.Config{
+		layerBuffers: make([]mat.Matrix[T], len(config.Dilations)),
+		layers:       make([]*layer.Layer[T], len(config.Dilations)),
+		headRechannel: conv1x1.New[T](conv1x1.Config{
 			InChannels:  config.Channels,
 			OutChannels: config.HeadSize,
 			Bias:        config.HeadBias,
@@ -60,7 +76,8 @@ func NewLayerArray(config Config) *LayerArray {
 	}
 
 	for i, dilation := range config.Dilations {
-		la.layers[i] = layer.New(layer.Config{
+		var err error
+		la.layers[i], err = layer.New[T](layer.Config{
 			ConditionSize: config.ConditionSize,
 			Channels:      config.Channels,
 			KernelSize:    config.KernelSize,
@@ -68,24 +85,35 @@ func NewLayerArray(config Config) *LayerArray {
 			Activation:    config.Activation,
 			Gated:         config.Gated,
 		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	receptiveField := la.GetReceptiveField()
-	layerColumns := layerArrayBufferSize + receptiveField
+	la.capacity = la.GetReceptiveField() + 1
 	for i := range config.Dilations {
-		la.layerBuffers[i] = mat.NewMatrix(config.Channels, layerColumns)
+		la.layerBuffers[i] = mat.NewMatrix[T](config.Channels, 2*la.capacity)
 	}
+	la.position = la.GetReceptiveField()
+	return la, nil
+}
 
-	la.bufferStart = receptiveField
-	return la
+func (la *LayerArray[T]) AdvanceBuffers(numFrames int) {
+	la.position = (la.position + numFrames) % la.capacity
 }
 
-func (la *LayerArray) AdvanceBuffers(numFrames int) {
-	la.bufferStart += numFrames
+// bufferStart returns the column of the layer buffers where the frames of
+// the current block start: the one of the position, in the first copy,
+// unless the past frames would precede it.
+func (la *LayerArray[T]) bufferStart() int {
+	if la.position < la.GetReceptiveField() {
+		return la.position + la.capacity
+	}
+	return la.position
 }
 
 // GetReceptiveField returns the zero-indexed receptive field.
-func (la *LayerArray) GetReceptiveField() int {
+func (la *LayerArray[T]) GetReceptiveField() int {
 	receptiveField := 0
 	for _, l := range la.layers {
 		receptiveField += (l.GetKernelSize() - 1) * l.GetDilation()
@@ -93,41 +121,125 @@ func (la *LayerArray) GetReceptiveField() int {
 	return receptiveField
 }
 
-func (la *LayerArray) PrepareForFrames(numFrames int) {
-	if la.bufferStart+numFrames > la.getBufferSize() {
-		la.rewindBuffers()
+// reserve grows the layer buffers, if needed, for blocks of numFrames
+// frames, keeping the past frames.
+func (la *LayerArray[T]) reserve(numFrames int) {
+	capacity := la.GetReceptiveField() + numFrames
+	if capacity <= la.capacity {
+		return
 	}
-}
-
-func (la *LayerArray) getBufferSize() int {
-	if len(la.layerBuffers) == 0 {
-		return 0
+	state := la.Snapshot()
+	for i, layerBuffer := range la.layerBuffers {
+		la.layerBuffers[i] = mat.NewMatrix[T](layerBuffer.Rows(), 2*capacity)
 	}
-	return la.layerBuffers[0].Columns()
+	la.capacity = capacity
+	la.restore(state)
 }
 
-func (la *LayerArray) getChannels() int {
+func (la *LayerArray[T]) getChannels() int {
 	if len(la.layers) == 0 {
 		return 0
 	}
 	return la.layers[0].GetChannels()
 }
 
-func (la *LayerArray) rewindBuffers() {
-	start := la.GetReceptiveField()
+// layerHistory returns the amount of past columns of the input buffer of
+// layer i needed for processing the next frames.
+func (la *LayerArray[T]) layerHistory(i int) int {
+	l := la.layers[i]
+	return (l.GetKernelSize() - 1) * l.GetDilation()
+}
 
-	for i, layerBuffer := range la.layerBuffers {
-		l := la.layers[i]
-		d := (l.GetKernelSize() - 1) * l.GetDilation()
+// mirror copies numFrames columns of a layer buffer, from the given one,
+// to the other copy of the ring buffer.
+func (la *LayerArray[T]) mirror(layerBuffer mat.Matrix[T], fromColumn, numFrames int) {
+	if n := min(numFrames, la.capacity-fromColumn); n > 0 {
 		mat.Copy(
-			layerBuffer.ViewMiddleColumns(start-d, d),
-			layerBuffer.ViewMiddleColumns(la.bufferStart-d, d),
+			layerBuffer.ViewMiddleColumns(fromColumn+la.capacity, n),
+			layerBuffer.ViewMiddleColumns(fromColumn, n),
 		)
+		fromColumn, numFrames = fromColumn+n, numFrames-n
 	}
-	la.bufferStart = start
+	if numFrames > 0 {
+		mat.Copy(
+			layerBuffer.ViewMiddleColumns(fromColumn-la.capacity, numFrames),
+			layerBuffer.ViewMiddleColumns(fromColumn, numFrames),
+		)
+	}
+}
+
+// Reset clears the buffers.
+func (la *LayerArray[T]) Reset() {
+	for _, layerBuffer := range la.layerBuffers {
+		layerBuffer.SetZero()
+	}
+	la.position = la.GetReceptiveField()
+}
+
+// State is a snapshot of the history of the layer buffers.
+type State[T mat.Float] struct {
+	history []mat.Matrix[T]
 }
 
-func (la *LayerArray) SetParams(params *floats.Reader) {
+// Snapshot returns a copy of the history of the layer buffers, as far as
+// needed by each layer.
+func (la *LayerArray[T]) Snapshot() State[T] {
+	history := make([]mat.Matrix[T], len(la.layerBuffers))
+	for i, layerBuffer := range la.layerBuffers {
+		d := la.layerHistory(i)
+		history[i] = layerBuffer.ViewMiddleColumns(la.bufferStart()-d, d).Clone()
+	}
+	return State[T]{history: history}
+}
+
+// CheckState returns an error if the state wasn't taken from a layer array
+// identical to this one.
+func (la *LayerArray[T]) CheckState(state State[T]) error {
+	if len(state.history) != len(la.layerBuffers) {
+		return fmt.Errorf("expected %d layer buffers, actual %d", len(la.layerBuffers), len(state.history))
+	}
+	for i, layerBuffer := range la.layerBuffers {
+		d := la.layerHistory(i)
+		if d == 0 {
+			continue
+		}
+		history := state.history[i]
+		if history.Rows() != layerBuffer.Rows() || history.Columns() != d {
+			return fmt.Errorf(
+				"layer %d: expected %dx%d history, actual %dx%d",
+				i, layerBuffer.Rows(), d, history.Rows(), history.Columns(),
+			)
+		}
+	}
+	return nil
+}
+
+// Restore restores the history of the layer buffers from a snapshot of the
+// same layer array, or of an identical one. It doesn't allocate, unless the
+// state doesn't match, leaving the buffers unchanged.
+func (la *LayerArray[T]) Restore(state State[T]) error {
+	if err := la.CheckState(state); err != nil {
+		return err
+	}
+	la.restore(state)
+	return nil
+}
+
+// restore restores a state already checked by CheckState.
+func (la *LayerArray[T]) restore(state State[T]) {
+	start := la.GetReceptiveField()
+	for i, layerBuffer := range la.layerBuffers {
+		d := la.layerHistory(i)
+		if d == 0 {
+			continue
+		}
+		mat.Copy(layerBuffer.ViewMiddleColumns(start-d, d), state.history[i])
+		la.mirror(layerBuffer, start-d, d)
+	}
+	la.position = start
+}
+
+func (la *LayerArray[T]) SetParams(params *floats.Reader) {
 	la.rechannel.SetParams(params)
 	for _, l := range la.layers {
 		l.SetParams(params)
@@ -135,40 +247,55 @@ func (la *LayerArray) SetParams(params *floats.Reader) {
 	la.headRechannel.SetParams(params)
 }
 
-func (la *LayerArray) SetNumFrames(numFrames int) {
-	if layerArrayBufferSize-numFrames <= la.GetReceptiveField() {
-		panic("buffer is too short")
+// SetPool sets the pool for processing each layer in parallel, split by
+// channels, if large enough. A nil pool restores the serial processing.
+func (la *LayerArray[T]) SetPool(pool *parallel.Pool) {
+	la.pool, la.numParts = nil, 0
+	channels := la.getChannels()
+	if pool == nil || channels < minParallelChannels || channels%minPartChannels != 0 {
+		return
+	}
+	if numParts := min(pool.Size(), channels/minPartChannels); numParts > 1 {
+		la.pool, la.numParts = pool, numParts
 	}
+}
+
+// SetNumFrames prepares for processing blocks of numFrames frames, growing
+// the layer buffers if needed.
+func (la *LayerArray[T]) SetNumFrames(numFrames int) {
+	la.reserve(numFrames)
 	for _, l := range la.layers {
 		l.SetNumFrames(numFrames)
 	}
 }
 
-func (la *LayerArray) Process(layerInputs, condition, headInputs, layerOutputs, headOutputs mat.Matrix) {
+func (la *LayerArray[T]) Process(layerInputs, condition, headInputs, layerOutputs, headOutputs mat.Matrix[T]) {
+	bufferStart, numFrames := la.bufferStart(), layerInputs.Columns()
 	la.rechannel.Process(
 		layerInputs,
-		la.layerBuffers[0].ViewMiddleColumns(la.bufferStart, layerInputs.Columns()),
+		la.layerBuffers[0].ViewMiddleColumns(bufferStart, numFrames),
 	)
 
 	lastIndex := len(la.layers) - 1
-	for i, l := range la.layers[:lastIndex] {
-		l.Process(
-			la.layerBuffers[i],
-			condition,
-			headInputs,
-			la.layerBuffers[i+1],
-			la.bufferStart,
-			la.bufferStart,
-		)
+	for i := range la.layers[:lastIndex] {
+		la.processLayer(i, condition, headInputs, la.layerBuffers[i+1], bufferStart, bufferStart)
+	}
+	la.processLayer(lastIndex, condition, headInputs, layerOutputs, bufferStart, 0)
+
+	// The copies are updated after processing all layers: the ones of the
+	// current block are never among the past frames read meanwhile.
+	for _, layerBuffer := range la.layerBuffers {
+		la.mirror(layerBuffer, bufferStart, numFrames)
 	}
-	la.layers[lastIndex].Process(
-		la.layerBuffers[lastIndex],
-		condition,
-		headInputs,
-		layerOutputs,
-		la.bufferStart,
-		0,
-	)
 
 	la.headRechannel.Process(headInputs, headOutputs)
 }
+
+func (la *LayerArray[T]) processLayer(i int, condition, headInputs, output mat.Matrix[T], inputStartColumn, outputStartColumn int) {
+	l, input := la.layers[i], la.layerBuffers[i]
+	if la.pool == nil {
+		l.Process(input, condition, headInputs, output, inputStartColumn, outputStartColumn)
+		return
+	}
+	l.ProcessParallel(la.pool, la.numParts, input, condition, headInputs, output, inputStartColumn, outputStartColumn)
+}
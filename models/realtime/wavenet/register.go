@@ -0,0 +1,60 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wavenet
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nlpodyssey/waveny/floats"
+	"github.com/nlpodyssey/waveny/models/realtime"
+	"github.com/nlpodyssey/waveny/models/realtime/mat"
+)
+
+func init() {
+	realtime.Register("WaveNet", newFromModelData[float32])
+	realtime.RegisterPrecision("WaveNet", 64, newFromModelData[float64])
+}
+
+func newFromModelData[T mat.Float](modelData *realtime.ModelData, options realtime.Options) (realtime.Model, error) {
+	model, err := NewFromModelData[T](modelData, WithWorkers(options.Workers))
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// NewFromModelData creates a new Model from WaveNet NAM model data, computing
+// with values of type T. Both float32 and float64 models are registered,
+// selected by realtime.WithPrecision.
+func NewFromModelData[T mat.Float](modelData *realtime.ModelData, opts ...Option) (*Model[T], error) {
+	config, err := decodeConfig(modelData)
+	if err != nil {
+		return nil, err
+	}
+	model, err := New[T](config, floats.NewReader(modelData.Weights), opts...)
+	if err != nil {
+		return nil, err
+	}
+	model.sampleRate = modelData.GetSampleRate()
+	return model, nil
+}
+
+func decodeConfig(modelData *realtime.ModelData) (Config, error) {
+	var config Config
+	if err := json.Unmarshal(modelData.Config, &config); err != nil {
+		return Config{}, fmt.Errorf("failed to decode configuration: %w", err)
+	}
+	return config, nil
+}
@@ -17,67 +17,176 @@ package wavenet
 import (
 	"fmt"
 	"github.com/nlpodyssey/waveny/floats"
+	"github.com/nlpodyssey/waveny/models/realtime"
 	"github.com/nlpodyssey/waveny/models/realtime/mat"
+	"github.com/nlpodyssey/waveny/models/realtime/parallel"
+	"github.com/nlpodyssey/waveny/models/realtime/wavenet/head"
 	"github.com/nlpodyssey/waveny/models/realtime/wavenet/layerarray"
 )
 
 type Config struct {
-	HeadScale float32             `json:"head_scale"`
-	Head      *any                `json:"head"`
+	HeadScale float64             `json:"head_scale"`
+	Head      *head.Config        `json:"head"`
 	Layers    []layerarray.Config `json:"layers"`
 }
 
-type Model struct {
+type Model[T mat.Float] struct {
 	numFrames         int
-	layerArrays       []*layerarray.LayerArray
-	layerArrayOutputs []mat.Matrix
-	condition         mat.Matrix
-	headArrays        []mat.Matrix
-	headScale         float32
-	headOutput        mat.Matrix
+	layerArrays       []*layerarray.LayerArray[T]
+	layerArrayOutputs []mat.Matrix[T]
+	condition         mat.Matrix[T]
+	headArrays        []mat.Matrix[T]
+	headScale         T
+	head              *head.Head[T] // nil if no head is configured
+	headInput         mat.Matrix[T]
+	headOutput        mat.Matrix[T]
+	sampleRate        int
+	pool              *parallel.Pool // nil if layers are processed serially
+
+	// The matrices above are views of the first numFrames columns of the
+	// following ones, as large as the largest block processed, or
+	// reserved, so far.
+	layerArrayOutputsData []mat.Matrix[T]
+	conditionData         mat.Matrix[T]
+	headArraysData        []mat.Matrix[T]
+	headInputData         mat.Matrix[T]
+	headOutputData        mat.Matrix[T]
 }
 
-func New(config Config, params *floats.Reader) (*Model, error) {
-	if config.Head != nil {
-		return nil, fmt.Errorf("head not implemented")
+// An Option configures a Model created with New.
+type Option func(*options)
+
+type options struct {
+	numWorkers   int
+	maxBlockSize int
+}
+
+// WithWorkers makes the model process each layer in parallel, on n
+// goroutines, including the calling one, each locked to an OS thread.
+// Layers too small to benefit from it are still processed serially.
+// Workers spin waiting for each other, so n shouldn't exceed the amount of
+// cores available to the process.
+// Models with workers must be closed to stop them.
+func WithWorkers(n int) Option {
+	return func(o *options) {
+		o.numWorkers = n
 	}
+}
+
+// WithMaxBlockSize allocates the model for blocks of up to n frames, as
+// SetMaxBlockSize. The layer buffers hold the frames of the receptive field,
+// plus the ones of a block: without this option, they start as small as
+// possible, and grow with the blocks processed.
+func WithMaxBlockSize(n int) Option {
+	return func(o *options) {
+		o.maxBlockSize = n
+	}
+}
+
+func New[T mat.Float](config Config, params *floats.Reader, opts ...Option) (*Model[T], error) {
 	if len(config.Layers) < 2 {
 		return nil, fmt.Errorf("expected at least two layers, actual %d", len(config.Layers))
 	}
 
-	wn := &Model{
+	wn := &Model[T]{
 		numFrames:         0,
-		layerArrays:       make([]*layerarray.LayerArray, len(config.Layers)),
-		layerArrayOutputs: make([]mat.Matrix, len(config.Layers)),
-		headArrays:        make([]mat.Matrix, 1+len(config.Layers)),
-		headScale:         config.HeadScale,
-		headOutput:        mat.NewMatrix(1, 0),
+		layerArrays:       make([]*layerarray.LayerArray[T], len(config.Layers)),
+		layerArrayOutputs: make([]mat.Matrix[T], len(config.Layers)),
+		headArrays:        make([]mat.Matrix[T], 1+len(config.Layers)),
+		headScale:         T(config.HeadScale),
+		sampleRate:        realtime.DefaultSampleRate,
+
+		layerArrayOutputsData: make([]mat.Matrix[T], len(config.Layers)),
+		conditionData:         mat.NewMatrix[T](1, 0),
+		headArraysData:        make([]mat.Matrix[T], 1+len(config.Layers)),
+		headOutputData:        mat.NewMatrix[T](1, 0),
 	}
 
-	wn.headArrays[0] = mat.NewMatrix(config.Layers[0].Channels, 0)
+	wn.headArraysData[0] = mat.NewMatrix[T](config.Layers[0].Channels, 0)
 
 	for i, layerArrayConfig := range config.Layers {
-		wn.layerArrays[i] = layerarray.NewLayerArray(layerArrayConfig)
-		wn.layerArrayOutputs[i] = mat.NewMatrix(layerArrayConfig.Channels, 0)
+		layerArray, err := layerarray.NewLayerArray[T](layerArrayConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create layer array %d: %w", i, err)
+		}
+		wn.layerArrays[i] = layerArray
+		wn.layerArrayOutputsData[i] = mat.NewMatrix[T](layerArrayConfig.Channels, 0)
 
 		if i > 0 && layerArrayConfig.Channels != config.Layers[i-1].HeadSize {
 			return nil, fmt.Errorf(
 				"channels of layer %d (%d) don't match head size of previous layer (%d)",
 				i, layerArrayConfig.Channels, config.Layers[i-1].HeadSize)
 		}
-		wn.headArrays[i+1] = mat.NewMatrix(layerArrayConfig.HeadSize, 0)
+		wn.headArraysData[i+1] = mat.NewMatrix[T](layerArrayConfig.HeadSize, 0)
+	}
+
+	if config.Head != nil {
+		if err := validateHeadConfig(*config.Head); err != nil {
+			return nil, err
+		}
+		headSize := config.Layers[len(config.Layers)-1].HeadSize
+		h, err := head.New[T](*config.Head, headSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create head: %w", err)
+		}
+		wn.head = h
+		wn.headInputData = mat.NewMatrix[T](headSize, 0)
 	}
 
 	if err := wn.SetParams(params); err != nil {
 		return nil, err
 	}
 
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.maxBlockSize < 0 {
+		return nil, fmt.Errorf("invalid maximum block size %d", o.maxBlockSize)
+	}
+	if o.maxBlockSize > 0 {
+		wn.SetMaxBlockSize(o.maxBlockSize)
+	}
+
 	wn.warmUp()
+
+	if o.numWorkers > 1 {
+		wn.pool = parallel.NewPool(o.numWorkers)
+		for _, layerArray := range wn.layerArrays {
+			layerArray.SetPool(wn.pool)
+		}
+	}
 	return wn, nil
 }
 
-func (m *Model) warmUp() {
-	receptiveField := m.getReceptiveField()
+// Close stops the workers, if any. The model can still be used afterwards,
+// processing serially.
+func (m *Model[T]) Close() {
+	if m.pool == nil {
+		return
+	}
+	for _, layerArray := range m.layerArrays {
+		layerArray.SetPool(nil)
+	}
+	m.pool.Close()
+	m.pool = nil
+}
+
+func validateHeadConfig(config head.Config) error {
+	if config.NumLayers < 1 {
+		return fmt.Errorf("expected at least one head layer, actual %d", config.NumLayers)
+	}
+	if config.NumLayers > 1 && config.Channels < 1 {
+		return fmt.Errorf("invalid head channels %d", config.Channels)
+	}
+	if config.OutChannels != 1 {
+		return fmt.Errorf("expected 1 head output channel, actual %d", config.OutChannels)
+	}
+	return nil
+}
+
+func (m *Model[T]) warmUp() {
+	receptiveField := m.ReceptiveField()
 	samples := []float32{0}
 	for i := 0; i < receptiveField; i++ {
 		m.Process(samples, samples)
@@ -86,7 +195,16 @@ func (m *Model) warmUp() {
 	}
 }
 
-func (m *Model) getReceptiveField() int {
+// Latency is always zero: the model is causal.
+func (m *Model[T]) Latency() int {
+	return 0
+}
+
+func (m *Model[T]) SampleRate() int {
+	return m.sampleRate
+}
+
+func (m *Model[T]) ReceptiveField() int {
 	receptiveField := 1
 	for _, layerArray := range m.layerArrays {
 		receptiveField += layerArray.GetReceptiveField()
@@ -94,48 +212,94 @@ func (m *Model) getReceptiveField() int {
 	return receptiveField
 }
 
-func (m *Model) Finalize(numFrames int) {
+func (m *Model[T]) Finalize(numFrames int) {
 	m.advanceBuffers(numFrames)
 }
 
-func (m *Model) SetParams(params *floats.Reader) error {
+// Reset restores the state of a silent input.
+func (m *Model[T]) Reset() {
 	for _, layerArray := range m.layerArrays {
-		layerArray.SetParams(params)
+		layerArray.Reset()
 	}
-	m.headScale = params.Next()
-	if params.HasNext() {
-		return fmt.Errorf("too many parameters")
+	m.warmUp()
+}
+
+// State is a snapshot of the internal state of a Model, that is, the
+// history of its layers.
+type State[T mat.Float] struct {
+	layerArrays []layerarray.State[T]
+}
+
+// Snapshot returns a copy of the internal state, for restoring it later,
+// possibly on another Model with the same configuration.
+func (m *Model[T]) Snapshot() State[T] {
+	layerArrays := make([]layerarray.State[T], len(m.layerArrays))
+	for i, layerArray := range m.layerArrays {
+		layerArrays[i] = layerArray.Snapshot()
+	}
+	return State[T]{layerArrays: layerArrays}
+}
+
+// Restore restores a snapshot of the internal state, taken from this Model
+// or from another one with the same configuration: the processing resumes
+// as it would have after the snapshot. It doesn't allocate, so it can be
+// called by real-time audio callbacks. A state that doesn't match the
+// configuration is reported as an error, leaving the model unchanged.
+func (m *Model[T]) Restore(state State[T]) error {
+	if len(state.layerArrays) != len(m.layerArrays) {
+		return fmt.Errorf("expected %d layer arrays, actual %d", len(m.layerArrays), len(state.layerArrays))
+	}
+	for i, layerArray := range m.layerArrays {
+		if err := layerArray.CheckState(state.layerArrays[i]); err != nil {
+			return fmt.Errorf("state doesn't match layer array %d: %w", i, err)
+		}
+	}
+	for i, layerArray := range m.layerArrays {
+		if err := layerArray.Restore(state.layerArrays[i]); err != nil {
+			return fmt.Errorf("failed to restore layer array %d: %w", i, err)
+		}
 	}
 	return nil
 }
 
-func (m *Model) advanceBuffers(numFrames int) {
+func (m *Model[T]) SetParams(params *floats.Reader) error {
 	for _, layerArray := range m.layerArrays {
-		layerArray.AdvanceBuffers(numFrames)
+		layerArray.SetParams(params)
+	}
+	if m.head != nil {
+		m.head.SetParams(params)
 	}
+	m.headScale = T(params.Next())
+	if params.HasNext() {
+		return fmt.Errorf("too many parameters")
+	}
+	return nil
 }
 
-func (m *Model) prepareForFrames(numFrames int) {
+func (m *Model[T]) advanceBuffers(numFrames int) {
 	for _, layerArray := range m.layerArrays {
-		layerArray.PrepareForFrames(numFrames)
+		layerArray.AdvanceBuffers(numFrames)
 	}
 }
 
-func (m *Model) setNumFrames(numFrames int) {
+func (m *Model[T]) setNumFrames(numFrames int) {
 	if numFrames == m.numFrames {
 		return
 	}
 
-	m.condition = m.condition.Resize(1, numFrames)
-	for i, headArray := range m.headArrays {
-		m.headArrays[i] = headArray.Resize(headArray.Rows(), numFrames)
+	m.condition = mat.ResizeView(&m.conditionData, numFrames)
+	for i := range m.headArrays {
+		m.headArrays[i] = mat.ResizeView(&m.headArraysData[i], numFrames)
 	}
-	for i, layerArrayOutput := range m.layerArrayOutputs {
-		m.layerArrayOutputs[i] = layerArrayOutput.Resize(layerArrayOutput.Rows(), numFrames)
+	for i := range m.layerArrayOutputs {
+		m.layerArrayOutputs[i] = mat.ResizeView(&m.layerArrayOutputsData[i], numFrames)
 	}
 
-	m.headOutput = m.headOutput.Resize(m.headOutput.Rows(), numFrames)
-	m.headOutput.SetZero()
+	m.headOutput = mat.ResizeView(&m.headOutputData, numFrames)
+	if m.head != nil {
+		m.headInput = mat.ResizeView(&m.headInputData, numFrames)
+		m.head.SetNumFrames(numFrames)
+	}
 
 	for _, layerArray := range m.layerArrays {
 		layerArray.SetNumFrames(numFrames)
@@ -143,15 +307,39 @@ func (m *Model) setNumFrames(numFrames int) {
 	m.numFrames = numFrames
 }
 
-func (m *Model) Process(input, output []float32) {
-	numFrames := len(input)
+// SetMaxBlockSize allocates the memory needed to process blocks of up to
+// maxBlockSize frames, so that processing them doesn't allocate. Larger
+// blocks can be processed anyway, allocating more memory.
+func (m *Model[T]) SetMaxBlockSize(maxBlockSize int) {
+	numFrames := m.numFrames
+	m.setNumFrames(maxBlockSize)
 	m.setNumFrames(numFrames)
-	m.prepareForFrames(numFrames)
+}
+
+func (m *Model[T]) Process(input, output []float32) {
+	process(m, input, output)
+}
 
+// ProcessFloat64 is like Process, for float64 samples, which a Model[float64]
+// processes without rounding them to float32.
+func (m *Model[T]) ProcessFloat64(input, output []float64) {
+	process(m, input, output)
+}
+
+func process[T, S mat.Float](m *Model[T], input, output []S) {
+	m.setNumFrames(len(input))
 	for j, inputValue := range input {
-		m.condition.Set(0, j, inputValue)
+		m.condition.Set(0, j, T(inputValue))
 	}
+	result, scale := m.forward()
+	for i := range output {
+		output[i] = S(scale * result.Get(0, i))
+	}
+}
 
+// forward processes the condition, returning the output, which must be
+// scaled by the returned factor.
+func (m *Model[T]) forward() (mat.Matrix[T], T) {
 	m.headArrays[0].SetZero()
 
 	m.layerArrays[0].Process(
@@ -172,7 +360,16 @@ func (m *Model) Process(input, output []float32) {
 	}
 
 	finalHeadArray := m.headArrays[len(m.headArrays)-1]
-	for i := range output {
-		output[i] = m.headScale * finalHeadArray.Get(0, i)
+	if m.head == nil {
+		return finalHeadArray, m.headScale
+	}
+
+	// The head is applied after scaling.
+	for r := 0; r < m.headInput.Rows(); r++ {
+		for j := 0; j < m.numFrames; j++ {
+			m.headInput.Set(r, j, m.headScale*finalHeadArray.Get(r, j))
+		}
 	}
+	m.head.Process(m.headInput, m.headOutput)
+	return m.headOutput, 1
 }
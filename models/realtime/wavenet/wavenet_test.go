@@ -0,0 +1,426 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wavenet
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nlpodyssey/waveny/floats"
+	"github.com/nlpodyssey/waveny/models/realtime"
+	"github.com/nlpodyssey/waveny/models/realtime/wavenet/layerarray"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// standardConfig is the "standard" WaveNet configuration of NAM.
+func standardConfig() Config {
+	dilations := []int{1, 2, 4, 8, 16, 32, 64, 128, 256, 512}
+	return Config{
+		HeadScale: 0.02,
+		Layers: []layerarray.Config{
+			{
+				InputSize: 1, ConditionSize: 1, HeadSize: 8, Channels: 16, KernelSize: 3,
+				Dilations: dilations, Activation: "Tanh", Gated: false, HeadBias: false,
+			},
+			{
+				InputSize: 16, ConditionSize: 1, HeadSize: 1, Channels: 8, KernelSize: 3,
+				Dilations: dilations, Activation: "Tanh", Gated: false, HeadBias: true,
+			},
+		},
+	}
+}
+
+// numParams returns the amount of parameters of a configuration without
+// head.
+func numParams(config Config) int {
+	n := 1 // head scale
+	for _, c := range config.Layers {
+		n += c.InputSize * c.Channels
+		outChannels := c.Channels
+		if c.Gated {
+			outChannels *= 2
+		}
+		perLayer := c.Channels*outChannels*c.KernelSize + outChannels + // convolution
+			c.ConditionSize*outChannels + // input mixin
+			c.Channels*c.Channels + c.Channels // 1x1 convolution
+		n += len(c.Dilations) * perLayer
+		n += c.Channels * c.HeadSize
+		if c.HeadBias {
+			n += c.HeadSize
+		}
+	}
+	return n
+}
+
+func randParams(config Config) []float64 {
+	rnd := rand.New(rand.NewSource(1))
+	params := make([]float64, numParams(config))
+	for i := range params {
+		params[i] = rnd.NormFloat64() * 0.1
+	}
+	return params
+}
+
+func TestProcessWorkers(t *testing.T) {
+	for _, gated := range []bool{false, true} {
+		t.Run(fmt.Sprintf("gated=%v", gated), func(t *testing.T) {
+			config := standardConfig()
+			for i := range config.Layers {
+				config.Layers[i].Gated = gated
+			}
+			params := randParams(config)
+			serial, err := New[float32](config, floats.NewReader(params))
+			if err != nil {
+				t.Fatal(err)
+			}
+			parallel, err := New[float32](config, floats.NewReader(params), WithWorkers(4))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer parallel.Close()
+
+			rnd := rand.New(rand.NewSource(2))
+			for _, n := range []int{1, 64, 7, 300, 2} {
+				input := make([]float32, n)
+				for i := range input {
+					input[i] = float32(rnd.NormFloat64() * 0.3)
+				}
+				expected, actual := make([]float32, n), make([]float32, n)
+				serial.Process(input, expected)
+				serial.Finalize(n)
+				parallel.Process(input, actual)
+				parallel.Finalize(n)
+				for i := range expected {
+					if expected[i] != actual[i] {
+						t.Fatalf("block of %d frames, frame %d: expected %g, actual %g", n, i, expected[i], actual[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestProcessFloat64(t *testing.T) {
+	config := standardConfig()
+	params := randParams(config)
+	model32, err := New[float32](config, floats.NewReader(params))
+	if err != nil {
+		t.Fatal(err)
+	}
+	model64, err := New[float64](config, floats.NewReader(params))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parallel64, err := New[float64](config, floats.NewReader(params), WithWorkers(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parallel64.Close()
+
+	rnd := rand.New(rand.NewSource(2))
+	for _, n := range []int{1, 64, 7, 300, 2} {
+		input := make([]float32, n)
+		for i := range input {
+			input[i] = float32(rnd.NormFloat64() * 0.3)
+		}
+		output32, output64, parallelOutput64 := make([]float32, n), make([]float32, n), make([]float32, n)
+		for _, m := range []struct {
+			model  realtime.Model
+			output []float32
+		}{{model32, output32}, {model64, output64}, {parallel64, parallelOutput64}} {
+			m.model.Process(input, m.output)
+			m.model.Finalize(n)
+		}
+		for i := range output64 {
+			if parallelOutput64[i] != output64[i] {
+				t.Fatalf("block of %d frames, frame %d: expected %g with workers, actual %g", n, i, output64[i], parallelOutput64[i])
+			}
+			// Only the rounding differs.
+			if math.Abs(float64(output32[i]-output64[i])) > 1e-5 {
+				t.Fatalf("block of %d frames, frame %d: float32 %g, float64 %g", n, i, output32[i], output64[i])
+			}
+		}
+	}
+}
+
+func TestFloat64Weights(t *testing.T) {
+	config := standardConfig()
+	rawConfig, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := randParams(config)
+	// The head scale is the last weight, and 0.1 isn't a float32.
+	params[len(params)-1] = 0.1
+	b, err := json.Marshal(realtime.ModelData{Architecture: "WaveNet", Config: rawConfig, Weights: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var modelData realtime.ModelData
+	if err = json.Unmarshal(b, &modelData); err != nil {
+		t.Fatal(err)
+	}
+	model, err := NewFromModelData[float64](&modelData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.headScale != 0.1 {
+		t.Errorf("expected head scale 0.1, actual %v", model.headScale)
+	}
+
+	input := make([]float64, 64)
+	for i := range input {
+		input[i] = math.Sin(float64(i) / 5)
+	}
+	output := make([]float64, len(input))
+	model.ProcessFloat64(input, output)
+	rounded := 0
+	for _, v := range output {
+		if float64(float32(v)) == v {
+			rounded++
+		}
+	}
+	if rounded == len(output) {
+		t.Error("expected float64 output, actual float32 values")
+	}
+}
+
+func TestProcessAllocations(t *testing.T) {
+	config := standardConfig()
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			model, err := New[float32](config, floats.NewReader(randParams(config)), WithWorkers(workers))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer model.Close()
+			model.SetMaxBlockSize(256)
+			input, output := make([]float32, 256), make([]float32, 256)
+			process := func() {
+				for _, n := range []int{1, 256, 7, 64, 255, 3} {
+					model.Process(input[:n], output[:n])
+					model.Finalize(n)
+				}
+			}
+			// The runtime allocates once what is needed for parking idle
+			// workers, and for switching their threads.
+			for i := 0; i < 5; i++ {
+				process()
+				time.Sleep(20 * time.Millisecond)
+			}
+			allocs := testing.AllocsPerRun(10, process)
+			if allocs != 0 {
+				t.Errorf("expected no allocations, actual %g", allocs)
+			}
+		})
+	}
+}
+
+// BenchmarkProcess reports the real-time factor of the standard model, that
+// is, the amount of audio seconds processed per second, for different
+// block sizes, serially and with 4 workers.
+func BenchmarkProcess(b *testing.B) {
+	config := standardConfig()
+	params := randParams(config)
+	rnd := rand.New(rand.NewSource(2))
+
+	for _, workers := range []int{1, 4} {
+		for _, blockSize := range []int{16, 64, 256, 4096} {
+			b.Run(fmt.Sprintf("workers=%d/block=%d", workers, blockSize), func(b *testing.B) {
+				model, err := New[float32](config, floats.NewReader(params), WithWorkers(workers))
+				if err != nil {
+					b.Fatal(err)
+				}
+				defer model.Close()
+				input := make([]float32, blockSize)
+				for i := range input {
+					input[i] = float32(rnd.NormFloat64() * 0.3)
+				}
+				output := make([]float32, blockSize)
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					model.Process(input, output)
+					model.Finalize(blockSize)
+				}
+				seconds := float64(b.N*blockSize) / realtime.DefaultSampleRate
+				b.ReportMetric(seconds/b.Elapsed().Seconds(), "x-realtime")
+			})
+		}
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	config := standardConfig()
+	params := randParams(config)
+	model, err := New[float32](config, floats.NewReader(params))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fork, err := New[float32](config, floats.NewReader(params))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rnd := rand.New(rand.NewSource(2))
+	process := func(m *Model[float32], input []float32) []float32 {
+		output := make([]float32, len(input))
+		m.Process(input, output)
+		m.Finalize(len(input))
+		return output
+	}
+	randInput := func(n int) []float32 {
+		input := make([]float32, n)
+		for i := range input {
+			input[i] = float32(rnd.NormFloat64() * 0.3)
+		}
+		return input
+	}
+
+	process(model, randInput(5000))
+	state := model.Snapshot()
+	input := randInput(300)
+	expected := process(model, input)
+
+	// The buffers are rewound meanwhile.
+	for i := 0; i < 300; i++ {
+		process(model, randInput(256))
+	}
+	var restoreErr error
+	if allocs := testing.AllocsPerRun(1, func() { restoreErr = model.Restore(state) }); allocs != 0 {
+		t.Errorf("expected no allocations restoring, actual %g", allocs)
+	}
+	if restoreErr != nil {
+		t.Fatal(restoreErr)
+	}
+	if err := fork.Restore(state); err != nil {
+		t.Fatal(err)
+	}
+	for name, m := range map[string]*Model[float32]{"restored": model, "fork": fork} {
+		actual := process(m, input)
+		for i := range expected {
+			if expected[i] != actual[i] {
+				t.Fatalf("%s: frame %d: expected %g, actual %g", name, i, expected[i], actual[i])
+			}
+		}
+	}
+}
+
+func TestRestoreMismatch(t *testing.T) {
+	config := standardConfig()
+	model, err := New[float32](config, floats.NewReader(randParams(config)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	twin, err := New[float32](config, floats.NewReader(randParams(config)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the second layer array differs, so that a partial restore
+	// would be noticed.
+	otherConfig := standardConfig()
+	otherConfig.Layers[1].Dilations = []int{1, 2, 4}
+	other, err := New[float32](otherConfig, floats.NewReader(randParams(otherConfig)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := make([]float32, 2048)
+	for i := range input {
+		input[i] = float32(i%13) * 0.05
+	}
+	other.Process(input, make([]float32, len(input)))
+	other.Finalize(len(input))
+	if err := model.Restore(other.Snapshot()); err == nil {
+		t.Fatal("expected an error restoring a state of another configuration")
+	}
+
+	expected := make([]float32, len(input))
+	twin.Process(input, expected)
+	actual := make([]float32, len(input))
+	model.Process(input, actual)
+	for i := range expected {
+		if expected[i] != actual[i] {
+			t.Fatalf("frame %d: expected %g, actual %g", i, expected[i], actual[i])
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	config := standardConfig()
+	params := randParams(config)
+	model, err := New[float32](config, floats.NewReader(params))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := make([]float32, 64)
+	for i := range input {
+		input[i] = float32(i%7) * 0.1
+	}
+	expected := make([]float32, len(input))
+	model.Process(input, expected)
+	model.Finalize(len(input))
+
+	model.Reset()
+	actual := make([]float32, len(input))
+	model.Process(input, actual)
+	for i := range expected {
+		if expected[i] != actual[i] {
+			t.Fatalf("frame %d: expected %g, actual %g", i, expected[i], actual[i])
+		}
+	}
+}
+
+// TestProcessBlockSizes compares processing an input at once, and in blocks
+// of varying sizes, growing the layer buffers and wrapping around them.
+func TestProcessBlockSizes(t *testing.T) {
+	config := standardConfig()
+	params := randParams(config)
+	rnd := rand.New(rand.NewSource(2))
+	input := make([]float32, 20000)
+	for i := range input {
+		input[i] = float32(rnd.NormFloat64() * 0.3)
+	}
+
+	model, err := New[float32](config, floats.NewReader(params), WithMaxBlockSize(len(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := make([]float32, len(input))
+	model.Process(input, expected)
+
+	if model, err = New[float32](config, floats.NewReader(params)); err != nil {
+		t.Fatal(err)
+	}
+	actual := make([]float32, len(input))
+	for from := 0; from < len(input); {
+		to := min(from+1+rnd.Intn(from/10+1), len(input))
+		model.Process(input[from:to], actual[from:to])
+		model.Finalize(to - from)
+		from = to
+	}
+	for i := range expected {
+		// Rounding differs between vectorized and scalar columns.
+		if math.Abs(float64(actual[i]-expected[i])) > 1e-5 {
+			t.Fatalf("frame %d: expected %g, actual %g", i, expected[i], actual[i])
+		}
+	}
+
+	if _, err = New[float32](config, floats.NewReader(params), WithMaxBlockSize(-1)); err == nil {
+		t.Error("expected an error for a negative maximum block size")
+	}
+}
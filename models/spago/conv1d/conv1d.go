@@ -113,7 +113,7 @@ func (m *Model) ExportParams(w *floats.Writer) {
 		for i := 0; i < outChannels; i++ {
 			for j := 0; j < inChannels; j++ {
 				for k := range m.Weights {
-					w.Write(m.Weights[k].At(i, j).Item().F32())
+					w.Write(m.Weights[k].At(i, j).Item().F64())
 				}
 			}
 		}
@@ -122,7 +122,7 @@ func (m *Model) ExportParams(w *floats.Writer) {
 	if m.Bias != nil {
 		size := m.Bias.Size()
 		for i := 0; i < size; i++ {
-			w.Write(m.Bias.At(i).Item().F32())
+			w.Write(m.Bias.At(i).Item().F64())
 		}
 	}
 }
@@ -86,14 +86,14 @@ func (m *Model) ExportParams(w *floats.Writer) {
 
 	for i := 0; i < outChannels; i++ {
 		for j := 0; j < inChannels; j++ {
-			w.Write(m.Weights.At(i, j).Item().F32())
+			w.Write(m.Weights.At(i, j).Item().F64())
 		}
 	}
 
 	if m.Bias != nil {
 		size := m.Bias.Size()
 		for i := 0; i < size; i++ {
-			w.Write(m.Bias.At(i).Item().F32())
+			w.Write(m.Bias.At(i).Item().F64())
 		}
 	}
 }
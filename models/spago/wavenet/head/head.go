@@ -0,0 +1,93 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package head
+
+import (
+	"github.com/nlpodyssey/spago/mat"
+	"github.com/nlpodyssey/spago/nn"
+	"github.com/nlpodyssey/spago/nn/activation"
+	"github.com/nlpodyssey/waveny/floats"
+	rthead "github.com/nlpodyssey/waveny/models/realtime/wavenet/head"
+	"github.com/nlpodyssey/waveny/models/spago/conv1x1"
+)
+
+// A Config specifies the configuration for instantiating a new head Model.
+// The fields are matching the JSON model configuration.
+type Config struct {
+	Channels    int    `json:"channels"`
+	Activation  string `json:"activation"`
+	NumLayers   int    `json:"num_layers"`
+	OutChannels int    `json:"out_channels"`
+}
+
+// Model is the WaveNet head: a stack of layers, each one made of an
+// activation followed by a 1x1 convolution.
+type Model struct {
+	nn.Module
+	Config     Config
+	Activation *activation.Model
+	Layers     []*conv1x1.Model
+}
+
+// New creates a new head Model, whose input has the given channels (the
+// head size of the last layer array).
+func New(c Config, inChannels int) *Model {
+	layers := make([]*conv1x1.Model, c.NumLayers)
+	for i := range layers {
+		outChannels := c.Channels
+		if i == c.NumLayers-1 {
+			outChannels = c.OutChannels
+		}
+		layers[i] = conv1x1.New(conv1x1.Config{
+			InChannels:  inChannels,
+			OutChannels: outChannels,
+			Bias:        true,
+		})
+		inChannels = outChannels
+	}
+	return &Model{
+		Config:     c,
+		Activation: activation.New(activation.MustParseActivation(c.Activation)),
+		Layers:     layers,
+	}
+}
+
+func (m *Model) Forward(x mat.Tensor) mat.Tensor {
+	for _, l := range m.Layers {
+		x = l.Forward(m.Activation.Forward(x)[0])
+	}
+	return x
+}
+
+func (m *Model) ResetParameters() {
+	for _, l := range m.Layers {
+		l.ResetParameters()
+	}
+}
+
+func (m *Model) ExportConfig() rthead.Config {
+	return rthead.Config{
+		Channels:    m.Config.Channels,
+		Activation:  m.Config.Activation,
+		NumLayers:   m.Config.NumLayers,
+		OutChannels: m.Config.OutChannels,
+	}
+}
+
+func (m *Model) ExportParams(w *floats.Writer) {
+	for _, l := range m.Layers {
+		l.ExportParams(w)
+	}
+}
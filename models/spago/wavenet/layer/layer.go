@@ -94,14 +94,16 @@ func (m *Model) activation(z1 mat.Tensor) mat.Tensor {
 	if !m.Gated {
 		return m.Activation.Forward(z1)[0]
 	}
+	// Rows are channels: the top half is activated, then gated by the
+	// sigmoid of the bottom half.
 	channels := m.Conv1x1.InChannels
 	shape := z1.Shape()
-	return ag.Mul(
+	return ag.Prod(
 		m.Activation.Forward(
-			ag.Slice(z1, 0, 0, shape[0], channels),
+			ag.Slice(z1, 0, 0, channels, shape[1]),
 		)[0],
 		ag.Sigmoid(
-			ag.Slice(z1, 0, channels, shape[0], shape[1]),
+			ag.Slice(z1, channels, 0, shape[0], shape[1]),
 		),
 	)
 }
@@ -24,6 +24,7 @@ import (
 	"github.com/nlpodyssey/waveny/models/spago/conv1d"
 	"github.com/nlpodyssey/waveny/models/spago/conv1x1"
 	"github.com/nlpodyssey/waveny/models/spago/wavenet"
+	"github.com/nlpodyssey/waveny/models/spago/wavenet/head"
 	"github.com/nlpodyssey/waveny/models/spago/wavenet/layer"
 	"github.com/nlpodyssey/waveny/models/spago/wavenet/layerarray"
 	"strings"
@@ -52,6 +53,21 @@ func loadModelState(model *wavenet.Model, state StateMap) error {
 			return fmt.Errorf("failed to load state for layer-array %d: %w", i, err)
 		}
 	}
+	if model.Head != nil {
+		if err := loadModelHead(model.Head, state.ExtractPrefixedSubset("_net._net._head.")); err != nil {
+			return fmt.Errorf("failed to load state for head: %w", err)
+		}
+	}
+	return nil
+}
+
+func loadModelHead(model *head.Model, state StateMap) error {
+	for i, l := range model.Layers {
+		layerState := state.ExtractPrefixedSubset(fmt.Sprintf("_layers.layer_%d.conv.", i))
+		if err := loadConv1x1(l, layerState); err != nil {
+			return fmt.Errorf("failed to load head layer %d: %w", i, err)
+		}
+	}
 	return nil
 }
 
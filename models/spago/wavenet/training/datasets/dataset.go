@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"github.com/nlpodyssey/spago/ag"
 	"github.com/nlpodyssey/spago/mat"
+	"github.com/nlpodyssey/waveny/audiofile"
 	"github.com/nlpodyssey/waveny/wave"
 )
 
@@ -28,8 +29,15 @@ type DatasetConfig struct {
 	Stop  int
 	NX    int
 	NY    int
+	// Channel selects the channel, or the downmix, of X and Y to be used
+	// for training. Processing all channels independently is not allowed.
+	Channel wave.ChannelSelection
 }
 
+// sampleRate is the sample rate of the training data: input and target
+// files with different rates are converted.
+const sampleRate = 48_000
+
 type Dataset struct {
 	sampleRate int
 	x          mat.Tensor
@@ -39,11 +47,14 @@ type Dataset struct {
 }
 
 func NewDataset(config DatasetConfig) (*Dataset, error) {
-	x, err := wave.WavToSpagoTensor(config.XPath)
+	if config.Channel.Mode == wave.AllChannels {
+		return nil, fmt.Errorf("cannot train on all channels independently: select a single channel or a downmix")
+	}
+	x, err := loadTensor(config.XPath, config.Channel)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert X wave file to tensor: %w", err)
 	}
-	y, err := wave.WavToSpagoTensor(config.YPath)
+	y, err := loadTensor(config.YPath, config.Channel)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert Y wave file to tensor: %w", err)
 	}
@@ -93,7 +104,7 @@ func NewDataset(config DatasetConfig) (*Dataset, error) {
 		ny = x.Size() - config.NX + 1
 	}
 	d := &Dataset{
-		sampleRate: 48000,
+		sampleRate: sampleRate,
 		x:          x,
 		y:          y,
 		nx:         config.NX,
@@ -102,6 +113,25 @@ func NewDataset(config DatasetConfig) (*Dataset, error) {
 	return d, nil
 }
 
+// loadTensor reads an audio file, returning the selected channel as a tensor,
+// resampled to the dataset sample rate if needed.
+func loadTensor(path string, channel wave.ChannelSelection) (mat.Tensor, error) {
+	data, format, _, err := audiofile.FileToFloats(path)
+	if err != nil {
+		return nil, err
+	}
+	channels := int(format.Channels)
+	if err = channel.Validate(channels); err != nil {
+		return nil, err
+	}
+	stream := channel.SplitAll(data, channels)[0]
+	stream, err = wave.Resample(stream, int(format.SampleRate), sampleRate, wave.ResampleHigh)
+	if err != nil {
+		return nil, err
+	}
+	return mat.NewDense[float32](mat.WithBacking(stream)), nil
+}
+
 func (d *Dataset) Length() int {
 	n := d.x.Size()
 	singlePairs := n - d.nx + 1
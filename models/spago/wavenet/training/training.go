@@ -19,6 +19,7 @@ import (
 	"github.com/nlpodyssey/waveny/models/spago/wavenet"
 	datasets2 "github.com/nlpodyssey/waveny/models/spago/wavenet/training/datasets"
 	"github.com/nlpodyssey/waveny/models/spago/wavenet/training/trainer"
+	"github.com/nlpodyssey/waveny/wave"
 	"os"
 	"path/filepath"
 	"time"
@@ -39,6 +40,8 @@ type Config struct {
 	TrainingBatchSize int  // batch size
 	TrainingShuffle   bool // whether to enable shuffling
 	TrainingDropLast  bool
+
+	Channel wave.ChannelSelection // channel of input and target files
 }
 
 // PathsConfig provides a series of paths to input/output files or folders.
@@ -97,6 +100,8 @@ func makeTrainingDatasetConfig(pathsConfig PathsConfig, config Config, model *wa
 		Stop:  config.TrainingSetStop,
 		NX:    model.ReceptiveField,
 		NY:    config.TrainingSetNY,
+
+		Channel: config.Channel,
 	}
 }
 
@@ -108,6 +113,8 @@ func makeValidationDatasetConfig(pathsConfig PathsConfig, config Config, model *
 		Stop:  config.ValidationSetStop,
 		NX:    model.ReceptiveField,
 		NY:    config.ValidationSetNY,
+
+		Channel: config.Channel,
 	}
 }
 
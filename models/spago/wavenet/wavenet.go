@@ -22,17 +22,22 @@ import (
 	"github.com/nlpodyssey/spago/mat"
 	"github.com/nlpodyssey/spago/nn"
 	"github.com/nlpodyssey/waveny/floats"
+	"github.com/nlpodyssey/waveny/models/realtime"
 	rtwavenet "github.com/nlpodyssey/waveny/models/realtime/wavenet"
+	rthead "github.com/nlpodyssey/waveny/models/realtime/wavenet/head"
 	rtlayerarray "github.com/nlpodyssey/waveny/models/realtime/wavenet/layerarray"
+	"github.com/nlpodyssey/waveny/models/spago/wavenet/head"
 	"github.com/nlpodyssey/waveny/models/spago/wavenet/layerarray"
 	"github.com/nlpodyssey/waveny/models/spago/wavenet/training/datasets"
 	"os"
+	"strconv"
 )
 
 // A Config specifies the configuration for instantiating a new WaveNet Model.
 // The fields are matching the JSON model configuration.
 type Config struct {
 	HeadScale     float32             `json:"head_scale"`
+	HeadConfig    *head.Config        `json:"head_config"`
 	LayersConfigs []layerarray.Config `json:"layers_configs"`
 }
 
@@ -43,7 +48,8 @@ type Model struct {
 	ReceptiveField int
 	// Avoid recomputing padding vector at each inference
 	ZeroPadding mat.Tensor
-	// TODO: WaveNet Head not implemented
+	// Head is applied after HeadScale. It is nil if not configured.
+	Head *head.Model
 }
 
 // New creates a new WaveNet Model.
@@ -55,9 +61,18 @@ func New(config Config) *Model {
 		HeadScale:      ag.StopGrad(mat.Scalar(config.HeadScale)),
 		ReceptiveField: receptiveField,
 		ZeroPadding:    ag.StopGrad(mat.NewDense[float32](mat.WithShape(1, receptiveField-1))),
+		Head:           makeHead(config),
 	}
 }
 
+func makeHead(config Config) *head.Model {
+	if config.HeadConfig == nil {
+		return nil
+	}
+	lastLayer := config.LayersConfigs[len(config.LayersConfigs)-1]
+	return head.New(*config.HeadConfig, lastLayer.HeadSize)
+}
+
 func makeLayers(configs []layerarray.Config) []*layerarray.Model {
 	layers := make([]*layerarray.Model, len(configs))
 	for i, layerArrayConfig := range configs {
@@ -100,13 +115,20 @@ func (m *Model) forwardOne(x mat.Tensor, padStart bool) mat.Tensor {
 		headInput, y = layers.Forward(y, x, headInput)
 	}
 
-	return ag.ProdScalar(headInput, m.HeadScale)
+	y = ag.ProdScalar(headInput, m.HeadScale)
+	if m.Head != nil {
+		y = m.Head.Forward(y)
+	}
+	return y
 }
 
 func (m *Model) ResetParameters() {
 	for _, layers := range m.Layers {
 		layers.ResetParameters()
 	}
+	if m.Head != nil {
+		m.Head.ResetParameters()
+	}
 }
 
 func (m *Model) TrainingStep(batch datasets.XYDataPair) mat.Tensor {
@@ -133,12 +155,20 @@ func computeESRLoss(preds, targets mat.Tensor) mat.Tensor {
 
 func (m *Model) ExportConfig() rtwavenet.Config {
 	return rtwavenet.Config{
-		HeadScale: m.HeadScale.Item().F32(),
-		Head:      nil,
+		HeadScale: m.HeadScale.Item().F64(),
+		Head:      m.exportHeadConfig(),
 		Layers:    m.exportLayersConfig(),
 	}
 }
 
+func (m *Model) exportHeadConfig() *rthead.Config {
+	if m.Head == nil {
+		return nil
+	}
+	c := m.Head.ExportConfig()
+	return &c
+}
+
 func (m *Model) exportLayersConfig() []rtlayerarray.Config {
 	cs := make([]rtlayerarray.Config, len(m.Layers))
 	for i, l := range m.Layers {
@@ -151,22 +181,57 @@ func (m *Model) ExportParams(w *floats.Writer) {
 	for _, l := range m.Layers {
 		l.ExportParams(w)
 	}
-	w.Write(m.HeadScale.Item().F32())
+	if m.Head != nil {
+		m.Head.ExportParams(w)
+	}
+	w.Write(m.HeadScale.Item().F64())
 }
 
-func (m *Model) ExportModelData() rtwavenet.ModelData {
+func (m *Model) ExportModelData() (*realtime.ModelData, error) {
+	// The head scale is found both in the configuration and among the
+	// weights: it's rounded the same way in both.
+	rtConfig := m.ExportConfig()
+	headScale, err := shortestFloat32(rtConfig.HeadScale)
+	if err != nil {
+		return nil, err
+	}
+	rtConfig.HeadScale = headScale
+	config, err := json.Marshal(rtConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode configuration: %w", err)
+	}
 	w := floats.NewWriter()
 	m.ExportParams(w)
-	return rtwavenet.ModelData{
+	weights := w.Floats()
+	for i, v := range weights {
+		if weights[i], err = shortestFloat32(v); err != nil {
+			return nil, err
+		}
+	}
+	return &realtime.ModelData{
 		Version:      "0.5.2",
 		Architecture: "WaveNet",
-		Config:       m.ExportConfig(),
-		Weights:      w.Floats(),
+		Config:       config,
+		Weights:      weights,
+	}, nil
+}
+
+// shortestFloat32 returns the value of the shortest decimal representation
+// of the float32 v, which still reads back as v, so that model data files
+// don't show the float64 digits of float32 parameters.
+func shortestFloat32(v float64) (float64, error) {
+	r, err := strconv.ParseFloat(strconv.FormatFloat(v, 'g', -1, 32), 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to round parameter %g: %w", v, err)
 	}
+	return r, nil
 }
 
 func (m *Model) ExportModelDataFile(name string) (err error) {
-	modelData := m.ExportModelData()
+	modelData, err := m.ExportModelData()
+	if err != nil {
+		return err
+	}
 
 	file, err := os.Create(name)
 	if err != nil {
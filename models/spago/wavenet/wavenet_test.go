@@ -0,0 +1,124 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wavenet
+
+import (
+	"encoding/json"
+	"github.com/nlpodyssey/spago/mat"
+	"github.com/nlpodyssey/waveny/models/realtime"
+	rtwavenet "github.com/nlpodyssey/waveny/models/realtime/wavenet"
+	"github.com/nlpodyssey/waveny/models/spago/wavenet/head"
+	"github.com/nlpodyssey/waveny/models/spago/wavenet/layerarray"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestRealTimeParity checks that the real-time model, loaded from exported
+// model data, matches the SpaGO forward pass.
+func TestRealTimeParity(t *testing.T) {
+	testCases := []struct {
+		name  string
+		gated bool
+		head  *head.Config
+	}{
+		{"plain", false, nil},
+		{"gated", true, nil},
+		{"head", false, &head.Config{Channels: 3, Activation: "Tanh", NumLayers: 2, OutChannels: 1}},
+		{"gated with head", true, &head.Config{Activation: "Sigmoid", NumLayers: 1, OutChannels: 1}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// The head can take more than one channel.
+			headSize := 1
+			if tc.head != nil {
+				headSize = 2
+			}
+			model := New(Config{
+				HeadScale:  0.02,
+				HeadConfig: tc.head,
+				LayersConfigs: []layerarray.Config{
+					{
+						ConditionSize: 1, InputSize: 1, Channels: 4, HeadSize: 2, KernelSize: 3,
+						Dilations: []int{1, 2, 4}, Activation: "Tanh", Gated: tc.gated,
+					},
+					{
+						ConditionSize: 1, InputSize: 4, Channels: 2, HeadSize: headSize, KernelSize: 3,
+						Dilations: []int{8, 16}, Activation: "Tanh", Gated: tc.gated, HeadBias: true,
+					},
+				},
+			})
+			model.ResetParameters()
+
+			rnd := rand.New(rand.NewSource(1))
+			input := make([]float32, 500)
+			for i := range input {
+				input[i] = float32(rnd.NormFloat64() * 0.3)
+			}
+			expected := model.Forward(mat.NewDense[float32](mat.WithBacking(input)), true).Data().F32()
+
+			// Round-trip through JSON, as with model data files.
+			exported, err := model.ExportModelData()
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := json.Marshal(exported)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var modelData realtime.ModelData
+			if err = json.Unmarshal(b, &modelData); err != nil {
+				t.Fatal(err)
+			}
+			var config rtwavenet.Config
+			if err = json.Unmarshal(modelData.Config, &config); err != nil {
+				t.Fatal(err)
+			}
+			if last := modelData.Weights[len(modelData.Weights)-1]; config.HeadScale != last {
+				t.Errorf("expected head scale %g in the configuration, as in the weights, actual %g", last, config.HeadScale)
+			}
+			rtModel, err := rtwavenet.NewFromModelData[float32](&modelData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual := make([]float32, len(input))
+			process := func() {
+				for from, size := 0, 1; from < len(input); from, size = from+size, size*2 {
+					to := min(from+size, len(input))
+					rtModel.Process(input[from:to], actual[from:to])
+					rtModel.Finalize(to - from)
+				}
+			}
+			assertClose := func() {
+				t.Helper()
+				if len(expected) != len(actual) {
+					t.Fatalf("expected %d samples, actual %d", len(expected), len(actual))
+				}
+				for i, v := range expected {
+					if math.Abs(float64(actual[i]-v)) > 1e-5 {
+						t.Fatalf("sample %d: expected %g, actual %g", i, v, actual[i])
+					}
+				}
+			}
+			process()
+			assertClose()
+
+			// After a reset, the model behaves as newly created.
+			rtModel.Reset()
+			process()
+			assertClose()
+		})
+	}
+}
@@ -0,0 +1,351 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processing
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/nlpodyssey/waveny/audiofile"
+	"github.com/nlpodyssey/waveny/models/realtime"
+	rtmat "github.com/nlpodyssey/waveny/models/realtime/mat"
+	"github.com/nlpodyssey/waveny/models/realtime/wavenet"
+	"github.com/nlpodyssey/waveny/wave"
+	"io"
+	"os"
+)
+
+type BatchConfig struct {
+	ModelDataPath string
+	// Precision is the size in bits of the floating-point values the model
+	// computes with, 32 (the default, if zero) or 64, as for RTConfig.
+	Precision int
+	// Workers is the amount of goroutines processing each layer of the
+	// model: see wavenet.WithWorkers.
+	Workers int
+}
+
+// ProcessBatchWithRTModel processes several input files at once, each one
+// described by its Config, running all their streams together through a
+// batched real-time WaveNet model. Like ProcessWithRTModel, it streams
+// chunks of frames from the inputs to the outputs.
+//
+// Shorter inputs are padded with silence, as long as longer ones are
+// processed, without affecting their outputs.
+func ProcessBatchWithRTModel(configs []Config, batchConfig BatchConfig) (err error) {
+	files := make([]*batchFile, 0, len(configs))
+	defer func() {
+		for _, f := range files {
+			if e := f.close(); e != nil && err == nil {
+				err = e
+			}
+		}
+	}()
+
+	numStreams := 0
+	for _, config := range configs {
+		f, err := openBatchFile(config)
+		if f != nil {
+			files = append(files, f)
+		}
+		if err != nil {
+			return err
+		}
+		numStreams += len(f.streams)
+	}
+
+	modelData, err := realtime.ReadModelDataJSONFile(batchConfig.ModelDataPath)
+	if err != nil {
+		return fmt.Errorf("failed to read JSON model data from file %q: %w", batchConfig.ModelDataPath, err)
+	}
+	if modelData.Architecture != "WaveNet" {
+		return fmt.Errorf("batch processing supports WaveNet models only, actual %q", modelData.Architecture)
+	}
+	switch batchConfig.Precision {
+	case 0, 32:
+		return processBatchWithPrecision[float32](modelData, files, numStreams, batchConfig)
+	case 64:
+		return processBatchWithPrecision[float64](modelData, files, numStreams, batchConfig)
+	default:
+		return fmt.Errorf("invalid precision %d: expected 32 or 64", batchConfig.Precision)
+	}
+}
+
+func processBatchWithPrecision[T rtmat.Float](modelData *realtime.ModelData, files []*batchFile, numStreams int, batchConfig BatchConfig) error {
+	model, err := wavenet.NewBatchFromModelData[T](modelData, numStreams, wavenet.WithWorkers(batchConfig.Workers))
+	if err != nil {
+		return fmt.Errorf("failed to initialize WaveNet from JSON configuration: %w", err)
+	}
+	defer model.Close()
+	model.SetMaxBlockSize(rtChunkSize)
+
+	for _, f := range files {
+		if err = f.create(model.SampleRate()); err != nil {
+			return err
+		}
+	}
+	return processBatch(model, files)
+}
+
+func processBatch[T rtmat.Float](model *wavenet.BatchModel[T], files []*batchFile) error {
+	var streams []*batchStream
+	for _, f := range files {
+		streams = append(streams, f.streams...)
+	}
+	inputs := make([][]float32, len(streams))
+	outputs := make([][]float32, len(streams))
+	outputData := make([]float32, len(streams)*rtChunkSize)
+	silence := make([]float32, rtChunkSize)
+
+	for {
+		// Each stream of a file holds the same amount of pending frames,
+		// since they are read and resampled together.
+		numFrames, active := rtChunkSize, false
+		for _, f := range files {
+			if f.done {
+				continue
+			}
+			if err := f.fill(); err != nil {
+				return err
+			}
+			if n := len(f.streams[0].pending); n > 0 {
+				numFrames, active = min(numFrames, n), true
+				continue
+			}
+			if err := f.finish(); err != nil {
+				return err
+			}
+		}
+		if !active {
+			return nil
+		}
+
+		for i, s := range streams {
+			inputs[i] = silence[:numFrames]
+			if len(s.pending) > 0 {
+				inputs[i] = s.pending[:numFrames]
+			}
+			outputs[i] = outputData[i*rtChunkSize : i*rtChunkSize+numFrames]
+		}
+		model.ProcessBatch(inputs, outputs)
+		for i, s := range streams {
+			if len(s.pending) > 0 {
+				s.consume(outputs[i])
+			}
+		}
+		for _, f := range files {
+			if f.done {
+				continue
+			}
+			if err := f.writeOutput(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// batchFile is an input file, with its output file, processed as part of
+// a batch.
+type batchFile struct {
+	config    Config
+	inFile    *os.File
+	outFile   *os.File // nil until created
+	chunks    wave.Chunks
+	dec       audiofile.Decoder
+	enc       audiofile.Encoder
+	quantizer *wave.Quantizer
+	channels  int
+	streams   []*batchStream
+	input     []float32
+	output    []float32
+	views     [][]float32
+
+	numInputFrames, numOutputFrames int
+	eof, done                       bool
+}
+
+// batchStream is a mono stream of a batchFile.
+type batchStream struct {
+	toModel   *wave.Resampler // nil if no conversion is needed
+	fromModel *wave.Resampler // nil if no conversion is needed
+	input     []float32       // input buffer, for convenience
+	pending   []float32       // model input not processed yet
+	output    []float32       // pending output, at the input sample rate
+}
+
+// openBatchFile opens the input file. The returned batchFile, if not nil,
+// must be closed, even on error.
+func openBatchFile(config Config) (*batchFile, error) {
+	inFile, err := os.Open(config.InputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audio file %q: %w", config.InputPath, err)
+	}
+	f := &batchFile{config: config, inFile: inFile}
+
+	// Metadata chunks may also follow the wave data, so they are read
+	// upfront, before streaming.
+	if f.chunks, err = audiofile.ReadChunks(inFile); err != nil {
+		return f, fmt.Errorf("failed to read audio file %q: %w", config.InputPath, err)
+	}
+	if _, err = inFile.Seek(0, io.SeekStart); err != nil {
+		return f, fmt.Errorf("failed to seek audio file %q: %w", config.InputPath, err)
+	}
+	if f.dec, err = audiofile.NewDecoder(bufio.NewReader(inFile)); err != nil {
+		return f, fmt.Errorf("failed to read audio file %q: %w", config.InputPath, err)
+	}
+	format := f.dec.Format()
+	if err = checkInputFormat(format, config.Channel); err != nil {
+		return f, fmt.Errorf("audio file %q: %w", config.InputPath, err)
+	}
+
+	f.channels = int(format.Channels)
+	f.input = make([]float32, rtChunkSize*f.channels)
+	f.streams = make([]*batchStream, config.Channel.NumStreams(f.channels))
+	f.views = make([][]float32, len(f.streams))
+	for i := range f.streams {
+		f.streams[i] = &batchStream{input: make([]float32, rtChunkSize)}
+	}
+	return f, nil
+}
+
+// create creates the output file, and the resamplers to and from the
+// model sample rate, if needed.
+func (f *batchFile) create(modelRate int) (err error) {
+	inputFormat := f.dec.Format()
+	if inputRate := int(inputFormat.SampleRate); inputRate != modelRate {
+		for _, s := range f.streams {
+			if s.toModel, err = wave.NewResampler(inputRate, modelRate, wave.ResampleHigh); err != nil {
+				return err
+			}
+			if s.fromModel, err = wave.NewResampler(modelRate, inputRate, wave.ResampleHigh); err != nil {
+				return err
+			}
+		}
+	}
+
+	path := f.config.OutputPath
+	if f.outFile, err = os.Create(path); err != nil {
+		return fmt.Errorf("failed to create audio file %q: %w", path, err)
+	}
+	format := outputFormat(inputFormat)
+	if f.quantizer, err = newQuantizer(format, f.config); err != nil {
+		return err
+	}
+	if f.enc, err = audiofile.NewEncoder(f.outFile, audiofile.TypeFromExtension(path), format, f.chunks); err != nil {
+		return fmt.Errorf("failed to write audio file %q: %w", path, err)
+	}
+	return nil
+}
+
+// fill reads the input, until each stream holds a chunk of frames at the
+// model sample rate, or the input ends.
+func (f *batchFile) fill() error {
+	for !f.eof && len(f.streams[0].pending) < rtChunkSize {
+		n, err := f.dec.ReadFrames(f.input)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				if err = checkTruncation(err, f.config); err != nil {
+					return fmt.Errorf("failed to read audio file %q: %w", f.config.InputPath, err)
+				}
+			}
+			f.eof = true
+			for _, s := range f.streams {
+				if s.toModel != nil {
+					s.pending = s.toModel.Flush(s.pending)
+				}
+			}
+			break
+		}
+		f.numInputFrames += n
+
+		for i, s := range f.streams {
+			f.views[i] = s.input[:n]
+		}
+		f.config.Channel.Split(f.views, f.input[:n*f.channels], f.channels)
+		for i, s := range f.streams {
+			if s.toModel == nil {
+				s.pending = append(s.pending, f.views[i]...)
+			} else {
+				s.pending = s.toModel.Process(s.pending, f.views[i])
+			}
+		}
+	}
+	return nil
+}
+
+// consume appends the output of the pending frames, discarding them.
+func (s *batchStream) consume(output []float32) {
+	if s.fromModel == nil {
+		s.output = append(s.output, output...)
+	} else {
+		s.output = s.fromModel.Process(s.output, output)
+	}
+	s.pending = s.pending[:copy(s.pending, s.pending[len(output):])]
+}
+
+// writeOutput writes the pending output. It is limited to the input
+// length, since resampling back and forth can produce an extra sample at
+// the end.
+func (f *batchFile) writeOutput() error {
+	n := min(len(f.streams[0].output), f.numInputFrames-f.numOutputFrames)
+	for i, s := range f.streams {
+		f.views[i] = s.output[:n]
+	}
+	if cap(f.output) < n*f.channels {
+		f.output = make([]float32, n*f.channels)
+	}
+	output := f.output[:n*f.channels]
+	f.config.Channel.Merge(output, f.views, f.channels)
+	f.quantizer.Quantize(output, output)
+	for _, s := range f.streams {
+		s.output = s.output[:copy(s.output, s.output[n:])]
+	}
+	f.numOutputFrames += n
+	if err := f.enc.WriteFrames(output); err != nil {
+		return fmt.Errorf("failed to write audio file %q: %w", f.config.OutputPath, err)
+	}
+	return nil
+}
+
+// finish writes the remaining output, once all the input is processed.
+func (f *batchFile) finish() error {
+	for _, s := range f.streams {
+		if s.fromModel != nil {
+			s.output = s.fromModel.Flush(s.output)
+		}
+	}
+	if err := f.writeOutput(); err != nil {
+		return err
+	}
+	f.done = true
+	if err := f.enc.Close(); err != nil {
+		return fmt.Errorf("failed to write audio file %q: %w", f.config.OutputPath, err)
+	}
+	reportClipping(f.quantizer, f.config)
+	return nil
+}
+
+func (f *batchFile) close() error {
+	var err error
+	if f.outFile != nil {
+		if e := f.outFile.Close(); e != nil {
+			err = fmt.Errorf("failed to close audio file %q: %w", f.config.OutputPath, e)
+		}
+	}
+	if e := f.inFile.Close(); e != nil && err == nil {
+		err = fmt.Errorf("failed to close audio file %q: %w", f.config.InputPath, e)
+	}
+	return err
+}
@@ -14,7 +14,72 @@
 
 package processing
 
+import (
+	"errors"
+	"fmt"
+	"github.com/nlpodyssey/waveny/wave"
+	"os"
+)
+
 type Config struct {
 	InputPath  string
 	OutputPath string
+	// Channel specifies how a multi-channel input is processed.
+	// The output always keeps the input channel layout.
+	Channel wave.ChannelSelection
+	// Lenient allows processing inputs whose audio data is shorter than
+	// declared, such as files left by crashed recorders: the available
+	// samples are processed, and a warning is reported.
+	Lenient bool
+	// Quantization configures how the processed samples are reduced to
+	// the output bit depth.
+	Quantization wave.Quantization
+}
+
+// modelSampleRate is the sample rate SpaGO models operate at. Inputs with a
+// different rate are converted to it, and outputs are converted back.
+const modelSampleRate = 48_000
+
+func checkInputFormat(format wave.Format, channel wave.ChannelSelection) error {
+	if format.SampleRate == 0 {
+		return fmt.Errorf("invalid sample rate 0")
+	}
+	return channel.Validate(int(format.Channels))
+}
+
+// checkTruncation reports a truncated input as a warning, in lenient mode,
+// returning nil. Any other error is returned unchanged.
+func checkTruncation(err error, config Config) error {
+	var truncated *wave.TruncatedDataError
+	if config.Lenient && errors.As(err, &truncated) {
+		_, _ = fmt.Fprintf(os.Stderr, "WARNING: %q: %v\n", config.InputPath, err)
+		return nil
+	}
+	return err
+}
+
+// newQuantizer returns a Quantizer reducing the processed samples to the
+// resolution of the given output format. A noise shaping filter not fit for
+// the output sample rate is reported as a warning.
+func newQuantizer(format wave.Format, config Config) (*wave.Quantizer, error) {
+	if shaping := config.Quantization.NoiseShaping; !shaping.SuitsSampleRate(int(format.SampleRate)) {
+		_, _ = fmt.Fprintf(os.Stderr, "WARNING: %q: %s noise shaping is designed for 44.1kHz, not %dHz: the noise may be moved into the audible band\n",
+			config.OutputPath, shaping.String(), format.SampleRate)
+	}
+	return wave.NewQuantizer(int(format.Channels), int(format.BitsPerSample), config.Quantization)
+}
+
+// reportClipping reports clipped output samples as a warning.
+func reportClipping(q *wave.Quantizer, config Config) {
+	if n := q.Clipped(); n > 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "WARNING: %q: %d output samples clipped\n", config.OutputPath, n)
+	}
+}
+
+// outputFormat returns the format of the processed output: PCM 24-bit,
+// with the same sample rate and channel layout of the input.
+func outputFormat(input wave.Format) wave.Format {
+	format := wave.NewPCMFormat(int(input.Channels), int(input.SampleRate), 24)
+	format.ChannelMask = input.ChannelMask
+	return format
 }
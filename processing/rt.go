@@ -15,42 +15,266 @@
 package processing
 
 import (
-	"github.com/nlpodyssey/waveny/models/realtime/wavenet"
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/nlpodyssey/waveny/audiofile"
+	"github.com/nlpodyssey/waveny/models/realtime"
+	_ "github.com/nlpodyssey/waveny/models/realtime/convnet"
+	_ "github.com/nlpodyssey/waveny/models/realtime/linear"
+	_ "github.com/nlpodyssey/waveny/models/realtime/lstm"
+	_ "github.com/nlpodyssey/waveny/models/realtime/wavenet"
 	"github.com/nlpodyssey/waveny/wave"
+	"io"
+	"os"
 )
 
 type RTConfig struct {
 	ModelDataPath string
+	// Precision is the size in bits of the floating-point values the model
+	// computes with: see realtime.WithPrecision. Only WaveNet models support
+	// 64-bit precision, which is slower, but closer to reference
+	// implementations.
+	Precision int
+	// Workers is the amount of goroutines processing each step of the
+	// model: see realtime.WithWorkers.
+	Workers int
 }
 
-func ProcessWithRTModel(config Config, rtConfig RTConfig) error {
-	model, err := wavenet.LoadFromJSONModelDataFile(rtConfig.ModelDataPath)
+// rtChunkSize is the amount of frames read, processed and written at once.
+const rtChunkSize = 4096
+
+// ProcessWithRTModel processes the input file with the real-time model,
+// streaming chunks of frames from the input to the output file, so that
+// neither of them needs to be held in memory. Metadata chunks are carried
+// over to the output file.
+//
+// When all channels are processed independently, each one is run through
+// a separate model instance.
+func ProcessWithRTModel(config Config, rtConfig RTConfig) (err error) {
+	inFile, err := os.Open(config.InputPath)
+	if err != nil {
+		return fmt.Errorf("failed to open audio file %q: %w", config.InputPath, err)
+	}
+	defer func() {
+		if e := inFile.Close(); e != nil && err == nil {
+			err = fmt.Errorf("failed to close audio file %q: %w", config.InputPath, e)
+		}
+	}()
+
+	// Metadata chunks may also follow the wave data, so they are read
+	// upfront, before streaming.
+	chunks, err := audiofile.ReadChunks(inFile)
+	if err != nil {
+		return fmt.Errorf("failed to read audio file %q: %w", config.InputPath, err)
+	}
+	if _, err = inFile.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek audio file %q: %w", config.InputPath, err)
+	}
+
+	dec, err := audiofile.NewDecoder(bufio.NewReader(inFile))
 	if err != nil {
+		return fmt.Errorf("failed to read audio file %q: %w", config.InputPath, err)
+	}
+	inputFormat := dec.Format()
+	if err = checkInputFormat(inputFormat, config.Channel); err != nil {
 		return err
 	}
 
-	input, err := wave.WavToFloats(config.InputPath)
+	models, err := loadRTModels(rtConfig, config.Channel.NumStreams(int(inputFormat.Channels)))
 	if err != nil {
 		return err
 	}
+	defer closeRTModels(models)
 
-	output := make([]float32, len(input))
+	outFile, err := os.Create(config.OutputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create audio file %q: %w", config.OutputPath, err)
+	}
+	defer func() {
+		if e := outFile.Close(); e != nil && err == nil {
+			err = fmt.Errorf("failed to close audio file %q: %w", config.OutputPath, e)
+		}
+	}()
 
-	const chunkSize = 4096
-	numChunks := len(input) / chunkSize
+	format := outputFormat(inputFormat)
+	quantizer, err := newQuantizer(format, config)
+	if err != nil {
+		return err
+	}
+	outputType := audiofile.TypeFromExtension(config.OutputPath)
+	enc, err := audiofile.NewEncoder(outFile, outputType, format, chunks)
+	if err != nil {
+		return fmt.Errorf("failed to write audio file %q: %w", config.OutputPath, err)
+	}
+	if err = processStream(models, config, quantizer, dec, enc); err != nil {
+		return err
+	}
+	if err = enc.Close(); err != nil {
+		return fmt.Errorf("failed to write audio file %q: %w", config.OutputPath, err)
+	}
+	reportClipping(quantizer, config)
+	return nil
+}
 
-	for i := 0; i < numChunks; i++ {
-		from := i * chunkSize
-		to := from + chunkSize
+func loadRTModels(rtConfig RTConfig, n int) ([]realtime.Model, error) {
+	modelData, err := realtime.ReadModelDataJSONFile(rtConfig.ModelDataPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JSON model data from file %q: %w", rtConfig.ModelDataPath, err)
+	}
+	models := make([]realtime.Model, 0, n)
+	for i := 0; i < n; i++ {
+		model, err := realtime.New(modelData, realtime.WithPrecision(rtConfig.Precision), realtime.WithWorkers(rtConfig.Workers))
+		if err != nil {
+			closeRTModels(models)
+			return nil, err
+		}
+		model.SetMaxBlockSize(rtChunkSize)
+		models = append(models, model)
+	}
+	return models, nil
+}
 
-		model.Process(input[from:to], output[from:to])
-		model.Finalize(chunkSize)
+func closeRTModels(models []realtime.Model) {
+	for _, model := range models {
+		model.Close()
 	}
+}
+
+func processStream(models []realtime.Model, config Config, quantizer *wave.Quantizer, dec audiofile.Decoder, enc audiofile.Encoder) error {
+	channel := config.Channel
+	channels := int(dec.Format().Channels)
+	inputRate := int(dec.Format().SampleRate)
 
-	if from := chunkSize * numChunks; from < len(input) {
-		model.Process(input[from:], output[from:])
-		model.Finalize(len(input) - from)
+	streams := make([]*rtStream, len(models))
+	for i, model := range models {
+		var err error
+		if streams[i], err = newRTStream(model, inputRate); err != nil {
+			return err
+		}
 	}
 
-	return wave.FloatsToWav(output, config.OutputPath)
+	input := make([]float32, rtChunkSize*channels)
+	var output []float32
+	inputViews := make([][]float32, len(streams))
+	outputViews := make([][]float32, len(streams))
+
+	// Streams are all alike, so their pending output has the same length.
+	// It is limited to the input length, since resampling back and forth
+	// can produce an extra sample at the end.
+	var numInputFrames, numOutputFrames int
+	writeOutput := func() error {
+		n := min(len(streams[0].output), numInputFrames-numOutputFrames)
+		for i, stream := range streams {
+			outputViews[i] = stream.output[:n]
+			stream.output = stream.output[:0]
+		}
+		if cap(output) < n*channels {
+			output = make([]float32, n*channels)
+		}
+		channel.Merge(output[:n*channels], outputViews, channels)
+		quantizer.Quantize(output[:n*channels], output[:n*channels])
+		numOutputFrames += n
+		return enc.WriteFrames(output[:n*channels])
+	}
+
+	for {
+		n, err := dec.ReadFrames(input)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if err = checkTruncation(err, config); err != nil {
+				return err
+			}
+			break
+		}
+		numInputFrames += n
+
+		for i, stream := range streams {
+			inputViews[i] = stream.input[:n]
+		}
+		channel.Split(inputViews, input[:n*channels], channels)
+		for i, stream := range streams {
+			stream.process(inputViews[i])
+		}
+		if err = writeOutput(); err != nil {
+			return err
+		}
+	}
+
+	for _, stream := range streams {
+		stream.flush()
+	}
+	return writeOutput()
+}
+
+// rtStream runs a mono stream through a real-time model, converting the
+// sample rate to and from the model's one, if needed.
+type rtStream struct {
+	model       realtime.Model
+	toModel     *wave.Resampler // nil if no conversion is needed
+	fromModel   *wave.Resampler // nil if no conversion is needed
+	input       []float32       // input buffer, for convenience
+	modelInput  []float32
+	modelOutput []float32
+	output      []float32 // pending output, at the input sample rate
+}
+
+func newRTStream(model realtime.Model, inputRate int) (*rtStream, error) {
+	s := &rtStream{
+		model: model,
+		input: make([]float32, rtChunkSize),
+	}
+	modelRate := model.SampleRate()
+	if inputRate == modelRate {
+		return s, nil
+	}
+	var err error
+	if s.toModel, err = wave.NewResampler(inputRate, modelRate, wave.ResampleHigh); err != nil {
+		return nil, err
+	}
+	if s.fromModel, err = wave.NewResampler(modelRate, inputRate, wave.ResampleHigh); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *rtStream) process(input []float32) {
+	if s.toModel == nil {
+		s.run(input)
+		return
+	}
+	s.modelInput = s.toModel.Process(s.modelInput[:0], input)
+	s.run(s.modelInput)
+}
+
+func (s *rtStream) flush() {
+	if s.toModel == nil {
+		return
+	}
+	s.modelInput = s.toModel.Flush(s.modelInput[:0])
+	s.run(s.modelInput)
+	s.output = s.fromModel.Flush(s.output)
+}
+
+// run processes the input with the model, in chunks, appending the
+// result to the pending output.
+func (s *rtStream) run(input []float32) {
+	for from := 0; from < len(input); from += rtChunkSize {
+		chunk := input[from:min(from+rtChunkSize, len(input))]
+		if cap(s.modelOutput) < len(chunk) {
+			s.modelOutput = make([]float32, rtChunkSize)
+		}
+		output := s.modelOutput[:len(chunk)]
+
+		s.model.Process(chunk, output)
+		s.model.Finalize(len(chunk))
+
+		if s.fromModel == nil {
+			s.output = append(s.output, output...)
+		} else {
+			s.output = s.fromModel.Process(s.output, output)
+		}
+	}
 }
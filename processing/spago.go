@@ -16,7 +16,9 @@ package processing
 
 import (
 	"fmt"
+	"github.com/nlpodyssey/spago/mat"
 	"github.com/nlpodyssey/spago/nn"
+	"github.com/nlpodyssey/waveny/audiofile"
 	"github.com/nlpodyssey/waveny/models/spago/wavenet"
 	"github.com/nlpodyssey/waveny/wave"
 )
@@ -31,12 +33,49 @@ func ProcessWithSpagoModel(config Config, spagoConfig SpagoConfig) error {
 	if err != nil {
 		return fmt.Errorf("failed to load SpaGO model %q: %w", spagoConfig.ModelPath, err)
 	}
+	return processWithSpagoModel(model, config)
+}
 
-	input, err := wave.WavToSpagoTensor(config.InputPath)
-	if err != nil {
+// processWithSpagoModel processes the input file with a SpaGO WaveNet model.
+// Every selected channel stream is processed independently. Metadata chunks
+// are carried over to the output file.
+func processWithSpagoModel(model *wavenet.Model, config Config) error {
+	input, format, chunks, err := audiofile.FileToFloats(config.InputPath)
+	if err = checkTruncation(err, config); err != nil {
+		return err
+	}
+	if err = checkInputFormat(format, config.Channel); err != nil {
 		return err
 	}
 
-	output := model.Forward(input, true)
-	return wave.SpagoTensorToWav(output, config.OutputPath)
+	channels := int(format.Channels)
+	inputRate := int(format.SampleRate)
+	streams := config.Channel.SplitAll(input, channels)
+	for i, stream := range streams {
+		numFrames := len(stream)
+		stream, err = wave.Resample(stream, inputRate, modelSampleRate, wave.ResampleHigh)
+		if err != nil {
+			return err
+		}
+		x := mat.NewDense[float32](mat.WithBacking(stream))
+		y := model.Forward(x, true).Data().F32()
+		y, err = wave.Resample(y, modelSampleRate, inputRate, wave.ResampleHigh)
+		if err != nil {
+			return err
+		}
+		streams[i] = y[:numFrames]
+	}
+
+	output := config.Channel.MergeAll(streams, channels)
+	outFormat := outputFormat(format)
+	quantizer, err := newQuantizer(outFormat, config)
+	if err != nil {
+		return err
+	}
+	quantizer.Quantize(output, output)
+	if err = audiofile.FloatsToFile(output, outFormat, chunks, config.OutputPath); err != nil {
+		return err
+	}
+	reportClipping(quantizer, config)
+	return nil
 }
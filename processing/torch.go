@@ -18,7 +18,6 @@ import (
 	"fmt"
 	"github.com/nlpodyssey/waveny/models/spago/wavenet"
 	"github.com/nlpodyssey/waveny/models/spago/wavenet/torchconv"
-	"github.com/nlpodyssey/waveny/wave"
 )
 
 type TorchConfig struct {
@@ -38,11 +37,5 @@ func ProcessWithTorchModel(config Config, torchConfig TorchConfig) error {
 		return fmt.Errorf("failed to load-and-convert torch model from file %q: %w", torchConfig.ModelPath, err)
 	}
 
-	input, err := wave.WavToSpagoTensor(config.InputPath)
-	if err != nil {
-		return err
-	}
-
-	output := model.Forward(input, true)
-	return wave.SpagoTensorToWav(output, config.OutputPath)
+	return processWithSpagoModel(model, config)
 }
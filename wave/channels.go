@@ -0,0 +1,201 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wave
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Deinterleave splits interleaved samples into separate channels.
+func Deinterleave(data []float32, channels int) [][]float32 {
+	dst := make([][]float32, channels)
+	for i := range dst {
+		dst[i] = make([]float32, len(data)/channels)
+	}
+	DeinterleaveInto(dst, data)
+	return dst
+}
+
+// DeinterleaveInto splits interleaved samples into the given channels.
+// The number of frames is determined by the length of the channels.
+func DeinterleaveInto(dst [][]float32, src []float32) {
+	channels := len(dst)
+	for c, channel := range dst {
+		for i := range channel {
+			channel[i] = src[i*channels+c]
+		}
+	}
+}
+
+// Interleave merges separate channels into interleaved samples.
+func Interleave(channels [][]float32) []float32 {
+	if len(channels) == 0 {
+		return nil
+	}
+	dst := make([]float32, len(channels)*len(channels[0]))
+	InterleaveInto(dst, channels)
+	return dst
+}
+
+// InterleaveInto merges the given channels into interleaved samples.
+// The number of frames is determined by the length of the channels.
+func InterleaveInto(dst []float32, src [][]float32) {
+	channels := len(src)
+	for c, channel := range src {
+		for i, v := range channel {
+			dst[i*channels+c] = v
+		}
+	}
+}
+
+// DownmixInto averages the interleaved channels of src into the mono dst.
+// The number of frames is determined by the length of dst.
+func DownmixInto(dst []float32, src []float32, channels int) {
+	scale := 1 / float32(channels)
+	for i := range dst {
+		frame := src[i*channels : i*channels+channels]
+		v := float32(0)
+		for _, s := range frame {
+			v += s
+		}
+		dst[i] = v * scale
+	}
+}
+
+// ChannelMode is the strategy of a ChannelSelection.
+type ChannelMode int
+
+const (
+	// SingleChannel selects only one channel, by index.
+	SingleChannel ChannelMode = iota
+	// DownmixChannels averages all channels into a mono signal.
+	DownmixChannels
+	// AllChannels selects every channel, to be processed independently.
+	AllChannels
+)
+
+// A ChannelSelection specifies how the channels of a multi-channel signal
+// are turned into the mono streams processed by a model, and how the
+// processed streams are turned back into the original channel layout.
+//
+// The zero value selects the first channel.
+//
+// ChannelSelection implements flag.Value, parsing a zero-based channel
+// index, "mix" or "all".
+type ChannelSelection struct {
+	Mode  ChannelMode
+	Index int
+}
+
+func (s *ChannelSelection) String() string {
+	switch s.Mode {
+	case DownmixChannels:
+		return "mix"
+	case AllChannels:
+		return "all"
+	default:
+		return strconv.Itoa(s.Index)
+	}
+}
+
+func (s *ChannelSelection) Set(value string) error {
+	switch value {
+	case "mix":
+		*s = ChannelSelection{Mode: DownmixChannels}
+	case "all":
+		*s = ChannelSelection{Mode: AllChannels}
+	default:
+		index, err := strconv.Atoi(value)
+		if err != nil || index < 0 {
+			return fmt.Errorf("invalid channel selection %q: expected channel index, \"mix\" or \"all\"", value)
+		}
+		*s = ChannelSelection{Mode: SingleChannel, Index: index}
+	}
+	return nil
+}
+
+// Validate checks whether the selection is applicable to a signal with
+// the given number of channels.
+func (s ChannelSelection) Validate(channels int) error {
+	if s.Mode == SingleChannel && s.Index >= channels {
+		return fmt.Errorf("invalid channel index %d for %d channel(s)", s.Index, channels)
+	}
+	return nil
+}
+
+// NumStreams returns the amount of mono streams produced from a signal
+// with the given number of channels.
+func (s ChannelSelection) NumStreams(channels int) int {
+	if s.Mode == AllChannels {
+		return channels
+	}
+	return 1
+}
+
+// Split turns interleaved samples into mono streams. The length of dst must
+// be NumStreams, and the number of frames is determined by the length of
+// the streams.
+func (s ChannelSelection) Split(dst [][]float32, src []float32, channels int) {
+	switch s.Mode {
+	case AllChannels:
+		DeinterleaveInto(dst, src)
+	case DownmixChannels:
+		DownmixInto(dst[0], src, channels)
+	default:
+		stream := dst[0]
+		for i := range stream {
+			stream[i] = src[i*channels+s.Index]
+		}
+	}
+}
+
+// Merge turns processed mono streams back into interleaved samples with
+// the given number of channels. When a single stream was selected, it is
+// copied to every channel.
+func (s ChannelSelection) Merge(dst []float32, src [][]float32, channels int) {
+	if s.Mode == AllChannels {
+		InterleaveInto(dst, src)
+		return
+	}
+	for i, v := range src[0] {
+		frame := dst[i*channels : i*channels+channels]
+		for c := range frame {
+			frame[c] = v
+		}
+	}
+}
+
+// SplitAll is a convenience function which allocates and returns the mono
+// streams produced by Split.
+func (s ChannelSelection) SplitAll(data []float32, channels int) [][]float32 {
+	dst := make([][]float32, s.NumStreams(channels))
+	for i := range dst {
+		dst[i] = make([]float32, len(data)/channels)
+	}
+	s.Split(dst, data, channels)
+	return dst
+}
+
+// MergeAll is a convenience function which allocates and returns the
+// interleaved samples produced by Merge.
+func (s ChannelSelection) MergeAll(streams [][]float32, channels int) []float32 {
+	if len(streams) == 0 {
+		return nil
+	}
+	dst := make([]float32, len(streams[0])*channels)
+	s.Merge(dst, streams, channels)
+	return dst
+}
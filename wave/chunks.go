@@ -0,0 +1,308 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wave
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// A Chunk is a RIFF chunk, other than the format and data ones, whose
+// content is kept verbatim.
+type Chunk struct {
+	ID   [4]byte
+	Data []byte
+}
+
+// Chunks is a list of metadata chunks, in file order.
+type Chunks []Chunk
+
+// IDs of the metadata chunks with typed accessors.
+var (
+	ListChunkID = [4]byte{'L', 'I', 'S', 'T'}
+	BextChunkID = [4]byte{'b', 'e', 'x', 't'}
+	CueChunkID  = [4]byte{'c', 'u', 'e', ' '}
+)
+
+// infoListType is the list type of a LIST/INFO chunk, stored in the first
+// four bytes of the LIST chunk data.
+var infoListType = [4]byte{'I', 'N', 'F', 'O'}
+
+// isMetadataChunkID reports whether a chunk is preserved when reading.
+// Structural chunks, whose content would not be valid anymore once the
+// wave data is modified, are excluded.
+func isMetadataChunkID(id [4]byte) bool {
+	switch string(id[:]) {
+	case "fmt ", "data", "ds64", "fact", "JUNK", "junk", "PAD ", "FLLR":
+		return false
+	default:
+		return true
+	}
+}
+
+// Get returns the first chunk with the given ID.
+func (c Chunks) Get(id [4]byte) (Chunk, bool) {
+	i := slices.IndexFunc(c, func(ch Chunk) bool { return ch.ID == id })
+	if i < 0 {
+		return Chunk{}, false
+	}
+	return c[i], true
+}
+
+// Set replaces the first chunk with the same ID, or appends it.
+func (c *Chunks) Set(chunk Chunk) {
+	c.set(chunk, func(ch Chunk) bool { return ch.ID == chunk.ID })
+}
+
+func (c *Chunks) set(chunk Chunk, match func(Chunk) bool) {
+	if i := slices.IndexFunc(*c, match); i >= 0 {
+		(*c)[i] = chunk
+		return
+	}
+	*c = append(*c, chunk)
+}
+
+func isInfoChunk(ch Chunk) bool {
+	return ch.ID == ListChunkID && len(ch.Data) >= 4 && [4]byte(ch.Data[:4]) == infoListType
+}
+
+// Info returns the tags of the LIST/INFO chunk, keyed by four-character ID,
+// such as "INAM" (title), "IART" (artist) or "ICMT" (comment).
+// It returns nil if there is no such chunk.
+func (c Chunks) Info() (map[string]string, error) {
+	i := slices.IndexFunc(c, isInfoChunk)
+	if i < 0 {
+		return nil, nil
+	}
+	data := c[i].Data[4:]
+	tags := make(map[string]string)
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, errors.New("truncated INFO sub-chunk header")
+		}
+		id := string(data[:4])
+		size := int(binary.LittleEndian.Uint32(data[4:8]))
+		data = data[8:]
+		if size > len(data) {
+			return nil, fmt.Errorf("truncated INFO sub-chunk %q", id)
+		}
+		tags[id] = cString(data[:size])
+		data = data[min(size+size%2, len(data)):] // skip the pad byte
+	}
+	return tags, nil
+}
+
+// SetInfo replaces the LIST/INFO chunk with the given tags, or appends it.
+// Tag IDs must be four characters long.
+func (c *Chunks) SetInfo(tags map[string]string) error {
+	ids := make([]string, 0, len(tags))
+	for id := range tags {
+		if len(id) != 4 {
+			return fmt.Errorf("invalid INFO tag ID %q: four characters expected", id)
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var b bytes.Buffer
+	b.Write(infoListType[:])
+	for _, id := range ids {
+		value := tags[id]
+		size := len(value) + 1 // NUL-terminated
+		b.WriteString(id)
+		_ = binary.Write(&b, binary.LittleEndian, uint32(size))
+		b.WriteString(value)
+		b.WriteByte(0)
+		if size%2 != 0 {
+			b.WriteByte(0)
+		}
+	}
+	c.set(Chunk{ID: ListChunkID, Data: b.Bytes()}, isInfoChunk)
+	return nil
+}
+
+// BroadcastExtension is the content of a "bext" chunk, as defined by the
+// Broadcast Wave Format (EBU Tech 3285).
+type BroadcastExtension struct {
+	Description         string
+	Originator          string
+	OriginatorReference string
+	// OriginationDate is formatted as "yyyy-mm-dd".
+	OriginationDate string
+	// OriginationTime is formatted as "hh:mm:ss".
+	OriginationTime string
+	// TimeReference is the timecode of the first sample, as sample count
+	// since midnight.
+	TimeReference uint64
+	Version       uint16
+	UMID          [64]byte
+	// Loudness values (version 2), in hundredths.
+	LoudnessValue        int16
+	LoudnessRange        int16
+	MaxTruePeakLevel     int16
+	MaxMomentaryLoudness int16
+	MaxShortTermLoudness int16
+	CodingHistory        string
+}
+
+const bextFixedSize = 256 + // description
+	32 + // originator
+	32 + // originator reference
+	10 + // origination date
+	8 + // origination time
+	8 + // time reference
+	2 + // version
+	64 + // UMID
+	5*2 + // loudness values
+	180 // reserved
+
+// BroadcastExtension returns the content of the "bext" chunk.
+// It returns nil if there is no such chunk.
+func (c Chunks) BroadcastExtension() (*BroadcastExtension, error) {
+	ch, ok := c.Get(BextChunkID)
+	if !ok {
+		return nil, nil
+	}
+	data := ch.Data
+	if len(data) < bextFixedSize {
+		return nil, fmt.Errorf("bext chunk is too short: expected at least %d bytes, actual %d", bextFixedSize, len(data))
+	}
+	next := func(n int) []byte {
+		b := data[:n]
+		data = data[n:]
+		return b
+	}
+	nextInt16 := func() int16 {
+		return int16(binary.LittleEndian.Uint16(next(2)))
+	}
+	bext := &BroadcastExtension{
+		Description:         cString(next(256)),
+		Originator:          cString(next(32)),
+		OriginatorReference: cString(next(32)),
+		OriginationDate:     cString(next(10)),
+		OriginationTime:     cString(next(8)),
+		TimeReference:       binary.LittleEndian.Uint64(next(8)),
+		Version:             binary.LittleEndian.Uint16(next(2)),
+		UMID:                [64]byte(next(64)),
+	}
+	bext.LoudnessValue = nextInt16()
+	bext.LoudnessRange = nextInt16()
+	bext.MaxTruePeakLevel = nextInt16()
+	bext.MaxMomentaryLoudness = nextInt16()
+	bext.MaxShortTermLoudness = nextInt16()
+	next(180) // reserved
+	bext.CodingHistory = cString(data)
+	return bext, nil
+}
+
+// SetBroadcastExtension replaces the "bext" chunk, or appends it.
+// Strings exceeding their fixed-size fields are truncated.
+func (c *Chunks) SetBroadcastExtension(bext *BroadcastExtension) {
+	data := make([]byte, bextFixedSize, bextFixedSize+len(bext.CodingHistory))
+	b := data
+	next := func(n int) []byte {
+		v := b[:n]
+		b = b[n:]
+		return v
+	}
+	copy(next(256), bext.Description)
+	copy(next(32), bext.Originator)
+	copy(next(32), bext.OriginatorReference)
+	copy(next(10), bext.OriginationDate)
+	copy(next(8), bext.OriginationTime)
+	binary.LittleEndian.PutUint64(next(8), bext.TimeReference)
+	binary.LittleEndian.PutUint16(next(2), bext.Version)
+	copy(next(64), bext.UMID[:])
+	for _, v := range []int16{bext.LoudnessValue, bext.LoudnessRange, bext.MaxTruePeakLevel, bext.MaxMomentaryLoudness, bext.MaxShortTermLoudness} {
+		binary.LittleEndian.PutUint16(next(2), uint16(v))
+	}
+	data = append(data, bext.CodingHistory...)
+	c.Set(Chunk{ID: BextChunkID, Data: data})
+}
+
+// A CuePoint is a marker of the "cue " chunk.
+type CuePoint struct {
+	ID uint32
+	// Position is the sample frame of the cue point, for the play order.
+	Position uint32
+	// DataChunkID is the ID of the chunk containing the cue point, usually
+	// "data".
+	DataChunkID  [4]byte
+	ChunkStart   uint32
+	BlockStart   uint32
+	SampleOffset uint32
+}
+
+const cuePointSize = 6 * 4
+
+// CuePoints returns the cue points of the "cue " chunk.
+// It returns nil if there is no such chunk.
+func (c Chunks) CuePoints() ([]CuePoint, error) {
+	ch, ok := c.Get(CueChunkID)
+	if !ok {
+		return nil, nil
+	}
+	data := ch.Data
+	if len(data) < 4 {
+		return nil, fmt.Errorf("cue chunk is too short: %d bytes", len(data))
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+	if uint64(count)*cuePointSize > uint64(len(data)) {
+		return nil, fmt.Errorf("cue chunk is too short for %d cue points: %d bytes", count, len(data))
+	}
+	points := make([]CuePoint, count)
+	for i := range points {
+		b := data[i*cuePointSize:]
+		points[i] = CuePoint{
+			ID:           binary.LittleEndian.Uint32(b),
+			Position:     binary.LittleEndian.Uint32(b[4:]),
+			DataChunkID:  [4]byte(b[8:12]),
+			ChunkStart:   binary.LittleEndian.Uint32(b[12:]),
+			BlockStart:   binary.LittleEndian.Uint32(b[16:]),
+			SampleOffset: binary.LittleEndian.Uint32(b[20:]),
+		}
+	}
+	return points, nil
+}
+
+// SetCuePoints replaces the "cue " chunk with the given cue points, or
+// appends it.
+func (c *Chunks) SetCuePoints(points []CuePoint) {
+	data := make([]byte, 4+len(points)*cuePointSize)
+	binary.LittleEndian.PutUint32(data, uint32(len(points)))
+	for i, p := range points {
+		b := data[4+i*cuePointSize:]
+		binary.LittleEndian.PutUint32(b, p.ID)
+		binary.LittleEndian.PutUint32(b[4:], p.Position)
+		copy(b[8:12], p.DataChunkID[:])
+		binary.LittleEndian.PutUint32(b[12:], p.ChunkStart)
+		binary.LittleEndian.PutUint32(b[16:], p.BlockStart)
+		binary.LittleEndian.PutUint32(b[20:], p.SampleOffset)
+	}
+	c.Set(Chunk{ID: CueChunkID, Data: data})
+}
+
+// cString returns the string up to the first NUL byte, if any.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
@@ -0,0 +1,131 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wave
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A Decoder reads normalized floating-point samples from a WAVE stream,
+// block by block, without loading the whole wave data in memory.
+type Decoder struct {
+	r          io.Reader
+	format     Format
+	chunks     Chunks
+	decode     func([]byte) float32
+	sampleSize int
+	dataSize   int64
+	remaining  int64
+	buf        []byte
+	err        error // sticky *TruncatedDataError
+}
+
+// NewDecoder reads the WAVE header from r, up to the beginning of the wave
+// data, and returns a new Decoder ready to read the samples.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	lr, h, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	format := h.format
+	if format.Channels == 0 {
+		return nil, errors.New("invalid format: zero channels")
+	}
+	decode, err := sampleDecoder(format)
+	if err != nil {
+		return nil, err
+	}
+	return &Decoder{
+		r:          lr,
+		format:     format,
+		chunks:     h.chunks,
+		decode:     decode,
+		sampleSize: int(format.BitsPerSample) / 8,
+		dataSize:   int64(h.dataSize),
+		remaining:  int64(h.dataSize),
+	}, nil
+}
+
+// Format returns the format of the wave data.
+func (d *Decoder) Format() Format {
+	return d.format
+}
+
+// Chunks returns the metadata chunks preceding the wave data. Use ReadChunks
+// to also get the ones following it.
+func (d *Decoder) Chunks() Chunks {
+	return d.chunks
+}
+
+// NumFrames returns the total amount of frames declared by the data chunk.
+func (d *Decoder) NumFrames() int64 {
+	return d.dataSize / int64(d.frameSize())
+}
+
+func (d *Decoder) frameSize() int {
+	return d.sampleSize * int(d.format.Channels)
+}
+
+// ReadFrames reads up to len(dst)/channels frames, storing their interleaved
+// samples into dst, and returns the number of frames read.
+// At the end of the wave data, it returns 0 and io.EOF.
+//
+// If the wave data is shorter than declared, the available whole frames are
+// returned first, then 0 and a *TruncatedDataError.
+func (d *Decoder) ReadFrames(dst []float32) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	channels := int(d.format.Channels)
+	if len(dst) < channels {
+		return 0, fmt.Errorf("destination length %d is too short for a frame of %d channels", len(dst), channels)
+	}
+	frameSize := int64(d.frameSize())
+	size := min(int64(len(dst)/channels)*frameSize, d.remaining/frameSize*frameSize)
+	if size == 0 {
+		return 0, io.EOF
+	}
+
+	if int64(cap(d.buf)) < size {
+		d.buf = make([]byte, size)
+	}
+	buf := d.buf[:size]
+	n, err := io.ReadFull(d.r, buf)
+	switch {
+	case err == nil:
+		d.remaining -= size
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		d.err = &TruncatedDataError{
+			DeclaredSize: uint64(d.dataSize),
+			ActualSize:   uint64(d.dataSize - d.remaining + int64(n)),
+		}
+		d.remaining = 0
+		size = int64(n) / frameSize * frameSize
+		if size == 0 {
+			return 0, d.err
+		}
+	default:
+		return 0, fmt.Errorf("failed to read wave data: %w", err)
+	}
+
+	sampleSize := d.sampleSize
+	for i := range dst[:int(size)/sampleSize] {
+		j := i * sampleSize
+		dst[i] = d.decode(buf[j : j+sampleSize])
+	}
+	return int(size / frameSize), nil
+}
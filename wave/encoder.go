@@ -0,0 +1,133 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wave
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// An Encoder writes normalized floating-point samples to a WAVE stream,
+// block by block, without holding the whole wave data in memory.
+//
+// The header is written upfront with placeholder sizes, and rewritten when
+// the Encoder is closed. Room is reserved for switching to RF64 form, so
+// that the amount of data is not limited to 4 GiB.
+type Encoder struct {
+	w          io.WriteSeeker
+	bw         *bufio.Writer
+	format     Format
+	chunks     Chunks
+	encode     func([]byte, float32)
+	sampleSize int
+	start      int64
+	dataSize   int64
+	buf        []byte
+}
+
+// NewEncoder writes the WAVE header to w, at its current position, and
+// returns a new Encoder ready to write samples with the given format.
+func NewEncoder(w io.WriteSeeker, format Format) (*Encoder, error) {
+	return NewEncoderWithChunks(w, format, nil)
+}
+
+// NewEncoderWithChunks is like NewEncoder, additionally writing the given
+// metadata chunks before the wave data.
+func NewEncoderWithChunks(w io.WriteSeeker, format Format, chunks Chunks) (*Encoder, error) {
+	if format.Channels == 0 {
+		return nil, errors.New("invalid format: zero channels")
+	}
+	encode, err := sampleEncoder(format)
+	if err != nil {
+		return nil, err
+	}
+	start, err := w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current position: %w", err)
+	}
+	e := &Encoder{
+		w:          w,
+		bw:         bufio.NewWriter(w),
+		format:     format,
+		chunks:     chunks,
+		encode:     encode,
+		sampleSize: int(format.BitsPerSample) / 8,
+		start:      start,
+	}
+	if err = writeHeader(e.bw, e.header()); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// WriteFrames encodes and writes the interleaved samples of one or more
+// frames. The input data is left untouched.
+func (e *Encoder) WriteFrames(src []float32) error {
+	if len(src)%int(e.format.Channels) != 0 {
+		return fmt.Errorf("samples count %d is not a multiple of channels %d", len(src), e.format.Channels)
+	}
+	size := len(src) * e.sampleSize
+	if cap(e.buf) < size {
+		e.buf = make([]byte, size)
+	}
+	buf := e.buf[:size]
+	sampleSize := e.sampleSize
+	for i, v := range src {
+		j := i * sampleSize
+		e.encode(buf[j:j+sampleSize], v)
+	}
+	if _, err := e.bw.Write(buf); err != nil {
+		return fmt.Errorf("failed to write wave data: %w", err)
+	}
+	e.dataSize += int64(size)
+	return nil
+}
+
+// Close flushes any buffered data and rewrites the header with the final
+// sizes, switching to RF64 form if the data exceeds the RIFF 4 GiB limit.
+// It does not close the underlying writer.
+func (e *Encoder) Close() error {
+	if err := writePadByte(e.bw, e.dataSize); err != nil {
+		return err
+	}
+	if err := e.bw.Flush(); err != nil {
+		return fmt.Errorf("failed to flush wave data: %w", err)
+	}
+	if _, err := e.w.Seek(e.start, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek WAVE header: %w", err)
+	}
+	bw := bufio.NewWriter(e.w)
+	if err := writeHeader(bw, e.header()); err != nil {
+		return fmt.Errorf("failed to rewrite WAVE header: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to rewrite WAVE header: %w", err)
+	}
+	if _, err := e.w.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("failed to seek end of WAVE stream: %w", err)
+	}
+	return nil
+}
+
+func (e *Encoder) header() header {
+	return header{
+		format:      e.format,
+		chunks:      e.chunks,
+		dataSize:    uint64(e.dataSize),
+		reserveDS64: true,
+	}
+}
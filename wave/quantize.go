@@ -0,0 +1,235 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wave
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Dither is the noise added to samples before quantization, so that the
+// quantization error is decorrelated from the signal, rather than heard as
+// distortion on quiet passages.
+//
+// Dither implements flag.Value, parsing "none" or "tpdf".
+type Dither int
+
+const (
+	// NoDither simply rounds samples to the nearest integer value.
+	NoDither Dither = iota
+	// TPDFDither adds noise with triangular probability density function,
+	// with a peak amplitude of 1 LSB.
+	TPDFDither
+)
+
+func (d *Dither) String() string {
+	switch *d {
+	case NoDither:
+		return "none"
+	case TPDFDither:
+		return "tpdf"
+	default:
+		return fmt.Sprintf("Dither(%d)", int(*d))
+	}
+}
+
+func (d *Dither) Set(value string) error {
+	switch value {
+	case "none":
+		*d = NoDither
+	case "tpdf":
+		*d = TPDFDither
+	default:
+		return fmt.Errorf("invalid dither %q: expected \"none\" or \"tpdf\"", value)
+	}
+	return nil
+}
+
+// NoiseShaping selects the error feedback filter which moves the
+// quantization noise towards the frequencies where it is less audible.
+//
+// NoiseShaping implements flag.Value, parsing "none", "first-order",
+// "lipshitz" or "wannamaker".
+type NoiseShaping int
+
+const (
+	// NoNoiseShaping leaves the quantization noise spectrum flat.
+	NoNoiseShaping NoiseShaping = iota
+	// FirstOrderNoiseShaping is a simple high-pass shaping, suitable for
+	// any sample rate.
+	FirstOrderNoiseShaping
+	// LipshitzNoiseShaping is Lipshitz's 5-tap E-weighted filter, designed
+	// for 44.1kHz, and still fit for 48kHz. At higher sample rates it moves
+	// the noise into the audible band: see SuitsSampleRate.
+	LipshitzNoiseShaping
+	// WannamakerNoiseShaping is Wannamaker's 9-tap F-weighted filter,
+	// designed for 44.1kHz, with the same limitation as LipshitzNoiseShaping.
+	WannamakerNoiseShaping
+)
+
+// SuitsSampleRate reports whether the noise shaping filter is fit for the
+// given sample rate: the psychoacoustic filters are designed for 44.1kHz,
+// and are only fit for 44.1 and 48kHz, while the others suit any rate.
+func (s NoiseShaping) SuitsSampleRate(sampleRate int) bool {
+	switch s {
+	case LipshitzNoiseShaping, WannamakerNoiseShaping:
+		return sampleRate == 44_100 || sampleRate == 48_000
+	default:
+		return true
+	}
+}
+
+var noiseShapingNames = map[NoiseShaping]string{
+	NoNoiseShaping:         "none",
+	FirstOrderNoiseShaping: "first-order",
+	LipshitzNoiseShaping:   "lipshitz",
+	WannamakerNoiseShaping: "wannamaker",
+}
+
+// noiseShapingFilters are the error feedback coefficients, from the most
+// recent error.
+var noiseShapingFilters = map[NoiseShaping][]float64{
+	NoNoiseShaping:         nil,
+	FirstOrderNoiseShaping: {1},
+	LipshitzNoiseShaping:   {2.033, -2.165, 1.959, -1.590, 0.6149},
+	WannamakerNoiseShaping: {2.412, -3.370, 3.937, -4.174, 3.353, -2.205, 1.281, -0.569, 0.0847},
+}
+
+func (s *NoiseShaping) String() string {
+	if name, ok := noiseShapingNames[*s]; ok {
+		return name
+	}
+	return fmt.Sprintf("NoiseShaping(%d)", int(*s))
+}
+
+func (s *NoiseShaping) Set(value string) error {
+	for shaping, name := range noiseShapingNames {
+		if name == value {
+			*s = shaping
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid noise shaping %q: expected \"none\", \"first-order\", \"lipshitz\" or \"wannamaker\"", value)
+}
+
+// Quantization configures a Quantizer. The zero value plainly rounds
+// samples.
+type Quantization struct {
+	Dither       Dither
+	NoiseShaping NoiseShaping
+}
+
+// A Quantizer reduces normalized floating-point samples to the resolution
+// of an integer PCM bit depth, with optional dither and noise shaping.
+//
+// The quantized samples are still floating-point values, but exactly
+// representable at the target bit depth, so that encoding them with
+// EncodeFloats, an Encoder, or any other PCM encoder with rounding, does
+// not alter them further. Samples exceeding the representable range are
+// clipped, and counted.
+//
+// Noise shaping keeps track of the error of previous samples across calls,
+// separately for each channel of interleaved data.
+type Quantizer struct {
+	scaling  float64
+	dither   Dither
+	filter   []float64
+	channels int
+	errors   []float64 // error history, per channel, from the most recent one
+	channel  int       // channel of the next sample
+	rnd      *rand.Rand
+	clipped  int
+}
+
+// NewQuantizer creates a new Quantizer for interleaved samples with the
+// given channels, and bits per sample (8, 16 or 24).
+//
+// The dither noise is pseudo-random, but deterministic: the same input
+// always produces the same output.
+func NewQuantizer(channels, bitsPerSample int, quantization Quantization) (*Quantizer, error) {
+	if channels < 1 {
+		return nil, fmt.Errorf("invalid channels %d", channels)
+	}
+	var scaling float64
+	switch bitsPerSample {
+	case 8:
+		scaling = scaling8bit
+	case 16:
+		scaling = scaling16bit
+	case 24:
+		scaling = scaling24bit
+	default:
+		// float32 samples cannot represent 32-bit values exactly.
+		return nil, fmt.Errorf("unsupported %d-bit quantization", bitsPerSample)
+	}
+	if quantization.Dither != NoDither && quantization.Dither != TPDFDither {
+		return nil, fmt.Errorf("invalid dither %d", quantization.Dither)
+	}
+	filter, ok := noiseShapingFilters[quantization.NoiseShaping]
+	if !ok {
+		return nil, fmt.Errorf("invalid noise shaping %d", quantization.NoiseShaping)
+	}
+	return &Quantizer{
+		scaling:  scaling,
+		dither:   quantization.Dither,
+		filter:   filter,
+		channels: channels,
+		errors:   make([]float64, channels*len(filter)),
+		rnd:      rand.New(rand.NewSource(1)),
+	}, nil
+}
+
+// Quantize quantizes the interleaved samples of src, storing the results
+// into dst, which must be at least as long as src. The input samples are
+// left untouched, unless dst and src are the same slice.
+func (q *Quantizer) Quantize(dst, src []float32) {
+	order := len(q.filter)
+	upper := q.scaling - 1
+	for i, v := range src {
+		errors := q.errors[q.channel*order : (q.channel+1)*order]
+
+		u := float64(v) * q.scaling
+		for k, h := range q.filter {
+			u -= h * errors[k]
+		}
+		y := u
+		if q.dither == TPDFDither {
+			y += q.rnd.Float64() - q.rnd.Float64()
+		}
+		y = math.Round(y)
+
+		if order > 0 {
+			// The error is taken before clipping, so that the feedback
+			// stays bounded.
+			copy(errors[1:], errors)
+			errors[0] = y - u
+		}
+		if y < -q.scaling || y > upper {
+			y = clip(y, -q.scaling, upper)
+			q.clipped++
+		}
+		dst[i] = float32(y / q.scaling)
+
+		if q.channel++; q.channel == q.channels {
+			q.channel = 0
+		}
+	}
+}
+
+// Clipped returns the amount of samples clipped so far.
+func (q *Quantizer) Clipped() int {
+	return q.clipped
+}
@@ -16,10 +16,12 @@ package wave
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 )
 
@@ -35,117 +37,352 @@ func ReadFile(name string) (_ *Wave, err error) {
 	}()
 	w, err := Read(bufio.NewReader(file))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read WAV file %q: %w", name, err)
+		return w, fmt.Errorf("failed to read WAV file %q: %w", name, err)
 	}
 	return w, nil
 }
 
+// Read reads a whole WAVE stream. Metadata chunks, preceding and following
+// the wave data, are kept in Wave.Chunks.
+//
+// If the wave data is shorter than declared, Read returns the available
+// whole frames along with a *TruncatedDataError, so that lenient callers
+// can recover them.
 func Read(r io.Reader) (*Wave, error) {
-	var err error
-	if err = expectFourCharCode(r, riffChunkID); err != nil {
+	lr, h, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	b, err := readWaveData(lr, h.dataSize, h.format)
+	var truncated *TruncatedDataError
+	if errors.As(err, &truncated) {
+		return &Wave{Format: h.format, Data: b, Chunks: h.chunks}, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = skipPadByte(lr, h.dataSize); err != nil {
+		return nil, err
+	}
+	trailing, err := readChunks(lr)
+	if err != nil {
+		return nil, err
+	}
+	wav := &Wave{
+		Format: h.format,
+		Data:   b,
+		Chunks: append(h.chunks, trailing...),
+	}
+	return wav, nil
+}
+
+// TruncatedDataError reports that the wave data is shorter than declared
+// by the data chunk, as it happens with files left by crashed recorders.
+type TruncatedDataError struct {
+	// DeclaredSize is the data size declared by the data chunk.
+	DeclaredSize uint64
+	// ActualSize is the size of the data actually available.
+	ActualSize uint64
+}
+
+func (e *TruncatedDataError) Error() string {
+	return fmt.Sprintf("truncated wave data: declared %d bytes, actual %d", e.DeclaredSize, e.ActualSize)
+}
+
+func (e *TruncatedDataError) Unwrap() error {
+	return io.ErrUnexpectedEOF
+}
+
+// ReadChunks reads the metadata chunks of a WAVE stream, preceding and
+// following the wave data, which is skipped by seeking.
+func ReadChunks(r io.ReadSeeker) (Chunks, error) {
+	lr, h, err := readHeader(r)
+	if err != nil {
 		return nil, err
 	}
+	size := h.dataSize + h.dataSize%2 // including the pad byte
+	if size > uint64(lr.N) {
+		return h.chunks, nil
+	}
+	if _, err = r.Seek(int64(size), io.SeekCurrent); err != nil {
+		return nil, fmt.Errorf("failed to skip wave data: %w", err)
+	}
+	lr.N -= int64(size)
+	trailing, err := readChunks(lr)
+	if err != nil {
+		return nil, err
+	}
+	return append(h.chunks, trailing...), nil
+}
+
+// readHeader reads the RIFF header, the format chunk and any other chunk
+// preceding the wave data, stopping right at the beginning of the data.
+// It returns a reader limited to the RIFF chunk and a header with the
+// format, the metadata chunks and the size of the wave data.
+//
+// Both plain RIFF and RF64/BW64 forms are supported; with the latter, the
+// 64-bit sizes are taken from the "ds64" chunk.
+func readHeader(r io.Reader) (*io.LimitedReader, header, error) {
+	riffID, err := readFourCharCode(r)
+	if err != nil {
+		return nil, header{}, err
+	}
+	if riffID != riffChunkID && riffID != rf64ChunkID && riffID != bw64ChunkID {
+		return nil, header{}, fmt.Errorf("expected four-character code %q, %q or %q, actual %q",
+			string(riffChunkID[:]), string(rf64ChunkID[:]), string(bw64ChunkID[:]), string(riffID[:]))
+	}
+	rf64 := riffID != riffChunkID
 
 	riffChunkSize, err := readUint32(r)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read RIFF chunk size: %w", err)
+		return nil, header{}, fmt.Errorf("failed to read RIFF chunk size: %w", err)
+	}
+	lr := &io.LimitedReader{R: r, N: int64(riffChunkSize)}
+	if rf64 {
+		lr.N = math.MaxInt64
 	}
-	r = io.LimitReader(r, int64(riffChunkSize))
 
-	if err = expectFourCharCode(r, waveFormType); err != nil {
-		return nil, err
+	if err = expectFourCharCode(lr, waveFormType); err != nil {
+		return nil, header{}, err
 	}
 
-	format, b, err := readFormatChunkAndWaveData(r)
+	var ds64 ds64Chunk
+	if rf64 {
+		if ds64, err = readDS64Chunk(lr); err != nil {
+			return nil, header{}, err
+		}
+		riffSize := uint64(riffChunkSize)
+		if riffChunkSize == math.MaxUint32 {
+			riffSize = ds64.riffSize
+		}
+		// Limit to the remaining size, excluding "WAVE" and the ds64 chunk.
+		remaining := int64(min(riffSize, math.MaxInt64)) - 4 - 8 - int64(ds64.chunkSize)
+		lr.N = max(remaining, 0)
+	}
+
+	format, chunks, dataSize32, err := readFormatChunkAndWaveDataSize(lr)
 	if err != nil {
-		return nil, err
+		return nil, header{}, err
 	}
+	h := header{format: format, chunks: chunks, dataSize: uint64(dataSize32)}
+	if rf64 && dataSize32 == math.MaxUint32 {
+		h.dataSize = ds64.dataSize
+	}
+	return lr, h, nil
+}
 
-	wav := &Wave{Format: format, Data: b}
-	return wav, nil
+// ds64Chunk holds the content of an RF64/BW64 "ds64" chunk, relevant for
+// reading the wave data.
+type ds64Chunk struct {
+	chunkSize uint32
+	riffSize  uint64
+	dataSize  uint64
 }
 
-func readFormatChunkAndWaveData(r io.Reader) (Format, []byte, error) {
+func readDS64Chunk(r io.Reader) (ds64Chunk, error) {
+	if err := expectFourCharCode(r, ds64ChunkID); err != nil {
+		return ds64Chunk{}, err
+	}
+	chunkSize, err := readUint32(r)
+	if err != nil {
+		return ds64Chunk{}, fmt.Errorf("failed to read ds64 chunk size: %w", err)
+	}
+	if chunkSize < ds64ChunkSize {
+		return ds64Chunk{}, fmt.Errorf("ds64 chunk is too short: expected at least %d bytes, actual %d", ds64ChunkSize, chunkSize)
+	}
+	lr := io.LimitReader(r, int64(chunkSize))
+
+	ds64 := ds64Chunk{chunkSize: chunkSize}
+	if ds64.riffSize, err = readUint64(lr); err != nil {
+		return ds64Chunk{}, fmt.Errorf("failed to read ds64 RIFF size: %w", err)
+	}
+	if ds64.dataSize, err = readUint64(lr); err != nil {
+		return ds64Chunk{}, fmt.Errorf("failed to read ds64 data size: %w", err)
+	}
+	// The sample count and the table of other chunk sizes are not needed.
+	if err = skipUntilEOF(lr); err != nil {
+		return ds64Chunk{}, fmt.Errorf("failed to skip remaining ds64 chunk data: %w", err)
+	}
+	if err = skipPadByte(r, chunkSize); err != nil {
+		return ds64Chunk{}, err
+	}
+	return ds64, nil
+}
+
+func readFormatChunkAndWaveDataSize(r io.Reader) (Format, Chunks, uint32, error) {
 	var format Format
+	var chunks Chunks
 	formatFound := false
 	for {
 		chunkID, err := readFourCharCode(r)
 		if err != nil {
-			return Format{}, nil, err
+			return Format{}, nil, 0, err
 		}
 		switch chunkID {
 		case fmtChunkID:
 			if formatFound {
-				return Format{}, nil, errors.New("duplicate format chunk encountered")
+				return Format{}, nil, 0, errors.New("duplicate format chunk encountered")
 			}
 			formatFound = true
 			format, err = readFormatChunk(r)
 			if err != nil {
-				return Format{}, nil, err
+				return Format{}, nil, 0, err
 			}
 		case dataChunkID:
 			if !formatFound {
-				return Format{}, nil, errors.New("wave data is not preceded by format chunk")
+				return Format{}, nil, 0, errors.New("wave data is not preceded by format chunk")
 			}
-			b, err := readWaveData(r)
+			dataSize, err := readUint32(r)
 			if err != nil {
-				return Format{}, nil, err
+				return Format{}, nil, 0, fmt.Errorf("failed to read wave data chunk size: %w", err)
 			}
-			return format, b, nil
+			return format, chunks, dataSize, nil
 		default:
-			if err = skipChunk(r, chunkID); err != nil {
-				return Format{}, nil, err
+			if chunks, err = readOrSkipChunk(r, chunkID, chunks); err != nil {
+				return Format{}, nil, 0, err
 			}
 		}
 	}
 }
 
+// readChunks reads all the remaining chunks, until EOF.
+func readChunks(r io.Reader) (Chunks, error) {
+	var chunks Chunks
+	for {
+		chunkID, err := readFourCharCode(r)
+		if errors.Is(err, io.EOF) {
+			return chunks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if chunks, err = readOrSkipChunk(r, chunkID, chunks); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readOrSkipChunk reads a metadata chunk, appending it to chunks, or skips
+// it if it is not meant to be preserved.
+func readOrSkipChunk(r io.Reader, chunkID [4]byte, chunks Chunks) (Chunks, error) {
+	if !isMetadataChunkID(chunkID) {
+		return chunks, skipChunk(r, chunkID)
+	}
+	chunk, err := readChunk(r, chunkID)
+	if err != nil {
+		return nil, err
+	}
+	return append(chunks, chunk), nil
+}
+
+func readChunk(r io.Reader, chunkID [4]byte) (Chunk, error) {
+	chunkSize, err := readUint32(r)
+	if err != nil {
+		return Chunk{}, fmt.Errorf("failed to read %q chunk size: %w", string(chunkID[:]), err)
+	}
+	// Not allocating the declared size upfront protects from corrupted sizes.
+	data, err := io.ReadAll(io.LimitReader(r, int64(chunkSize)))
+	if err != nil {
+		return Chunk{}, fmt.Errorf("failed to read %q chunk data: %w", string(chunkID[:]), err)
+	}
+	if len(data) != int(chunkSize) {
+		return Chunk{}, fmt.Errorf("failed to read %q chunk data: %w", string(chunkID[:]), io.ErrUnexpectedEOF)
+	}
+	if err = skipPadByte(r, chunkSize); err != nil {
+		return Chunk{}, err
+	}
+	return Chunk{ID: chunkID, Data: data}, nil
+}
+
 func readFormatChunk(r io.Reader) (Format, error) {
 	chunkSize, err := readUint32(r)
 	if err != nil {
 		return Format{}, fmt.Errorf("failed to read format chunk size: %w", err)
 	}
-	r = io.LimitReader(r, int64(chunkSize))
+	cr := io.LimitReader(r, int64(chunkSize))
 
-	formatTag, err := readUint16(r)
+	formatTag, err := readUint16(cr)
 	if err != nil {
 		return Format{}, fmt.Errorf("failed to read format tag: %w", err)
 	}
-	if formatTag != wavePCMFormatTag {
-		return Format{}, fmt.Errorf("unsupported format tag %d: only WAVE format tag %d is supported", formatTag, wavePCMFormatTag)
+	if formatTag != PCMFormatTag && formatTag != IEEEFloatFormatTag && formatTag != ExtensibleFormatTag {
+		return Format{}, fmt.Errorf("unsupported format tag %d: only WAVE format tags %d (PCM), %d (IEEE float) and %d (extensible) are supported",
+			formatTag, PCMFormatTag, IEEEFloatFormatTag, ExtensibleFormatTag)
 	}
 
-	var format Format
-	if format.Channels, err = readUint16(r); err != nil {
+	format := Format{FormatTag: formatTag}
+	if format.Channels, err = readUint16(cr); err != nil {
 		return Format{}, fmt.Errorf("failed to read format's channels: %w", err)
 	}
-	if format.SampleRate, err = readUint32(r); err != nil {
+	if format.SampleRate, err = readUint32(cr); err != nil {
 		return Format{}, fmt.Errorf("failed to read format's' sample rate: %w", err)
 	}
-	if format.AvgByteRate, err = readUint32(r); err != nil {
+	if format.AvgByteRate, err = readUint32(cr); err != nil {
 		return Format{}, fmt.Errorf("failed to read format's' average byte rate rate: %w", err)
 	}
-	if format.BlockAlign, err = readUint16(r); err != nil {
+	if format.BlockAlign, err = readUint16(cr); err != nil {
 		return Format{}, fmt.Errorf("failed to read format's block align: %w", err)
 	}
-	if format.BitsPerSample, err = readUint16(r); err != nil {
+	if format.BitsPerSample, err = readUint16(cr); err != nil {
 		return Format{}, fmt.Errorf("failed to read format's bits per sample: %w", err)
 	}
-	if err = skipUntilEOF(r); err != nil {
+	if formatTag == ExtensibleFormatTag {
+		if err = readFormatExtension(cr, &format); err != nil {
+			return Format{}, err
+		}
+	}
+	if err = skipUntilEOF(cr); err != nil {
 		return Format{}, fmt.Errorf("failed to skip remaining format chunk data: %w", err)
 	}
+	if err = skipPadByte(r, chunkSize); err != nil {
+		return Format{}, err
+	}
 	return format, nil
 }
 
-func readWaveData(r io.Reader) ([]byte, error) {
-	chunkSize, err := readUint32(r)
+func readFormatExtension(r io.Reader, format *Format) (err error) {
+	extensionSize, err := readUint16(r)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read wave data chunk size: %w", err)
+		return fmt.Errorf("failed to read format's extension size: %w", err)
+	}
+	if extensionSize < formatExtensionSize {
+		return fmt.Errorf("format extension is too short: expected at least %d bytes, actual %d", formatExtensionSize, extensionSize)
+	}
+	if format.ValidBitsPerSample, err = readUint16(r); err != nil {
+		return fmt.Errorf("failed to read format's valid bits per sample: %w", err)
+	}
+	if format.ChannelMask, err = readUint32(r); err != nil {
+		return fmt.Errorf("failed to read format's channel mask: %w", err)
 	}
-	b := make([]byte, chunkSize)
-	_, err = io.ReadFull(r, b)
+	if _, err = io.ReadFull(r, format.SubFormat[:]); err != nil {
+		return fmt.Errorf("failed to read format's sub-format: %w", err)
+	}
+	if tag := format.SampleFormatTag(); tag != PCMFormatTag && tag != IEEEFloatFormatTag {
+		return fmt.Errorf("unsupported extensible format's sub-format %x", format.SubFormat)
+	}
+	return nil
+}
+
+// maxDataPrealloc limits the memory allocated upfront for reading the wave
+// data, which might be much shorter than declared.
+const maxDataPrealloc = 64 << 20
+
+// readWaveData reads the wave data of the given declared size. If less data
+// is available, it returns the whole frames read along with a
+// *TruncatedDataError.
+func readWaveData(r io.Reader, size uint64, format Format) ([]byte, error) {
+	if size > math.MaxInt {
+		return nil, fmt.Errorf("wave data size %d exceeds the addressable memory", size)
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, min(size, maxDataPrealloc)))
+	n, err := buf.ReadFrom(io.LimitReader(r, int64(size)))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read ave data: %w", err)
+		return nil, fmt.Errorf("failed to read wave data: %w", err)
+	}
+	b := buf.Bytes()
+	if uint64(n) < size {
+		frameSize := max(int(format.BlockAlign), 1)
+		return b[:len(b)/frameSize*frameSize], &TruncatedDataError{DeclaredSize: size, ActualSize: uint64(n)}
 	}
 	return b, nil
 }
@@ -155,10 +392,23 @@ func skipChunk(r io.Reader, chunkID [4]byte) error {
 	if err != nil {
 		return fmt.Errorf("failed to read %q chunk size: %w", string(chunkID[:]), err)
 	}
-	r = io.LimitReader(r, int64(chunkSize))
-	if err = skipUntilEOF(r); err != nil {
+	if err = skipUntilEOF(io.LimitReader(r, int64(chunkSize))); err != nil {
 		return fmt.Errorf("failed to skip %q chunk data: %w", string(chunkID[:]), err)
 	}
+	return skipPadByte(r, chunkSize)
+}
+
+// skipPadByte skips the pad byte following a chunk of odd size, since RIFF
+// chunks are word-aligned. A missing pad byte at the end of the stream is
+// tolerated.
+func skipPadByte[T uint32 | uint64](r io.Reader, chunkSize T) error {
+	if chunkSize%2 == 0 {
+		return nil
+	}
+	var b [1]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to skip pad byte: %w", err)
+	}
 	return nil
 }
 
@@ -199,6 +449,15 @@ func readUint32(r io.Reader) (uint32, error) {
 	return binary.LittleEndian.Uint32(buf), nil
 }
 
+func readUint64(r io.Reader) (uint64, error) {
+	var arr [8]byte
+	buf := arr[:]
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, fmt.Errorf("failed to read uint64 value: %w", err)
+	}
+	return binary.LittleEndian.Uint64(buf), nil
+}
+
 func skipUntilEOF(r io.Reader) error {
 	var arr [1]byte
 	var buf = arr[:]
@@ -215,18 +474,57 @@ func skipUntilEOF(r io.Reader) error {
 	}
 }
 
+// Decode8BitData converts 8-bit unsigned PCM wave data to signed values,
+// in the range [-128, 127].
+func Decode8BitData[T int | int32 | int64 | float32 | float64](wav *Wave) ([]T, error) {
+	return decodeData(wav, 8, "8-bit", func(b []byte) T {
+		return T(int32(b[0]) - 128)
+	})
+}
+
+func Decode16BitData[T int | int32 | int64 | float32 | float64](wav *Wave) ([]T, error) {
+	return decodeData(wav, 16, "16-bit", func(b []byte) T {
+		return T(int16(binary.LittleEndian.Uint16(b)))
+	})
+}
+
 func Decode24BitData[T int | int32 | int64 | float32 | float64](wav *Wave) ([]T, error) {
-	if wav.Format.BitsPerSample != 24 {
-		return nil, fmt.Errorf("cannot convert wave data to 24-bit values: format's bits per sample is %d", wav.Format.BitsPerSample)
+	return decodeData(wav, 24, "24-bit", func(b []byte) T {
+		return T(littleEndianInt24(b))
+	})
+}
+
+func Decode32BitData[T int | int32 | int64 | float32 | float64](wav *Wave) ([]T, error) {
+	return decodeData(wav, 32, "32-bit", func(b []byte) T {
+		return T(int32(binary.LittleEndian.Uint32(b)))
+	})
+}
+
+func DecodeFloat32Data[T float32 | float64](wav *Wave) ([]T, error) {
+	return decodeData(wav, 32, "32-bit float", func(b []byte) T {
+		return T(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	})
+}
+
+func DecodeFloat64Data[T float32 | float64](wav *Wave) ([]T, error) {
+	return decodeData(wav, 64, "64-bit float", func(b []byte) T {
+		return T(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+	})
+}
+
+func decodeData[T any](wav *Wave, bitsPerSample int, name string, decode func([]byte) T) ([]T, error) {
+	if int(wav.Format.BitsPerSample) != bitsPerSample {
+		return nil, fmt.Errorf("cannot convert wave data to %s values: format's bits per sample is %d", name, wav.Format.BitsPerSample)
 	}
 	dataIn := wav.Data
-	if len(dataIn)%3 != 0 {
-		return nil, fmt.Errorf("cannot convert wave data to 24-bit values: bad data length %d", len(wav.Data))
+	size := bitsPerSample / 8
+	if len(dataIn)%size != 0 {
+		return nil, fmt.Errorf("cannot convert wave data to %s values: bad data length %d", name, len(dataIn))
 	}
-	dataOut := make([]T, len(wav.Data)/3)
+	dataOut := make([]T, len(dataIn)/size)
 	for i := range dataOut {
-		j := i * 3
-		dataOut[i] = T(littleEndianInt24(dataIn[j : j+3]))
+		j := i * size
+		dataOut[i] = decode(dataIn[j : j+size])
 	}
 	return dataOut, nil
 }
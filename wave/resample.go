@@ -0,0 +1,243 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wave
+
+import (
+	"fmt"
+	"math"
+)
+
+// ResampleQuality selects the trade-off between accuracy and speed of a
+// Resampler.
+type ResampleQuality int
+
+const (
+	// ResampleLow uses a short filter, suitable for previews.
+	ResampleLow ResampleQuality = iota
+	// ResampleMedium offers about 80 dB of stop-band attenuation.
+	ResampleMedium
+	// ResampleHigh offers more than 100 dB of stop-band attenuation and a
+	// narrow transition band, suitable for mastering-grade conversions.
+	ResampleHigh
+)
+
+type resampleParams struct {
+	halfTaps int     // half of the filter length, in input samples, when upsampling
+	beta     float64 // Kaiser window shape
+	rolloff  float64 // cutoff, relative to the lower Nyquist frequency
+}
+
+var resampleQualityParams = map[ResampleQuality]resampleParams{
+	ResampleLow:    {halfTaps: 8, beta: 6, rolloff: 0.90},
+	ResampleMedium: {halfTaps: 24, beta: 8.5, rolloff: 0.94},
+	ResampleHigh:   {halfTaps: 64, beta: 11, rolloff: 0.96},
+}
+
+// A Resampler converts a mono stream of samples from one sample rate to
+// another, with a windowed-sinc polyphase filter.
+//
+// The conversion ratio is reduced to a fraction up/down: every output sample
+// is computed with one of the up filter phases, selected by its position
+// relative to the input. The filter is centered on each output position,
+// so the output is not delayed with respect to the input.
+//
+// Input can be provided incrementally: the Resampler keeps enough history,
+// and look-ahead, across calls.
+type Resampler struct {
+	up       int64
+	down     int64
+	halfTaps int
+	cutoff   float64
+	beta     float64
+	i0Beta   float64
+	phases   [][]float32 // lazily computed filter phases
+
+	buf      []float32 // buffered input, starting at input index bufStart
+	bufStart int64
+	inCount  int64 // total amount of input samples received
+	outCount int64 // total amount of output samples produced
+}
+
+// NewResampler creates a new Resampler, converting from inRate to outRate.
+func NewResampler(inRate, outRate int, quality ResampleQuality) (*Resampler, error) {
+	if inRate <= 0 || outRate <= 0 {
+		return nil, fmt.Errorf("invalid sample rates %d and %d", inRate, outRate)
+	}
+	params, ok := resampleQualityParams[quality]
+	if !ok {
+		return nil, fmt.Errorf("invalid resample quality %d", quality)
+	}
+
+	g := gcd(inRate, outRate)
+	up := int64(outRate / g)
+	down := int64(inRate / g)
+
+	// When downsampling, the cutoff is lowered to the output Nyquist
+	// frequency, and the filter is stretched accordingly, to keep the
+	// same transition bandwidth in the output domain.
+	ratio := min(1, float64(up)/float64(down))
+	halfTaps := int(math.Ceil(float64(params.halfTaps) / ratio))
+
+	r := &Resampler{
+		up:       up,
+		down:     down,
+		halfTaps: halfTaps,
+		cutoff:   ratio * params.rolloff,
+		beta:     params.beta,
+		i0Beta:   besselI0(params.beta),
+		phases:   make([][]float32, up),
+		buf:      make([]float32, halfTaps-1),
+		bufStart: -int64(halfTaps - 1),
+	}
+	return r, nil
+}
+
+// Process resamples src, appending to dst all the output samples which can
+// be computed so far, and returns the extended slice.
+func (r *Resampler) Process(dst, src []float32) []float32 {
+	r.buf = append(r.buf, src...)
+	r.inCount += int64(len(src))
+	dst = r.produce(dst, false)
+	r.compact()
+	return dst
+}
+
+// Flush appends to dst the remaining output samples, treating the input
+// as terminated, and returns the extended slice. The total amount of
+// output samples is ceil(inputSamples * outRate / inRate).
+// The Resampler must not be used after Flush.
+func (r *Resampler) Flush(dst []float32) []float32 {
+	return r.produce(dst, true)
+}
+
+func (r *Resampler) produce(dst []float32, flush bool) []float32 {
+	halfTaps := int64(r.halfTaps)
+	for {
+		pos := r.outCount * r.down
+		i := pos / r.up
+		if flush {
+			if pos >= r.inCount*r.up {
+				return dst
+			}
+		} else if i+halfTaps >= r.inCount {
+			return dst
+		}
+
+		taps := r.phase(pos % r.up)
+		from := i - halfTaps + 1 - r.bufStart
+		v := float32(0)
+		for k, h := range taps {
+			if j := from + int64(k); j < int64(len(r.buf)) {
+				v += h * r.buf[j]
+			}
+		}
+		dst = append(dst, v)
+		r.outCount++
+	}
+}
+
+// compact discards the buffered input which is no longer needed.
+func (r *Resampler) compact() {
+	next := r.outCount*r.down/r.up - int64(r.halfTaps) + 1
+	n := next - r.bufStart
+	if n <= 0 || n < int64(len(r.buf))/2 {
+		return
+	}
+	r.buf = r.buf[:copy(r.buf, r.buf[n:])]
+	r.bufStart = next
+}
+
+// phase returns the filter taps for the given phase, computing them at
+// the first use.
+func (r *Resampler) phase(p int64) []float32 {
+	if taps := r.phases[p]; taps != nil {
+		return taps
+	}
+	n := 2 * r.halfTaps
+	taps := make([]float32, n)
+	frac := float64(p) / float64(r.up)
+	for k := range taps {
+		// distance from the output position to the input sample
+		x := frac + float64(r.halfTaps-1-k)
+		taps[k] = float32(r.cutoff * sinc(r.cutoff*x) * r.kaiser(x/float64(r.halfTaps)))
+	}
+	r.phases[p] = taps
+	return taps
+}
+
+func (r *Resampler) kaiser(x float64) float64 {
+	if x <= -1 || x >= 1 {
+		return 0
+	}
+	return besselI0(r.beta*math.Sqrt(1-x*x)) / r.i0Beta
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	x *= math.Pi
+	return math.Sin(x) / x
+}
+
+// besselI0 computes the zeroth-order modified Bessel function of the first
+// kind, by power series.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	q := x * x / 4
+	for k := 1; term > sum*1e-12; k++ {
+		term *= q / float64(k*k)
+		sum += term
+	}
+	return sum
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// Resample converts a whole mono signal from inRate to outRate.
+// When the rates are equal, data is returned unchanged.
+func Resample(data []float32, inRate, outRate int, quality ResampleQuality) ([]float32, error) {
+	if inRate == outRate {
+		return data, nil
+	}
+	r, err := NewResampler(inRate, outRate, quality)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]float32, 0, int64(len(data))*r.up/r.down+1)
+	out = r.Process(out, data)
+	return r.Flush(out), nil
+}
+
+// ResampleInterleaved converts a whole multi-channel signal, with
+// interleaved samples, from inRate to outRate.
+func ResampleInterleaved(data []float32, channels, inRate, outRate int, quality ResampleQuality) ([]float32, error) {
+	if inRate == outRate {
+		return data, nil
+	}
+	streams := Deinterleave(data, channels)
+	for i, stream := range streams {
+		var err error
+		if streams[i], err = Resample(stream, inRate, outRate, quality); err != nil {
+			return nil, err
+		}
+	}
+	return Interleave(streams), nil
+}
@@ -15,32 +15,123 @@
 package wave
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/nlpodyssey/spago/mat"
 	"math"
 )
 
+const (
+	scaling8bit  = 1 << 7  // 2 ** (8 - 1)
+	scaling16bit = 1 << 15 // 2 ** (16 - 1)
+	scaling24bit = 2 << 22 // 2 ** (24 - 1)
+	scaling32bit = 1 << 31 // 2 ** (32 - 1)
+)
+
 type Wave struct {
 	Format Format
 	Data   []byte
+	// Chunks holds the metadata chunks, such as LIST/INFO, bext or cue,
+	// which are preserved verbatim when reading and writing.
+	Chunks Chunks
 }
 
 type Format struct {
+	FormatTag     uint16
 	Channels      uint16
 	SampleRate    uint32
 	AvgByteRate   uint32
 	BlockAlign    uint16
 	BitsPerSample uint16
+
+	// Fields below are only meaningful for WAVE_FORMAT_EXTENSIBLE.
+
+	ValidBitsPerSample uint16
+	ChannelMask        uint32
+	SubFormat          GUID
+}
+
+// GUID is a globally unique identifier, stored in its binary layout.
+type GUID [16]byte
+
+// Supported WAVE format tags.
+const (
+	PCMFormatTag        uint16 = 1
+	IEEEFloatFormatTag  uint16 = 3
+	ExtensibleFormatTag uint16 = 0xFFFE
+)
+
+// Supported WAVE_FORMAT_EXTENSIBLE sub-formats. A sub-format GUID embeds a
+// basic format tag in its first two bytes.
+var (
+	PCMSubFormat       = makeSubFormat(PCMFormatTag)
+	IEEEFloatSubFormat = makeSubFormat(IEEEFloatFormatTag)
+)
+
+func makeSubFormat(formatTag uint16) GUID {
+	return GUID{
+		byte(formatTag), byte(formatTag >> 8), 0x00, 0x00, 0x00, 0x00, 0x10, 0x00,
+		0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71,
+	}
+}
+
+// SampleFormatTag returns the format tag describing the samples encoding:
+// for WAVE_FORMAT_EXTENSIBLE, the tag is derived from the sub-format,
+// otherwise it is simply FormatTag.
+func (f Format) SampleFormatTag() uint16 {
+	if f.FormatTag != ExtensibleFormatTag {
+		return f.FormatTag
+	}
+	tag := uint16(f.SubFormat[0]) | uint16(f.SubFormat[1])<<8
+	if f.SubFormat != makeSubFormat(tag) {
+		return 0
+	}
+	return tag
+}
+
+// IsExtensible reports whether the format is written in
+// WAVE_FORMAT_EXTENSIBLE form: this is the case when explicitly requested
+// via FormatTag, or when the data has more than 2 channels or more than
+// 16 bits per sample.
+func (f Format) IsExtensible() bool {
+	return f.FormatTag == ExtensibleFormatTag || f.Channels > 2 || f.BitsPerSample > 16
 }
 
 var (
-	wavePCMFormatTag uint16 = 1
-	riffChunkID             = [4]byte{'R', 'I', 'F', 'F'}
-	waveFormType            = [4]byte{'W', 'A', 'V', 'E'}
-	fmtChunkID              = [4]byte{'f', 'm', 't', ' '}
-	dataChunkID             = [4]byte{'d', 'a', 't', 'a'}
+	riffChunkID  = [4]byte{'R', 'I', 'F', 'F'}
+	waveFormType = [4]byte{'W', 'A', 'V', 'E'}
+	fmtChunkID   = [4]byte{'f', 'm', 't', ' '}
+	dataChunkID  = [4]byte{'d', 'a', 't', 'a'}
+	rf64ChunkID  = [4]byte{'R', 'F', '6', '4'}
+	bw64ChunkID  = [4]byte{'B', 'W', '6', '4'}
+	ds64ChunkID  = [4]byte{'d', 's', '6', '4'}
+	junkChunkID  = [4]byte{'J', 'U', 'N', 'K'}
 )
 
+// NewPCMFormat returns the Format for integer PCM data with the given
+// channels, sample rate and bits per sample (8, 16, 24 or 32).
+func NewPCMFormat(channels, sampleRate, bitsPerSample int) Format {
+	return newFormat(PCMFormatTag, channels, sampleRate, bitsPerSample)
+}
+
+// NewIEEEFloatFormat returns the Format for IEEE floating-point data with the
+// given channels, sample rate and bits per sample (32 or 64).
+func NewIEEEFloatFormat(channels, sampleRate, bitsPerSample int) Format {
+	return newFormat(IEEEFloatFormatTag, channels, sampleRate, bitsPerSample)
+}
+
+func newFormat(formatTag uint16, channels, sampleRate, bitsPerSample int) Format {
+	return Format{
+		FormatTag:     formatTag,
+		Channels:      uint16(channels),
+		SampleRate:    uint32(sampleRate),
+		AvgByteRate:   ComputePCMBAvgByteRate(channels, bitsPerSample, sampleRate),
+		BlockAlign:    ComputePCMBlockAlign(channels, bitsPerSample),
+		BitsPerSample: uint16(bitsPerSample),
+	}
+}
+
 func ComputePCMBlockAlign(channels, bitsPerSample int) uint16 {
 	return uint16(math.Ceil(float64(channels) * float64(bitsPerSample) / 8))
 }
@@ -49,32 +140,161 @@ func ComputePCMBAvgByteRate(channels, bitsPerSample, sampleRate int) uint32 {
 	return uint32(math.Ceil(float64(channels) * float64(sampleRate) * float64(bitsPerSample) / 8))
 }
 
-const scaling24bit = 2 << 22 // 2 ** (24 - 1)
+// DefaultFormat is the format used by FloatsToWav: PCM 48kHz 24-bit mono.
+var DefaultFormat = NewPCMFormat(1, 48_000, 24)
 
+// WavToFloats reads a mono WAVE file, returning its normalized samples at
+// 48kHz. Files with a different sample rate are resampled.
 func WavToFloats(filename string) ([]float32, error) {
-	wav, err := ReadFile(filename)
+	data, format, err := WavToFloatsWithFormat(filename)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read WAV file: %w", err)
+		return nil, err
 	}
-	if wav.Format.Channels != 1 {
-		return nil, fmt.Errorf("only 1 channel (mono) is supported, actual: %d", wav.Format.Channels)
+	if format.Channels != 1 {
+		return nil, fmt.Errorf("only 1 channel (mono) is supported, actual: %d", format.Channels)
 	}
-	if wav.Format.SampleRate != 48_000 {
-		return nil, fmt.Errorf("only sample rate 48000 is supported, actual: %d", wav.Format.SampleRate)
+	return Resample(data, int(format.SampleRate), 48_000, ResampleHigh)
+}
+
+// WavToFloatsWithFormat reads a WAVE file of any supported format, returning
+// its normalized floating-point samples, interleaved, and the format.
+func WavToFloatsWithFormat(filename string) ([]float32, Format, error) {
+	data, format, _, err := WavToFloatsWithMetadata(filename)
+	return data, format, err
+}
+
+// WavToFloatsWithMetadata is like WavToFloatsWithFormat, additionally
+// returning the metadata chunks.
+//
+// Like Read, if the wave data is truncated, it returns the available samples
+// along with a *TruncatedDataError.
+func WavToFloatsWithMetadata(filename string) ([]float32, Format, Chunks, error) {
+	wav, readErr := ReadFile(filename)
+	var truncated *TruncatedDataError
+	if readErr != nil && !errors.As(readErr, &truncated) {
+		return nil, Format{}, nil, fmt.Errorf("failed to read WAV file: %w", readErr)
 	}
-	if wav.Format.BitsPerSample != 24 {
-		return nil, fmt.Errorf("only 24-bit samples are supported, actual: %d", wav.Format.BitsPerSample)
+	data, err := DecodeFloats(wav)
+	if err != nil {
+		return nil, Format{}, nil, err
 	}
+	return data, wav.Format, wav.Chunks, readErr
+}
 
-	data, err := Decode24BitData[float32](wav)
+// DecodeFloats converts the wave data to normalized floating-point samples,
+// dispatching on the format tag and the bits per sample. Integer PCM values
+// are scaled to the range [-1, 1); IEEE float values are returned unchanged.
+func DecodeFloats(wav *Wave) ([]float32, error) {
+	decode, err := sampleDecoder(wav.Format)
 	if err != nil {
 		return nil, err
 	}
+	bitsPerSample := int(wav.Format.BitsPerSample)
+	return decodeData(wav, bitsPerSample, fmt.Sprintf("%d-bit", bitsPerSample), decode)
+}
 
-	for i := range data {
-		data[i] /= scaling24bit
+// EncodeFloats converts normalized floating-point samples to wave data,
+// according to the given format. Integer PCM values are clipped to the
+// range [-1, 1] before scaling. The input data is left untouched.
+func EncodeFloats(data []float32, format Format) ([]byte, error) {
+	encode, err := sampleEncoder(format)
+	if err != nil {
+		return nil, err
 	}
-	return data, nil
+	return encodeData(data, int(format.BitsPerSample)/8, encode), nil
+}
+
+// sampleDecoder returns a function which decodes a single sample, according
+// to the given format, as a normalized floating-point value.
+func sampleDecoder(format Format) (func([]byte) float32, error) {
+	switch format.SampleFormatTag() {
+	case PCMFormatTag:
+		switch format.BitsPerSample {
+		case 8:
+			return func(b []byte) float32 {
+				return float32(int32(b[0])-128) / scaling8bit
+			}, nil
+		case 16:
+			return func(b []byte) float32 {
+				return float32(int16(binary.LittleEndian.Uint16(b))) / scaling16bit
+			}, nil
+		case 24:
+			return func(b []byte) float32 {
+				return float32(littleEndianInt24(b)) / scaling24bit
+			}, nil
+		case 32:
+			return func(b []byte) float32 {
+				return float32(float64(int32(binary.LittleEndian.Uint32(b))) / scaling32bit)
+			}, nil
+		default:
+			return nil, fmt.Errorf("unsupported %d-bit PCM samples", format.BitsPerSample)
+		}
+	case IEEEFloatFormatTag:
+		switch format.BitsPerSample {
+		case 32:
+			return func(b []byte) float32 {
+				return math.Float32frombits(binary.LittleEndian.Uint32(b))
+			}, nil
+		case 64:
+			return func(b []byte) float32 {
+				return float32(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+			}, nil
+		default:
+			return nil, fmt.Errorf("unsupported %d-bit IEEE float samples", format.BitsPerSample)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format tag %d", format.SampleFormatTag())
+	}
+}
+
+// sampleEncoder returns a function which encodes a single normalized
+// floating-point sample, according to the given format.
+func sampleEncoder(format Format) (func([]byte, float32), error) {
+	switch format.SampleFormatTag() {
+	case PCMFormatTag:
+		switch format.BitsPerSample {
+		case 8:
+			return func(b []byte, v float32) {
+				b[0] = byte(quantize(v, scaling8bit) + 128)
+			}, nil
+		case 16:
+			return func(b []byte, v float32) {
+				binary.LittleEndian.PutUint16(b, uint16(quantize(v, scaling16bit)))
+			}, nil
+		case 24:
+			return func(b []byte, v float32) {
+				putLittleEndianInt24(b, int32(quantize(v, scaling24bit)))
+			}, nil
+		case 32:
+			return func(b []byte, v float32) {
+				binary.LittleEndian.PutUint32(b, uint32(quantize(v, scaling32bit)))
+			}, nil
+		default:
+			return nil, fmt.Errorf("unsupported %d-bit PCM samples", format.BitsPerSample)
+		}
+	case IEEEFloatFormatTag:
+		switch format.BitsPerSample {
+		case 32:
+			return func(b []byte, v float32) {
+				binary.LittleEndian.PutUint32(b, math.Float32bits(v))
+			}, nil
+		case 64:
+			return func(b []byte, v float32) {
+				binary.LittleEndian.PutUint64(b, math.Float64bits(float64(v)))
+			}, nil
+		default:
+			return nil, fmt.Errorf("unsupported %d-bit IEEE float samples", format.BitsPerSample)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format tag %d", format.SampleFormatTag())
+	}
+}
+
+// quantize scales a normalized sample to an integer PCM value, clipping to
+// the representable range. The upper bound is scaling-1, since +1.0 itself
+// is not representable.
+func quantize(v float32, scaling float64) int64 {
+	return int64(math.Round(clip(float64(v)*scaling, -scaling, scaling-1)))
 }
 
 func WavToSpagoTensor(filename string) (mat.Tensor, error) {
@@ -87,19 +307,23 @@ func WavToSpagoTensor(filename string) (mat.Tensor, error) {
 }
 
 func FloatsToWav(data []float32, filename string) error {
-	for i, v := range data {
-		data[i] = clip(v, -1, 1) * scaling24bit
-	}
-	wav := Wave{
-		Format: Format{
-			Channels:      1,
-			SampleRate:    48_000,
-			AvgByteRate:   ComputePCMBAvgByteRate(1, 24, 48_000),
-			BlockAlign:    ComputePCMBlockAlign(1, 24),
-			BitsPerSample: 24,
-		},
-		Data: Encode24BitData(data),
+	return FloatsToWavWithFormat(data, DefaultFormat, filename)
+}
+
+// FloatsToWavWithFormat writes normalized floating-point samples to a WAVE
+// file, encoding them according to the given format.
+func FloatsToWavWithFormat(data []float32, format Format, filename string) error {
+	return FloatsToWavWithMetadata(data, format, nil, filename)
+}
+
+// FloatsToWavWithMetadata is like FloatsToWavWithFormat, additionally
+// writing the given metadata chunks.
+func FloatsToWavWithMetadata(data []float32, format Format, chunks Chunks, filename string) error {
+	b, err := EncodeFloats(data, format)
+	if err != nil {
+		return err
 	}
+	wav := Wave{Format: format, Data: b, Chunks: chunks}
 	if err := WriteFile(&wav, filename); err != nil {
 		return fmt.Errorf("failed to write WAV file: %w", err)
 	}
@@ -110,6 +334,6 @@ func SpagoTensorToWav(tensor mat.Tensor, filename string) error {
 	return FloatsToWav(tensor.Data().F32(), filename)
 }
 
-func clip(v, vMin, vMax float32) float32 {
+func clip[T float32 | float64](v, vMin, vMax T) T {
 	return max(vMin, min(v, vMax))
 }
@@ -0,0 +1,720 @@
+// Copyright 2023 The NLP Odyssey Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wave
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeDecodeFloats(t *testing.T) {
+	formats := []Format{
+		NewPCMFormat(1, 48_000, 8),
+		NewPCMFormat(1, 48_000, 16),
+		NewPCMFormat(1, 48_000, 24),
+		NewPCMFormat(1, 48_000, 32),
+		NewIEEEFloatFormat(1, 48_000, 32),
+		NewIEEEFloatFormat(1, 48_000, 64),
+	}
+	data := []float32{-1, -0.5, -0.25, 0, 0.25, 0.5, 0.75}
+
+	for _, format := range formats {
+		t.Run(fmt.Sprintf("tag %d %d-bit", format.FormatTag, format.BitsPerSample), func(t *testing.T) {
+			b, err := EncodeFloats(data, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err = Write(&Wave{Format: format, Data: b}, &buf); err != nil {
+				t.Fatal(err)
+			}
+			wav, err := Read(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertSameFormat(t, format, wav.Format)
+			actual, err := DecodeFloats(wav)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(actual) != len(data) {
+				t.Fatalf("expected %d samples, actual %d", len(data), len(actual))
+			}
+			for i, v := range data {
+				if math.Abs(float64(actual[i]-v)) > 1e-6 {
+					t.Errorf("sample %d: expected %g, actual %g", i, v, actual[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeFloatsClipping(t *testing.T) {
+	b, err := EncodeFloats([]float32{-2, 1, 2}, NewPCMFormat(1, 48_000, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Encode16BitData([]int{-32768, 32767, 32767})
+	if !bytes.Equal(b, expected) {
+		t.Errorf("expected %v, actual %v", expected, b)
+	}
+}
+
+func TestExtensibleFormat(t *testing.T) {
+	format := NewIEEEFloatFormat(4, 96_000, 32)
+	format.FormatTag = ExtensibleFormatTag
+	format.SubFormat = IEEEFloatSubFormat
+	format.ValidBitsPerSample = 32
+	format.ChannelMask = 0x33
+
+	data := []float32{0.1, 0.2, 0.3, 0.4, -0.1, -0.2, -0.3, -0.4}
+	b, err := EncodeFloats(data, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = Write(&Wave{Format: format, Data: b}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if tag := binary.LittleEndian.Uint16(buf.Bytes()[20:]); tag != ExtensibleFormatTag {
+		t.Fatalf("expected format tag %#x, actual %#x", ExtensibleFormatTag, tag)
+	}
+	wav, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wav.Format != format {
+		t.Errorf("expected format %+v, actual %+v", format, wav.Format)
+	}
+	actual, err := DecodeFloats(wav)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range data {
+		if actual[i] != v {
+			t.Errorf("sample %d: expected %g, actual %g", i, v, actual[i])
+		}
+	}
+}
+
+func TestExtensibleFormatWithoutSubFormat(t *testing.T) {
+	format := NewPCMFormat(2, 48_000, 24)
+	format.FormatTag = ExtensibleFormatTag
+	var buf bytes.Buffer
+	if err := Write(&Wave{Format: format}, &buf); err == nil {
+		t.Error("expected error for missing sub-format")
+	}
+}
+
+func TestFormatIsExtensible(t *testing.T) {
+	testCases := []struct {
+		format   Format
+		expected bool
+	}{
+		{NewPCMFormat(1, 48_000, 16), false},
+		{NewPCMFormat(2, 48_000, 16), false},
+		{NewPCMFormat(3, 48_000, 16), true},
+		{NewPCMFormat(1, 48_000, 24), true},
+		{NewIEEEFloatFormat(1, 48_000, 32), true},
+	}
+	for _, tc := range testCases {
+		if actual := tc.format.IsExtensible(); actual != tc.expected {
+			t.Errorf("%+v: expected %t, actual %t", tc.format, tc.expected, actual)
+		}
+	}
+}
+
+// assertSameFormat compares two formats, ignoring whether they are
+// expressed in basic or extensible form.
+func assertSameFormat(t *testing.T, expected, actual Format) {
+	t.Helper()
+	if expected.SampleFormatTag() != actual.SampleFormatTag() ||
+		expected.Channels != actual.Channels ||
+		expected.SampleRate != actual.SampleRate ||
+		expected.AvgByteRate != actual.AvgByteRate ||
+		expected.BlockAlign != actual.BlockAlign ||
+		expected.BitsPerSample != actual.BitsPerSample {
+		t.Errorf("expected format %+v, actual %+v", expected, actual)
+	}
+}
+
+func TestEncoderZeroChannels(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "test.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+	if _, err = NewEncoder(file, NewPCMFormat(0, 48_000, 24)); err == nil {
+		t.Fatal("expected error for zero channels")
+	}
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("expected nothing written, actual %d bytes", info.Size())
+	}
+}
+
+func TestEncoderDecoder(t *testing.T) {
+	format := NewPCMFormat(2, 48_000, 24)
+	data := make([]float32, 2*1000)
+	for i := range data {
+		data[i] = float32(math.Sin(float64(i)/10)) / 2
+	}
+
+	name := filepath.Join(t.TempDir(), "test.wav")
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := NewEncoder(file, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for from := 0; from < len(data); from += 2 * 300 {
+		if err = enc.WriteFrames(data[from:min(from+2*300, len(data))]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	wav, err := ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameFormat(t, format, wav.Format)
+	if len(wav.Data) != len(data)*3 {
+		t.Fatalf("expected %d bytes of data, actual %d", len(data)*3, len(wav.Data))
+	}
+
+	file, err = os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+	dec, err := NewDecoder(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := dec.NumFrames(); n != 1000 {
+		t.Errorf("expected 1000 frames, actual %d", n)
+	}
+	var actual []float32
+	buf := make([]float32, 2*128)
+	for {
+		n, err := dec.ReadFrames(buf)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual = append(actual, buf[:n*2]...)
+	}
+	if len(actual) != len(data) {
+		t.Fatalf("expected %d samples, actual %d", len(data), len(actual))
+	}
+	for i, v := range data {
+		if math.Abs(float64(actual[i]-v)) > 1e-6 {
+			t.Errorf("sample %d: expected %g, actual %g", i, v, actual[i])
+		}
+	}
+}
+
+func TestReadRF64(t *testing.T) {
+	format := NewPCMFormat(1, 48_000, 16)
+	data := Encode16BitData([]int{1, -2, 3, -4})
+
+	var fmtChunk bytes.Buffer
+	if err := writeFormatChunk(&fmtChunk, format); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	put := func(v any) {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	riffSize := 4 + 8 + ds64ChunkSize + fmtChunk.Len() + 8 + len(data)
+	for _, id := range [][4]byte{rf64ChunkID, bw64ChunkID} {
+		buf.Reset()
+		put(id)
+		put(uint32(math.MaxUint32))
+		put(waveFormType)
+		put(ds64ChunkID)
+		put(uint32(ds64ChunkSize))
+		put(uint64(riffSize))
+		put(uint64(len(data)))
+		put(uint64(len(data) / 2))
+		put(uint32(0))
+		put(fmtChunk.Bytes())
+		put(dataChunkID)
+		put(uint32(math.MaxUint32))
+		put(data)
+
+		wav, err := Read(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", id[:], err)
+		}
+		assertSameFormat(t, format, wav.Format)
+		if !bytes.Equal(wav.Data, data) {
+			t.Errorf("%s: expected data %v, actual %v", id[:], data, wav.Data)
+		}
+	}
+}
+
+func TestHeaderRF64(t *testing.T) {
+	format := NewPCMFormat(2, 48_000, 24)
+	small := header{format: format, dataSize: 1000}
+	large := header{format: format, dataSize: math.MaxUint32}
+	if small.isRF64() {
+		t.Error("expected small header not to be RF64")
+	}
+	if !large.isRF64() {
+		t.Error("expected large header to be RF64")
+	}
+
+	// Reserving room for ds64, the header size must not change when
+	// switching to RF64 form.
+	small.reserveDS64 = true
+	large.reserveDS64 = true
+	for _, h := range []header{small, large} {
+		var buf bytes.Buffer
+		if err := writeHeader(&buf, h); err != nil {
+			t.Fatal(err)
+		}
+		if uint64(buf.Len()) != h.size() {
+			t.Errorf("expected header size %d, actual %d", h.size(), buf.Len())
+		}
+	}
+	if small.size() != large.size() {
+		t.Errorf("expected same header sizes, actual %d and %d", small.size(), large.size())
+	}
+
+	var buf bytes.Buffer
+	if err := writeHeader(&buf, large); err != nil {
+		t.Fatal(err)
+	}
+	_, h, err := readHeader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.dataSize != large.dataSize {
+		t.Errorf("expected data size %d, actual %d", large.dataSize, h.dataSize)
+	}
+}
+
+func TestChunks(t *testing.T) {
+	var chunks Chunks
+	info := map[string]string{"INAM": "Title", "IART": "Artist"}
+	if err := chunks.SetInfo(info); err != nil {
+		t.Fatal(err)
+	}
+	bext := &BroadcastExtension{
+		Description:     "Description",
+		Originator:      "Originator",
+		OriginationDate: "2023-10-01",
+		OriginationTime: "12:34:56",
+		TimeReference:   1 << 40,
+		Version:         2,
+		LoudnessValue:   -2300,
+		CodingHistory:   "A=PCM,F=48000,W=24,M=mono\r\n",
+	}
+	chunks.SetBroadcastExtension(bext)
+	cuePoints := []CuePoint{
+		{ID: 1, Position: 10, DataChunkID: dataChunkID, SampleOffset: 10},
+		{ID: 2, Position: 20, DataChunkID: dataChunkID, SampleOffset: 20},
+	}
+	chunks.SetCuePoints(cuePoints)
+	custom := Chunk{ID: [4]byte{'i', 'X', 'M', 'L'}, Data: []byte("<BWFXML/>")}
+	chunks.Set(custom)
+
+	// The last chunk is moved after the wave data.
+	wav := &Wave{Format: DefaultFormat, Data: make([]byte, 30), Chunks: chunks[:3]}
+	var buf bytes.Buffer
+	if err := Write(wav, &buf); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	b = append(b, custom.ID[:]...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(custom.Data)))
+	b = append(b, custom.Data...)
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)-8))
+
+	actual, err := ReadChunks(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(chunks, actual) {
+		t.Fatalf("expected chunks %v, actual %v", chunks, actual)
+	}
+	wav, err = Read(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(chunks, wav.Chunks) {
+		t.Fatalf("expected chunks %v, actual %v", chunks, wav.Chunks)
+	}
+
+	actualInfo, err := wav.Chunks.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info, actualInfo) {
+		t.Errorf("expected INFO %v, actual %v", info, actualInfo)
+	}
+	actualBext, err := wav.Chunks.BroadcastExtension()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bext, actualBext) {
+		t.Errorf("expected bext %+v, actual %+v", bext, actualBext)
+	}
+	actualCuePoints, err := wav.Chunks.CuePoints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cuePoints, actualCuePoints) {
+		t.Errorf("expected cue points %v, actual %v", cuePoints, actualCuePoints)
+	}
+}
+
+func TestOddSizeChunks(t *testing.T) {
+	chunks := Chunks{
+		{ID: [4]byte{'t', 'e', 's', 't'}, Data: []byte{1, 2, 3}},
+	}
+	trailing := Chunk{ID: [4]byte{'l', 'a', 's', 't'}, Data: []byte{4}}
+	wav := &Wave{
+		Format: NewPCMFormat(1, 48_000, 8),
+		Data:   []byte{10, 20, 30, 40, 50},
+		Chunks: chunks,
+	}
+	var buf bytes.Buffer
+	if err := Write(wav, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len()%2 != 0 {
+		t.Fatalf("expected even stream length, actual %d", buf.Len())
+	}
+	b := buf.Bytes()
+	b = append(b, trailing.ID[:]...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(trailing.Data)))
+	b = append(b, trailing.Data...)
+	b = append(b, 0) // pad byte
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)-8))
+
+	actual, err := Read(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(wav.Data, actual.Data) {
+		t.Errorf("expected data %v, actual %v", wav.Data, actual.Data)
+	}
+	expectedChunks := append(chunks, trailing)
+	if !reflect.DeepEqual(expectedChunks, actual.Chunks) {
+		t.Errorf("expected chunks %v, actual %v", expectedChunks, actual.Chunks)
+	}
+	actualChunks, err := ReadChunks(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expectedChunks, actualChunks) {
+		t.Errorf("expected chunks %v, actual %v", expectedChunks, actualChunks)
+	}
+}
+
+func TestTruncatedData(t *testing.T) {
+	format := NewPCMFormat(2, 48_000, 16)
+	data := Encode16BitData([]int{1, 2, 3, 4, 5, 6, 7, 8})
+	var buf bytes.Buffer
+	if err := Write(&Wave{Format: format, Data: data}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	// Cut the last frame in half.
+	b := buf.Bytes()[:buf.Len()-2]
+
+	wav, err := Read(bytes.NewReader(b))
+	var truncated *TruncatedDataError
+	if !errors.As(err, &truncated) {
+		t.Fatalf("expected TruncatedDataError, actual %v", err)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected error to match io.ErrUnexpectedEOF")
+	}
+	if truncated.DeclaredSize != 16 || truncated.ActualSize != 14 {
+		t.Errorf("expected sizes 16 and 14, actual %d and %d", truncated.DeclaredSize, truncated.ActualSize)
+	}
+	if !bytes.Equal(wav.Data, data[:12]) {
+		t.Errorf("expected data %v, actual %v", data[:12], wav.Data)
+	}
+
+	dec, err := NewDecoder(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := make([]float32, 2*10)
+	n, err := dec.ReadFrames(frames)
+	if err != nil || n != 3 {
+		t.Fatalf("expected 3 frames and no error, actual %d and %v", n, err)
+	}
+	n, err = dec.ReadFrames(frames)
+	if !errors.As(err, &truncated) || n != 0 {
+		t.Fatalf("expected 0 frames and TruncatedDataError, actual %d and %v", n, err)
+	}
+}
+
+func TestChannelSelection(t *testing.T) {
+	data := []float32{1, 2, 3, 4, 5, 6}
+	testCases := []struct {
+		value    string
+		split    [][]float32
+		merged   []float32
+		expected string
+	}{
+		{"0", [][]float32{{1, 3, 5}}, []float32{1, 1, 3, 3, 5, 5}, "0"},
+		{"1", [][]float32{{2, 4, 6}}, []float32{2, 2, 4, 4, 6, 6}, "1"},
+		{"mix", [][]float32{{1.5, 3.5, 5.5}}, []float32{1.5, 1.5, 3.5, 3.5, 5.5, 5.5}, "mix"},
+		{"all", [][]float32{{1, 3, 5}, {2, 4, 6}}, data, "all"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			var s ChannelSelection
+			if err := s.Set(tc.value); err != nil {
+				t.Fatal(err)
+			}
+			if s.String() != tc.expected {
+				t.Errorf("expected string %q, actual %q", tc.expected, s.String())
+			}
+			if err := s.Validate(2); err != nil {
+				t.Fatal(err)
+			}
+			split := s.SplitAll(data, 2)
+			if !reflect.DeepEqual(split, tc.split) {
+				t.Errorf("expected split %v, actual %v", tc.split, split)
+			}
+			merged := s.MergeAll(split, 2)
+			if !reflect.DeepEqual(merged, tc.merged) {
+				t.Errorf("expected merged %v, actual %v", tc.merged, merged)
+			}
+		})
+	}
+
+	var s ChannelSelection
+	if err := s.Set("-1"); err == nil {
+		t.Error("expected error for negative channel index")
+	}
+	if err := (ChannelSelection{Index: 2}).Validate(2); err == nil {
+		t.Error("expected error for out of range channel index")
+	}
+}
+
+func TestResample(t *testing.T) {
+	testCases := []struct {
+		inRate, outRate int
+		quality         ResampleQuality
+		tolerance       float64
+	}{
+		{44_100, 48_000, ResampleHigh, 1e-4},
+		{96_000, 48_000, ResampleHigh, 1e-4},
+		{48_000, 44_100, ResampleMedium, 1e-3},
+		{22_050, 48_000, ResampleLow, 1e-2},
+	}
+	const frequency = 1000
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d to %d", tc.inRate, tc.outRate), func(t *testing.T) {
+			input := makeSine(tc.inRate, frequency, tc.inRate/10)
+			output, err := Resample(input, tc.inRate, tc.outRate, tc.quality)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectedLength := (len(input)*tc.outRate + tc.inRate - 1) / tc.inRate
+			if len(output) != expectedLength {
+				t.Fatalf("expected %d samples, actual %d", expectedLength, len(output))
+			}
+			expected := makeSine(tc.outRate, frequency, len(output))
+			// ignore the edges, affected by the implicit zero-padding
+			margin := tc.outRate / 200
+			for i := margin; i < len(output)-margin; i++ {
+				if d := math.Abs(float64(output[i] - expected[i])); d > tc.tolerance {
+					t.Fatalf("sample %d: expected %g, actual %g", i, expected[i], output[i])
+				}
+			}
+
+			r, err := NewResampler(tc.inRate, tc.outRate, tc.quality)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var streamed []float32
+			for from := 0; from < len(input); from += 333 {
+				streamed = r.Process(streamed, input[from:min(from+333, len(input))])
+			}
+			streamed = r.Flush(streamed)
+			if !reflect.DeepEqual(streamed, output) {
+				t.Error("streamed resampling differs from one-shot resampling")
+			}
+		})
+	}
+}
+
+func makeSine(sampleRate, frequency, length int) []float32 {
+	data := make([]float32, length)
+	for i := range data {
+		data[i] = float32(0.5 * math.Sin(2*math.Pi*float64(frequency)*float64(i)/float64(sampleRate)))
+	}
+	return data
+}
+
+func TestQuantizer(t *testing.T) {
+	t.Run("rounding and clipping", func(t *testing.T) {
+		q, err := NewQuantizer(1, 16, Quantization{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		input := []float32{-2, 1, 2, 0.5, 0.1, -0.3}
+		original := append([]float32(nil), input...)
+		output := make([]float32, len(input))
+		q.Quantize(output, input)
+		if !reflect.DeepEqual(input, original) {
+			t.Error("input was modified")
+		}
+		if q.Clipped() != 3 {
+			t.Errorf("expected 3 clipped samples, actual %d", q.Clipped())
+		}
+		expected, _ := EncodeFloats(input, NewPCMFormat(1, 48_000, 16))
+		if actual, _ := EncodeFloats(output, NewPCMFormat(1, 48_000, 16)); !bytes.Equal(actual, expected) {
+			t.Errorf("expected %v, actual %v", expected, actual)
+		}
+	})
+
+	// A sine wave of 0.3 LSB is lost by plain rounding, while dither
+	// preserves it, on average.
+	const scaling = scaling16bit
+	input := makeSine(48_000, 100, 48_000)
+	for i := range input {
+		input[i] *= 0.6 / scaling
+	}
+	quantize := func(quantization Quantization) []float64 {
+		q, err := NewQuantizer(1, 16, quantization)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output := make([]float32, len(input))
+		for from := 0; from < len(input); from += 1000 {
+			q.Quantize(output[from:], input[from:min(from+1000, len(input))])
+		}
+		errors := make([]float64, len(output))
+		for i, v := range output {
+			y := float64(v) * scaling
+			if y != math.Round(y) {
+				t.Fatalf("sample %d: %g is not quantized", i, v)
+			}
+			errors[i] = y - float64(input[i])*scaling
+		}
+		return errors
+	}
+	// lowFrequencyEnergy returns the energy of the error averaged over
+	// blocks of 256 samples, a rough measure of its low-frequency content.
+	lowFrequencyEnergy := func(errors []float64) float64 {
+		var energy float64
+		for from := 0; from+256 <= len(errors); from += 256 {
+			var sum float64
+			for _, e := range errors[from : from+256] {
+				sum += e
+			}
+			energy += sum * sum
+		}
+		return energy
+	}
+
+	rounded := quantize(Quantization{})
+	for i, e := range rounded {
+		if math.Abs(e) > 0.5 {
+			t.Fatalf("sample %d: rounding error %g", i, e)
+		}
+	}
+	dithered := quantize(Quantization{Dither: TPDFDither})
+	for i, e := range dithered {
+		if math.Abs(e) > 1.5 {
+			t.Fatalf("sample %d: dither error %g", i, e)
+		}
+	}
+	if r, d := lowFrequencyEnergy(rounded), lowFrequencyEnergy(dithered); d >= r/4 {
+		t.Errorf("expected dither to decorrelate error: rounding %g, dither %g", r, d)
+	}
+	for _, shaping := range []NoiseShaping{FirstOrderNoiseShaping, LipshitzNoiseShaping, WannamakerNoiseShaping} {
+		shaped := quantize(Quantization{Dither: TPDFDither, NoiseShaping: shaping})
+		if d, s := lowFrequencyEnergy(dithered), lowFrequencyEnergy(shaped); s >= d/4 {
+			t.Errorf("%s: expected less low-frequency noise: dither %g, shaped %g", &shaping, d, s)
+		}
+	}
+
+	t.Run("channels", func(t *testing.T) {
+		quantization := Quantization{NoiseShaping: LipshitzNoiseShaping}
+		channels := [][]float32{makeSine(48_000, 440, 1000), makeSine(48_000, 880, 1000)}
+		interleaved := Interleave(channels)
+		stereo, _ := NewQuantizer(2, 24, quantization)
+		stereo.Quantize(interleaved, interleaved)
+		for c, actual := range Deinterleave(interleaved, 2) {
+			mono, _ := NewQuantizer(1, 24, quantization)
+			expected := make([]float32, len(channels[c]))
+			mono.Quantize(expected, channels[c])
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("channel %d differs from mono quantization", c)
+			}
+		}
+	})
+
+	if _, err := NewQuantizer(1, 32, Quantization{}); err == nil {
+		t.Error("expected error for 32-bit quantization")
+	}
+	var shaping NoiseShaping
+	if err := shaping.Set("wannamaker"); err != nil || shaping != WannamakerNoiseShaping {
+		t.Errorf("expected wannamaker noise shaping, actual %s (%v)", &shaping, err)
+	}
+}
+
+func TestNoiseShapingSuitsSampleRate(t *testing.T) {
+	testCases := []struct {
+		shaping    NoiseShaping
+		sampleRate int
+		expected   bool
+	}{
+		{NoNoiseShaping, 96_000, true},
+		{FirstOrderNoiseShaping, 96_000, true},
+		{LipshitzNoiseShaping, 44_100, true},
+		{LipshitzNoiseShaping, 48_000, true},
+		{LipshitzNoiseShaping, 96_000, false},
+		{WannamakerNoiseShaping, 88_200, false},
+	}
+	for _, tc := range testCases {
+		if actual := tc.shaping.SuitsSampleRate(tc.sampleRate); actual != tc.expected {
+			t.Errorf("%s at %dHz: expected %t, actual %t", tc.shaping.String(), tc.sampleRate, tc.expected, actual)
+		}
+	}
+}
@@ -17,8 +17,10 @@ package wave
 import (
 	"bufio"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 )
 
@@ -43,33 +45,213 @@ func WriteFile(wav *Wave, name string) (err error) {
 	return nil
 }
 
+// Write writes the wave to w, with its metadata chunks preceding the wave
+// data. If the data exceeds the RIFF 4 GiB limit, the RF64 form is used.
 func Write(wav *Wave, w io.Writer) error {
-	if err := writeFourCharCode(w, riffChunkID); err != nil {
+	h := header{
+		format:   wav.Format,
+		chunks:   wav.Chunks,
+		dataSize: uint64(len(wav.Data)),
+	}
+	if err := writeHeader(w, h); err != nil {
+		return err
+	}
+	if _, err := w.Write(wav.Data); err != nil {
+		return fmt.Errorf("failed to write wave data: %w", err)
+	}
+	return writePadByte(w, len(wav.Data))
+}
+
+// A header describes everything preceding the wave data, as written by
+// writeHeader.
+type header struct {
+	format   Format
+	chunks   Chunks
+	dataSize uint64
+	// reserveDS64 makes room for a "ds64" chunk with a "JUNK" chunk of the
+	// same size, so that the header can be later rewritten in RF64 form,
+	// in place, if the data grows beyond the RIFF 4 GiB limit.
+	reserveDS64 bool
+}
+
+// isRF64 reports whether the RIFF chunk size exceeds the 32-bit limit,
+// requiring the RF64 form.
+func (h header) isRF64() bool {
+	return h.baseSize()-8+paddedSize(h.dataSize) > math.MaxUint32
+}
+
+// size returns the total size of the header.
+func (h header) size() uint64 {
+	if !h.reserveDS64 && h.isRF64() {
+		return h.baseSize() + 8 + ds64ChunkSize
+	}
+	return h.baseSize()
+}
+
+// baseSize returns the size of the header, including the "ds64" (or
+// "JUNK") chunk only when reserved.
+func (h header) baseSize() uint64 {
+	size := uint64(4 + // "RIFF"
+		4 + // RIFF size
+		4 + // "WAVE"
+		4 + // "fmt "
+		4 + // fmt size
+		computeFormatChunkSize(h.format) +
+		4 + // "data"
+		4) // data size
+	for _, c := range h.chunks {
+		size += 8 + paddedSize(uint64(len(c.Data)))
+	}
+	if h.reserveDS64 {
+		size += 8 + ds64ChunkSize
+	}
+	return size
+}
+
+// riffSize returns the size of the RIFF chunk.
+func (h header) riffSize() uint64 {
+	return h.size() - 8 + // "RIFF" and RIFF size
+		paddedSize(h.dataSize)
+}
+
+// paddedSize returns the size of a chunk's data followed by the pad byte,
+// if needed: RIFF chunks are word-aligned, so odd-sized data is padded.
+func paddedSize(size uint64) uint64 {
+	return size + size%2
+}
+
+// writeHeader writes the RIFF header, the format chunk, the metadata chunks
+// and the header of the data chunk, so that the wave data itself can immediately follow.
+func writeHeader(w io.Writer, h header) error {
+	rf64 := h.isRF64()
+	riffID := riffChunkID
+	riffSize := uint32(h.riffSize())
+	dataSize := uint32(h.dataSize)
+	if rf64 {
+		riffID = rf64ChunkID
+		riffSize = math.MaxUint32
+		dataSize = math.MaxUint32
+	}
+
+	if err := writeFourCharCode(w, riffID); err != nil {
 		return err
 	}
-	if err := writeUint32(w, computeRIFFChunkSize(wav)); err != nil {
+	if err := writeUint32(w, riffSize); err != nil {
 		return err
 	}
 	if err := writeFourCharCode(w, waveFormType); err != nil {
 		return err
 	}
-	if err := writeFormatChunk(w, wav); err != nil {
+	if rf64 {
+		if err := writeDS64Chunk(w, h); err != nil {
+			return err
+		}
+	} else if h.reserveDS64 {
+		if err := writeJunkChunk(w, ds64ChunkSize); err != nil {
+			return err
+		}
+	}
+	if err := writeFormatChunk(w, h.format); err != nil {
+		return err
+	}
+	for _, c := range h.chunks {
+		if err := writeChunk(w, c); err != nil {
+			return err
+		}
+	}
+	if err := writeFourCharCode(w, dataChunkID); err != nil {
+		return err
+	}
+	return writeUint32(w, dataSize)
+}
+
+func writeDS64Chunk(w io.Writer, h header) error {
+	if err := writeFourCharCode(w, ds64ChunkID); err != nil {
+		return err
+	}
+	if err := writeUint32(w, ds64ChunkSize); err != nil {
+		return err
+	}
+	if err := writeUint64(w, h.riffSize()); err != nil {
+		return err
+	}
+	if err := writeUint64(w, h.dataSize); err != nil {
+		return err
+	}
+	var sampleCount uint64
+	if blockAlign := uint64(h.format.BlockAlign); blockAlign > 0 {
+		sampleCount = h.dataSize / blockAlign
+	}
+	if err := writeUint64(w, sampleCount); err != nil {
+		return err
+	}
+	return writeUint32(w, 0) // table length
+}
+
+func writeChunk(w io.Writer, c Chunk) error {
+	if err := writeFourCharCode(w, c.ID); err != nil {
+		return err
+	}
+	if err := writeUint32(w, uint32(len(c.Data))); err != nil {
+		return err
+	}
+	if _, err := w.Write(c.Data); err != nil {
+		return fmt.Errorf("failed to write %q chunk data: %w", string(c.ID[:]), err)
+	}
+	return writePadByte(w, len(c.Data))
+}
+
+// writePadByte writes the pad byte following a chunk of odd size.
+func writePadByte[T int | int64](w io.Writer, chunkSize T) error {
+	if chunkSize%2 == 0 {
+		return nil
+	}
+	if _, err := w.Write([]byte{0}); err != nil {
+		return fmt.Errorf("failed to write pad byte: %w", err)
+	}
+	return nil
+}
+
+func writeJunkChunk(w io.Writer, size uint32) error {
+	if err := writeFourCharCode(w, junkChunkID); err != nil {
+		return err
+	}
+	if err := writeUint32(w, size); err != nil {
 		return err
 	}
-	return writeWaveData(w, wav)
+	if _, err := w.Write(make([]byte, size)); err != nil {
+		return fmt.Errorf("failed to write JUNK chunk: %w", err)
+	}
+	return nil
 }
 
-func writeFormatChunk(w io.Writer, wav *Wave) error {
+func writeFormatChunk(w io.Writer, format Format) error {
 	if err := writeFourCharCode(w, fmtChunkID); err != nil {
 		return err
 	}
-	if err := writeUint32(w, formatChunkSize); err != nil {
+	if err := writeUint32(w, computeFormatChunkSize(format)); err != nil {
 		return err
 	}
-	if err := writeUint16(w, wavePCMFormatTag); err != nil {
+	formatTag := format.FormatTag
+	if formatTag == 0 {
+		// Zero-value tag, kept for compatibility with callers which
+		// pre-date the FormatTag field and only produced PCM data.
+		formatTag = PCMFormatTag
+	}
+	extensible := format.IsExtensible()
+	if extensible {
+		if format.SubFormat == (GUID{}) {
+			if formatTag == ExtensibleFormatTag {
+				// There's no basic tag to derive it from.
+				return errors.New("extensible format without sub-format")
+			}
+			format.SubFormat = makeSubFormat(formatTag)
+		}
+		formatTag = ExtensibleFormatTag
+	}
+	if err := writeUint16(w, formatTag); err != nil {
 		return err
 	}
-	format := wav.Format
 	if err := writeUint16(w, format.Channels); err != nil {
 		return err
 	}
@@ -85,18 +267,28 @@ func writeFormatChunk(w io.Writer, wav *Wave) error {
 	if err := writeUint16(w, format.BitsPerSample); err != nil {
 		return err
 	}
+	if extensible {
+		return writeFormatExtension(w, format)
+	}
 	return nil
 }
 
-func writeWaveData(w io.Writer, wav *Wave) error {
-	if err := writeFourCharCode(w, dataChunkID); err != nil {
+func writeFormatExtension(w io.Writer, format Format) error {
+	if err := writeUint16(w, formatExtensionSize); err != nil {
 		return err
 	}
-	if err := writeUint32(w, uint32(len(wav.Data))); err != nil {
+	validBitsPerSample := format.ValidBitsPerSample
+	if validBitsPerSample == 0 {
+		validBitsPerSample = format.BitsPerSample
+	}
+	if err := writeUint16(w, validBitsPerSample); err != nil {
 		return err
 	}
-	if _, err := w.Write(wav.Data); err != nil {
-		return fmt.Errorf("failed to write wave data: %w", err)
+	if err := writeUint32(w, format.ChannelMask); err != nil {
+		return err
+	}
+	if _, err := w.Write(format.SubFormat[:]); err != nil {
+		return fmt.Errorf("failed to write format's sub-format: %w", err)
 	}
 	return nil
 }
@@ -108,14 +300,22 @@ const formatChunkSize = 2 + // format tag
 	2 + // block align
 	2 // bits per sample
 
-func computeRIFFChunkSize(wav *Wave) uint32 {
-	return 4 + // "WAVE"
-		4 + // "fmt "
-		4 + // fmt size
-		formatChunkSize +
-		4 + // "data"
-		4 + // data size
-		uint32(len(wav.Data))
+const ds64ChunkSize = 8 + // RIFF size
+	8 + // data size
+	8 + // sample count
+	4 // table length
+
+const formatExtensionSize = 2 + // valid bits per sample
+	4 + // channel mask
+	16 // sub-format
+
+func computeFormatChunkSize(format Format) uint32 {
+	if format.IsExtensible() {
+		return formatChunkSize +
+			2 + // extension size
+			formatExtensionSize
+	}
+	return formatChunkSize
 }
 
 func writeFourCharCode(w io.Writer, value [4]byte) error {
@@ -126,11 +326,49 @@ func writeFourCharCode(w io.Writer, value [4]byte) error {
 	return nil
 }
 
+// Encode8BitData converts signed values, in the range [-128, 127], to 8-bit
+// unsigned PCM wave data.
+func Encode8BitData[T int | int32 | int64 | float32 | float64](dataIn []T) []byte {
+	return encodeData(dataIn, 1, func(b []byte, v T) {
+		b[0] = byte(int32(v) + 128)
+	})
+}
+
+func Encode16BitData[T int | int32 | int64 | float32 | float64](dataIn []T) []byte {
+	return encodeData(dataIn, 2, func(b []byte, v T) {
+		binary.LittleEndian.PutUint16(b, uint16(int16(v)))
+	})
+}
+
 func Encode24BitData[T int | int32 | int64 | float32 | float64](dataIn []T) []byte {
-	dataOut := make([]byte, len(dataIn)*3)
+	return encodeData(dataIn, 3, func(b []byte, v T) {
+		putLittleEndianInt24(b, int32(v))
+	})
+}
+
+func Encode32BitData[T int | int32 | int64 | float32 | float64](dataIn []T) []byte {
+	return encodeData(dataIn, 4, func(b []byte, v T) {
+		binary.LittleEndian.PutUint32(b, uint32(int32(v)))
+	})
+}
+
+func EncodeFloat32Data[T float32 | float64](dataIn []T) []byte {
+	return encodeData(dataIn, 4, func(b []byte, v T) {
+		binary.LittleEndian.PutUint32(b, math.Float32bits(float32(v)))
+	})
+}
+
+func EncodeFloat64Data[T float32 | float64](dataIn []T) []byte {
+	return encodeData(dataIn, 8, func(b []byte, v T) {
+		binary.LittleEndian.PutUint64(b, math.Float64bits(float64(v)))
+	})
+}
+
+func encodeData[T any](dataIn []T, size int, encode func([]byte, T)) []byte {
+	dataOut := make([]byte, len(dataIn)*size)
 	for i, v := range dataIn {
-		j := i * 3
-		putLittleEndianInt24(dataOut[j:j+3], int32(v))
+		j := i * size
+		encode(dataOut[j:j+size], v)
 	}
 	return dataOut
 }
@@ -154,6 +392,17 @@ func writeUint16(w io.Writer, v uint16) error {
 	return nil
 }
 
+func writeUint64(w io.Writer, v uint64) error {
+	var arr [8]byte
+	buf := arr[:]
+	binary.LittleEndian.PutUint64(buf, v)
+	_, err := w.Write(buf)
+	if err != nil {
+		return fmt.Errorf("failed to write uint64 value: %w", err)
+	}
+	return nil
+}
+
 func writeUint32(w io.Writer, v uint32) error {
 	var arr [4]byte
 	buf := arr[:]